@@ -0,0 +1,274 @@
+package domain
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 学年暦が登録されていない年度
+var ErrCalendarNotFound = errors.New("calendar not found")
+
+// 学年暦の日時のタイムゾーン
+var CalendarLocation = time.FixedZone("JST", 9*60*60)
+
+// 学年暦の日付の書式
+const CalendarDateLayout = "2006-01-02"
+
+// 曜時限の曜日の並び、time.Weekday の順
+const calendarWeekdays = "日月火水木金土"
+
+// 年度の学年暦
+type AcademicCalendar struct {
+	Year int
+	// 展開した開講時期 (春A から秋C、夏季休業中・春季休業中) ごとの授業期間
+	Terms map[int]*DateRange
+	// 時限ごとの授業の時刻
+	Periods map[int]*ClockRange
+	// 授業を行わない日、祝日や休業日、日付の順
+	Holidays []time.Time
+	// 振替授業日、日付の順
+	Substitutes []*SubstituteDay
+	// 開講時期ごとの試験期間、通常の授業は行わない
+	Exams map[int]*DateRange
+}
+
+// 振替授業日、Date には Day の曜日の授業を行う
+type SubstituteDay struct {
+	Date time.Time
+	// "月" のような曜日
+	Day string
+}
+
+// 日付の範囲、両端を含む
+// 日付は CalendarLocation の 0 時
+type DateRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// 時刻の範囲、0 時からの経過時間
+type ClockRange struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+type CalendarRepository interface {
+	// 見つからなければ ErrCalendarNotFound
+	FindByYear(year int) (*AcademicCalendar, error)
+}
+
+// /timetable/ics の条件
+type TimetableCalendarQuery struct {
+	// 学年暦を使う年度
+	Year int `json:"year"`
+	// 時間割に入っている科目の科目番号
+	CourseNumbers []string `json:"course_numbers"`
+}
+
+// 時間割のカレンダー
+type TimetableCalendar struct {
+	Calendar *AcademicCalendar
+	// 科目の与えられた順
+	Events []*CourseEvent
+}
+
+// 毎週繰り返す授業の予定
+// 開講時期と曜日ごとに、続いている時限を 1 つにまとめる
+type CourseEvent struct {
+	Course *Course
+	// 展開した開講時期
+	Term int
+	// "月" のような曜日
+	Day         string
+	FirstPeriod int
+	LastPeriod  int
+	// 最初の授業の開始・終了日時
+	Start time.Time
+	End   time.Time
+	// 毎週の繰り返しの最後の開始日時
+	Last time.Time
+	// 毎週の繰り返しのうち、授業を行わない日の授業の開始日時
+	Exceptions []time.Time
+	// 毎週の繰り返しに加えて、振替授業日に授業を行う開始日時
+	Additions []time.Time
+}
+
+// date に授業を行う曜日
+// 授業期間の外、授業を行わない日、試験期間は false
+// 振替授業日は授業を行わない日より優先する
+func (c *AcademicCalendar) ClassDay(date time.Time) (string, bool) {
+	if len(c.TermsOn(date)) == 0 {
+		return "", false
+	}
+	key := date.Format(CalendarDateLayout)
+	for _, substitute := range c.Substitutes {
+		if substitute.Date.Format(CalendarDateLayout) == key {
+			return substitute.Day, true
+		}
+	}
+	for _, holiday := range c.Holidays {
+		if holiday.Format(CalendarDateLayout) == key {
+			return "", false
+		}
+	}
+	for _, exam := range c.Exams {
+		if exam.Contains(date) {
+			return "", false
+		}
+	}
+	return calendarWeekday(date.Weekday()), true
+}
+
+// date を授業期間に含む展開した開講時期、番号の順
+func (c *AcademicCalendar) TermsOn(date time.Time) []int {
+	terms := []int{}
+	for term, dates := range c.Terms {
+		if dates.Contains(date) {
+			terms = append(terms, term)
+		}
+	}
+	sort.Ints(terms)
+	return terms
+}
+
+// date の日付が範囲に含まれるか
+func (r *DateRange) Contains(date time.Time) bool {
+	day := calendarDate(date)
+	return !day.Before(r.Start) && !day.After(r.End)
+}
+
+// CalendarLocation の 0 時
+func calendarDate(t time.Time) time.Time {
+	t = t.In(CalendarLocation)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, CalendarLocation)
+}
+
+// "月" のような曜日
+func calendarWeekday(weekday time.Weekday) string {
+	return string([]rune(calendarWeekdays)[weekday])
+}
+
+// "月" のような曜日を time.Weekday にする
+func ParseCalendarWeekday(day string) (time.Weekday, bool) {
+	for i, d := range []rune(calendarWeekdays) {
+		if string(d) == day {
+			return time.Weekday(i), true
+		}
+	}
+	return 0, false
+}
+
+// 科目の授業の予定
+// 学年暦に授業期間・時刻の無い開講時期・時限と、毎週決まったコマではない曜時限は含めない
+func CourseEvents(course *Course, calendar *AcademicCalendar) []*CourseEvent {
+	events := []*CourseEvent{}
+	for _, term := range ExpandTerms(course.Term) {
+		dates, ok := calendar.Terms[term]
+		if !ok {
+			continue
+		}
+		for _, day := range courseDays(course.Period) {
+			for _, run := range periodRuns(course.Period, day, calendar) {
+				event := newCourseEvent(course, term, day, run, dates, calendar)
+				if event != nil {
+					events = append(events, event)
+				}
+			}
+		}
+	}
+	return events
+}
+
+// 曜時限の曜日、現れた順
+func courseDays(periods []string) []string {
+	days := []string{}
+	seen := map[string]bool{}
+	for _, period := range periods {
+		if !IsWeeklyPeriod(period) {
+			continue
+		}
+		day, _ := utf8.DecodeRuneInString(period)
+		if !seen[string(day)] {
+			seen[string(day)] = true
+			days = append(days, string(day))
+		}
+	}
+	return days
+}
+
+// day の時限を、続いているものごとにまとめた [最初, 最後] の組
+// 学年暦に時刻の無い時限は含めない
+func periodRuns(periods []string, day string, calendar *AcademicCalendar) [][2]int {
+	numbers := []int{}
+	seen := map[int]bool{}
+	for _, period := range periods {
+		if !IsWeeklyPeriod(period) || !strings.HasPrefix(period, day) {
+			continue
+		}
+		number := int(period[len(day)] - '0')
+		if _, ok := calendar.Periods[number]; !ok || seen[number] {
+			continue
+		}
+		seen[number] = true
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+
+	runs := [][2]int{}
+	for _, number := range numbers {
+		if len(runs) != 0 && runs[len(runs)-1][1]+1 == number {
+			runs[len(runs)-1][1] = number
+			continue
+		}
+		runs = append(runs, [2]int{number, number})
+	}
+	return runs
+}
+
+// 授業期間の日ごとに ClassDay で授業を行う曜日を確かめる
+// 授業期間に day の日が無いか、授業を行う日が 1 日も無ければ nil
+func newCourseEvent(course *Course, term int, day string, run [2]int, dates *DateRange, calendar *AcademicCalendar) *CourseEvent {
+	weekday, _ := ParseCalendarWeekday(day)
+	first := dates.Start.AddDate(0, 0, (int(weekday)-int(dates.Start.Weekday())+7)%7)
+	if first.After(dates.End) {
+		return nil
+	}
+
+	start := calendar.Periods[run[0]].Start
+	end := calendar.Periods[run[1]].End
+	event := &CourseEvent{
+		Course:      course,
+		Term:        term,
+		Day:         day,
+		FirstPeriod: run[0],
+		LastPeriod:  run[1],
+		Start:       first.Add(start),
+		End:         first.Add(end),
+		Exceptions:  []time.Time{},
+		Additions:   []time.Time{},
+	}
+	held := 0
+	for date := dates.Start; !date.After(dates.End); date = date.AddDate(0, 0, 1) {
+		classDay, ok := calendar.ClassDay(date)
+		weekly := date.Weekday() == weekday
+		if weekly {
+			event.Last = date.Add(start)
+		}
+		switch {
+		case ok && classDay == day:
+			held++
+			if !weekly {
+				event.Additions = append(event.Additions, date.Add(start))
+			}
+		case weekly:
+			event.Exceptions = append(event.Exceptions, date.Add(start))
+		}
+	}
+	if held == 0 {
+		return nil
+	}
+	return event
+}
@@ -0,0 +1,170 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 春A (2021-04-12 から 2021-05-19) と 1, 2, 4 時限の学年暦
+// 5/19 (水) は月曜日の授業を行い、5/17, 5/18 は春A の試験期間
+func testAcademicCalendar() *AcademicCalendar {
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(2021, month, day, 0, 0, 0, 0, CalendarLocation)
+	}
+	clock := func(hour int, minute int) time.Duration {
+		return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute
+	}
+	return &AcademicCalendar{
+		Year: 2021,
+		Terms: map[int]*DateRange{
+			1: {Start: date(time.April, 12), End: date(time.May, 19)},
+			// 月曜日の無い授業期間
+			7: {Start: date(time.August, 11), End: date(time.August, 13)},
+			// 月曜日がすべて休日の授業期間
+			8: {Start: date(time.May, 3), End: date(time.May, 5)},
+		},
+		Periods: map[int]*ClockRange{
+			1: {Start: clock(8, 40), End: clock(9, 55)},
+			2: {Start: clock(10, 10), End: clock(11, 25)},
+			4: {Start: clock(13, 45), End: clock(15, 0)},
+		},
+		Holidays: []time.Time{date(time.April, 29), date(time.May, 3), date(time.May, 4), date(time.May, 5)},
+		Substitutes: []*SubstituteDay{
+			{Date: date(time.May, 19), Day: "月"},
+		},
+		Exams: map[int]*DateRange{
+			1: {Start: date(time.May, 17), End: date(time.May, 18)},
+		},
+	}
+}
+
+func TestAcademicCalendar_ClassDay(t *testing.T) {
+	tests := []struct {
+		name   string
+		date   time.Time
+		want   string
+		wantOk bool
+	}{
+		{
+			name:   "授業期間の平日",
+			date:   time.Date(2021, 4, 13, 0, 0, 0, 0, CalendarLocation),
+			want:   "火",
+			wantOk: true,
+		},
+		{
+			name:   "時刻があっても日付で判定する",
+			date:   time.Date(2021, 4, 13, 1, 30, 0, 0, time.UTC),
+			want:   "火",
+			wantOk: true,
+		},
+		{
+			name:   "休日",
+			date:   time.Date(2021, 4, 29, 0, 0, 0, 0, CalendarLocation),
+			wantOk: false,
+		},
+		{
+			name:   "試験期間",
+			date:   time.Date(2021, 5, 17, 0, 0, 0, 0, CalendarLocation),
+			wantOk: false,
+		},
+		{
+			name:   "振替授業日",
+			date:   time.Date(2021, 5, 19, 0, 0, 0, 0, CalendarLocation),
+			want:   "月",
+			wantOk: true,
+		},
+		{
+			name:   "授業期間の外",
+			date:   time.Date(2021, 4, 1, 0, 0, 0, 0, CalendarLocation),
+			wantOk: false,
+		},
+	}
+	calendar := testAcademicCalendar()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotOk := calendar.ClassDay(tt.date)
+			if got != tt.want || gotOk != tt.wantOk {
+				t.Errorf("AcademicCalendar.ClassDay() = %s, %v, want %s, %v", got, gotOk, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestCourseEvents(t *testing.T) {
+	at := func(month time.Month, day int, hour int, minute int) time.Time {
+		return time.Date(2021, month, day, hour, minute, 0, 0, CalendarLocation)
+	}
+	calendar := testAcademicCalendar()
+
+	tests := []struct {
+		name   string
+		course *Course
+		want   []*CourseEvent
+	}{
+		{
+			name:   "続いている時限はまとめ、休日と試験期間は除いて振替授業日を加える",
+			course: &Course{Term: []int{1}, Period: []string{"月1", "木4", "月2"}},
+			want: []*CourseEvent{
+				{
+					Term: 1, Day: "月", FirstPeriod: 1, LastPeriod: 2,
+					Start: at(time.April, 12, 8, 40), End: at(time.April, 12, 11, 25), Last: at(time.May, 17, 8, 40),
+					Exceptions: []time.Time{at(time.May, 3, 8, 40), at(time.May, 17, 8, 40)},
+					Additions:  []time.Time{at(time.May, 19, 8, 40)},
+				},
+				{
+					Term: 1, Day: "木", FirstPeriod: 4, LastPeriod: 4,
+					Start: at(time.April, 15, 13, 45), End: at(time.April, 15, 15, 0), Last: at(time.May, 13, 13, 45),
+					Exceptions: []time.Time{at(time.April, 29, 13, 45)},
+					Additions:  []time.Time{},
+				},
+			},
+		},
+		{
+			name:   "学年暦に無い開講時期と時限、毎週決まったコマではない曜時限は含めない",
+			course: &Course{Term: []int{10}, Period: []string{"月1", "月3", "火6", "集中"}},
+			want: []*CourseEvent{
+				{
+					Term: 1, Day: "月", FirstPeriod: 1, LastPeriod: 1,
+					Start: at(time.April, 12, 8, 40), End: at(time.April, 12, 9, 55), Last: at(time.May, 17, 8, 40),
+					Exceptions: []time.Time{at(time.May, 3, 8, 40), at(time.May, 17, 8, 40)},
+					Additions:  []time.Time{at(time.May, 19, 8, 40)},
+				},
+			},
+		},
+		{
+			name:   "振替授業日で授業を行わなくなる曜日",
+			course: &Course{Term: []int{1}, Period: []string{"水2"}},
+			want: []*CourseEvent{
+				{
+					Term: 1, Day: "水", FirstPeriod: 2, LastPeriod: 2,
+					Start: at(time.April, 14, 10, 10), End: at(time.April, 14, 11, 25), Last: at(time.May, 19, 10, 10),
+					Exceptions: []time.Time{at(time.May, 5, 10, 10), at(time.May, 19, 10, 10)},
+					Additions:  []time.Time{},
+				},
+			},
+		},
+		{
+			name:   "授業期間に授業のある日が無い",
+			course: &Course{Term: []int{7, 8}, Period: []string{"月1"}},
+			want:   []*CourseEvent{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, event := range tt.want {
+				event.Course = tt.course
+			}
+			got := CourseEvents(tt.course, calendar)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CourseEvents() mismatch:")
+				for _, event := range got {
+					t.Errorf("got: %+v", *event)
+				}
+				for _, event := range tt.want {
+					t.Errorf("want: %+v", *event)
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,14 @@
 package domain
 
-import "time"
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
 
 type Course struct {
 	ID                       int
@@ -26,29 +34,213 @@ type Course struct {
 	UpdatedAt                time.Time
 }
 
+// 文字列の検索条件は、全角・半角、大文字・小文字、ひらがな・カタカナ、ローマ数字・アラビア数字を区別しない
 type CourseQuery struct {
 	CourseNumber string `json:"course_number"`
 	// スペース区切り
 	// CourseNameFilterType も指定する
-	CourseName               string `json:"course_name"`
-	InstructionalType        int    `json:"instructional_type"`
-	Credits                  string `json:"credits"`
-	StandardRegistrationYear int    `json:"standard_registration_year"`
-	Term                     string `json:"term"`
+	CourseName string `json:"course_name"`
+	// 0 から 8 の数値、またはその配列
+	// いずれかに一致する科目を検索する
+	InstructionalType MultiValue `json:"instructional_type"`
+	// "2.0" のような単一の値、または "1.0-2.0" のような範囲
+	// util.ParseCreditsRange で認識できる形式であれば良い
+	Credits string `json:"credits"`
+	// "?" または 1 から 6 の値、またはその配列
+	// いずれかを標準履修年次に含む科目を検索する
+	StandardRegistrationYear MultiValue `json:"standard_registration_year"`
+	Term                     string     `json:"term"`
 	// csv2sql/kdb の PeriodParser で認識できる形式であれば良い
-	Period     string `json:"period"`
-	Classroom  string `json:"classroom"`
+	Period string `json:"period"`
+	// Period の一致のさせ方、MatchMode から始まる定数のいずれか
+	// 省略時は MatchModeContainedIn
+	// 検索式・検索条件の木で一致のさせ方を指定していない period にも適用する
+	PeriodMatchMode string `json:"period_match_mode"`
+	// Term の一致のさせ方、PeriodMatchMode と同様
+	TermMatchMode string `json:"term_match_mode"`
+	// スペース区切り、建物・教室の前方一致
+	// ClassroomFilterType も指定する
+	Classroom string `json:"classroom"`
+	// スペース区切り
+	// InstructorFilterType も指定する
 	Instructor string `json:"instructor"`
 	// スペース区切り
 	// CourseOverviewFilterType も指定する
-	CourseOverview           string `json:"course_overview"`
+	CourseOverview string `json:"course_overview"`
+	// スペース区切り
+	// RemarksFilterType も指定する
 	Remarks                  string `json:"remarks"`
 	CourseNameFilterType     string `json:"course_name_filter_type"`
 	CourseOverviewFilterType string `json:"course_overview_filter_type"`
-	FilterType               string `json:"filter_type"`
-	// 必須
+	InstructorFilterType     string `json:"instructor_filter_type"`
+	ClassroomFilterType      string `json:"classroom_filter_type"`
+	RemarksFilterType        string `json:"remarks_filter_type"`
+	// 省略時は CourseOverviewFilterType
+	CourseNumberFilterType string `json:"course_number_filter_type"`
+	FilterType             string `json:"filter_type"`
+	// 除外する条件、FilterType に関わらず他の条件と and でつなぐ
+	// PeriodParser で認識できる形式、いずれかの曜時限がある科目を除く
+	ExcludePeriod string `json:"exclude_period"`
+	// TermParser で認識できる形式、いずれかの開講時期に授業がある科目を除く
+	// TermsOverlapping で、春A なら通年・春学期の科目も除く
+	ExcludeTerm string `json:"exclude_term"`
+	// 0 から 8 の数値、またはその配列
+	// いずれかに一致する授業方法の科目を除く
+	ExcludeInstructionalType MultiValue `json:"exclude_instructional_type"`
+	// スペース区切り、いずれかのキーワードを備考に含む科目を除く
+	ExcludeRemarks string `json:"exclude_remarks"`
+	// 年度、またはその配列
+	// YearLatest は登録されている最新の年度を表す
+	// 省略時は最新の年度のみを検索する
+	Year MultiValue `json:"year"`
+	// スペース区切り
+	// 科目名・英語の科目名・授業概要・備考のいずれかにすべてのキーワードを含む科目を検索する
+	// SearchMode によって一致のさせ方が変わる
+	Keyword string `json:"keyword"`
+	// SearchModeLike または SearchModeFulltext
+	// 省略時は SearchModeLike
+	SearchMode string `json:"search_mode"`
+	// 検索式、ParseQuery で解釈できる形式
+	// 他の条件とは FilterType に関わらず and でつなぐ
+	// 年度は Year で指定する
+	Q string `json:"q"`
+	// 検索条件の木、Filter の JSON 表現
+	// 他の条件とは FilterType に関わらず and でつなぐ
+	// 年度は Year で指定する
+	Filter *Filter `json:"filter"`
+	// 埋まっているコマ、/course/fits で CourseFitsQuery から求める
+	// いずれかと重なるコマがある科目を除く
+	Occupied []TimetableCell `json:"-"`
+	// 必須、0 なら科目を返さずに件数と集計だけを返す
 	Limit  int `json:"limit"`
 	Offset int `json:"offset"`
+	// 並び順、先頭ほど優先される
+	// 省略時は登録順、ただし SearchModeFulltext でキーワードがあれば関連度順
+	Sort []CourseSort `json:"sort"`
+	// 集計の種類、FacetKind から始まる定数のリスト
+	// 指定すると、/facet では term_facet, period_facet に加えて、/course では検索結果と合わせて集計を返す
+	Facets []string `json:"facets"`
+	// 前回の検索結果の NextCursor
+	// 指定するとその続きから検索する (offset はカーソルの位置から数える)
+	Cursor string `json:"cursor"`
+}
+
+type CourseSort struct {
+	// SortKey から始まる定数のいずれか
+	Key string `json:"key"`
+	// SortOrderAsc または SortOrderDesc
+	// 省略時は昇順、ただし関連度は降順
+	Order string `json:"order"`
+}
+
+const (
+	SortKeyCourseNumber             = "course_number"
+	SortKeyCourseName               = "course_name"
+	SortKeyCredits                  = "credits"
+	SortKeyStandardRegistrationYear = "standard_registration_year"
+	// 週の最初の授業の曜時限
+	SortKeyPeriod = "period"
+	// CourseName, CourseOverview, Keyword のキーワードに対する関連度
+	SortKeyRelevance = "relevance"
+)
+
+const (
+	// これまで通り、それぞれのカラムに対してキーワードを部分一致させる
+	SearchModeLike = "like"
+	// Keyword を全文検索する
+	// FulltextMinKeywordLength より短いキーワードは無視する
+	// 関連度は科目名、英語の科目名、授業概要、備考の順に重み付けする
+	SearchModeFulltext = "fulltext"
+)
+
+// SearchModeFulltext で検索に使うキーワードの最小の文字数
+// 1 文字のキーワードはほとんどの科目に一致してしまうため
+// 2 文字以上のキーワードは連続する 2 文字の組のインデックスで絞り込める
+const FulltextMinKeywordLength = 2
+
+const (
+	SortOrderAsc  = "asc"
+	SortOrderDesc = "desc"
+)
+
+// 曜時限・開講時期の一致のさせ方
+// 指定した値の集合と科目の曜時限 (開講時期) の集合を比べる
+const (
+	// 科目のすべての曜時限が指定した値に含まれる (空き時間に収まる科目を探す)
+	MatchModeContainedIn = "contained_in"
+	// 科目の曜時限のいずれかが指定した値に含まれる
+	MatchModeOverlaps = "overlaps"
+	// 指定した値のすべてが科目の曜時限に含まれる
+	MatchModeContainsAll = "contains_all"
+	// 科目の曜時限と指定した値が (重複を除いて) 一致する
+	MatchModeExact = "exact"
+)
+
+// MatchMode から始まる定数の一覧
+var MatchModes = []string{MatchModeContainedIn, MatchModeOverlaps, MatchModeContainsAll, MatchModeExact}
+
+// CourseQuery.Year で最新の年度を表す値
+const YearLatest = "latest"
+
+// 単一の値 (数値・文字列) またはそれらの配列を受け付ける
+// 以前は数値のみを受け付けており -1 を未指定としていたので、単一の負の数は未指定として扱う
+type MultiValue []string
+
+func (v *MultiValue) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var raw interface{}
+	err := decoder.Decode(&raw)
+	if err != nil {
+		return err
+	}
+
+	var items []interface{}
+	switch r := raw.(type) {
+	case nil:
+		*v = nil
+		return nil
+	case json.Number:
+		if strings.HasPrefix(r.String(), "-") {
+			*v = nil
+			return nil
+		}
+		items = []interface{}{r}
+	case []interface{}:
+		items = r
+	default:
+		items = []interface{}{r}
+	}
+
+	var values MultiValue
+	for _, item := range items {
+		switch i := item.(type) {
+		case json.Number:
+			values = append(values, i.String())
+		case string:
+			if i != "" {
+				values = append(values, i)
+			}
+		default:
+			return fmt.Errorf("unsupported value: %v", item)
+		}
+	}
+	*v = values
+	return nil
+}
+
+type CourseSearchResult struct {
+	Courses []*Course
+	// limit, offset を適用する前の該当する科目数
+	Total int
+	// 続きのページがあるか
+	HasNext bool
+	// 続きのページを取得するためのカーソル
+	// 続きのページが無い場合は空文字列
+	NextCursor string
+	// CourseQuery.Facets を指定した場合の集計の種類ごとの集計、/facet の facets と同じく集計の対象のカラムに対する条件は取り除いて数える
+	// 指定していなければ nil
+	Facets map[string][]*FacetValue
 }
 
 type Facet struct {
@@ -56,7 +248,153 @@ type Facet struct {
 	TermCount int
 }
 
+// 曜時限ごとの該当する科目数
+type PeriodFacet struct {
+	// "月1" や "集中" など
+	Period string
+	Count  int
+}
+
+// CourseQuery.Facets で要求された集計の値 1 つとそれに該当する科目数
+type FacetValue struct {
+	// 開講時期なら "4"、曜時限なら "月1" のように集計の種類に応じた値
+	Value string
+	Count int
+}
+
+// 集計の種類
+const (
+	FacetKindTerm                     = "term"
+	FacetKindPeriod                   = "period"
+	FacetKindInstructionalType        = "instructional_type"
+	FacetKindCredits                  = "credits"
+	FacetKindStandardRegistrationYear = "standard_registration_year"
+	// 科目番号の先頭 2 文字 (GA, GB など)
+	FacetKindDepartment = "department"
+	// 該当する科目の多い順に FacetInstructorLimit 人まで
+	FacetKindInstructor = "instructor"
+)
+
+// FacetKind から始まる定数の一覧
+var FacetKinds = []string{
+	FacetKindTerm,
+	FacetKindPeriod,
+	FacetKindInstructionalType,
+	FacetKindCredits,
+	FacetKindStandardRegistrationYear,
+	FacetKindDepartment,
+	FacetKindInstructor,
+}
+
+// 担当教員の集計で返す人数
+const FacetInstructorLimit = 10
+
+// 集計の種類ごとの、集計の対象のカラムの検索条件の木の葉
+var facetFilterFields = map[string]string{
+	FacetKindTerm:                     FilterFieldTerm,
+	FacetKindPeriod:                   FilterFieldPeriod,
+	FacetKindInstructionalType:        FilterFieldInstructionalType,
+	FacetKindCredits:                  FilterFieldCredits,
+	FacetKindStandardRegistrationYear: FilterFieldStandardRegistrationYear,
+	FacetKindDepartment:               FilterFieldCourseNumber,
+	FacetKindInstructor:               FilterFieldInstructor,
+}
+
+// kind の集計に使う検索条件
+// 集計の対象のカラムに対する条件だけを取り除き、他の条件は適用する
+// 複数選択できる絞り込みで、選んでいない値の件数も示せるようにするため
+// カラムごとの条件、除外する条件に加えて、検索式・検索条件の木からも Filter.WithoutField で取り除く
+// 検索式は検索条件の木にして Filter とまとめるので、検索式が不正ならエラー
+func (q CourseQuery) WithoutFacetFilter(kind string) (CourseQuery, error) {
+	switch kind {
+	case FacetKindTerm:
+		q.Term = ""
+		q.ExcludeTerm = ""
+	case FacetKindPeriod:
+		q.Period = ""
+		q.ExcludePeriod = ""
+	case FacetKindInstructionalType:
+		q.InstructionalType = nil
+		q.ExcludeInstructionalType = nil
+	case FacetKindCredits:
+		q.Credits = ""
+	case FacetKindStandardRegistrationYear:
+		q.StandardRegistrationYear = nil
+	case FacetKindDepartment:
+		q.CourseNumber = ""
+	case FacetKindInstructor:
+		q.Instructor = ""
+	}
+
+	filters := []*Filter{}
+	if q.Q != "" {
+		filter, err := ParseQuery(q.Q)
+		if err != nil {
+			return q, err
+		}
+		filters = append(filters, filter)
+	}
+	if q.Filter != nil {
+		filters = append(filters, q.Filter)
+	}
+	remaining := []*Filter{}
+	for _, filter := range filters {
+		if f := filter.WithoutField(facetFilterFields[kind]); f != nil {
+			remaining = append(remaining, f)
+		}
+	}
+
+	q.Q = ""
+	switch len(remaining) {
+	case 0:
+		q.Filter = nil
+	case 1:
+		q.Filter = remaining[0]
+	default:
+		q.Filter = &Filter{And: remaining}
+	}
+	return q, nil
+}
+
+// 曜時限ごとの集計で、該当する科目が無くても 0 として返す曜時限
+// 月1 から金6 と集中・応談・随時
+var PeriodFacetPeriods = func() []string {
+	periods := []string{}
+	for _, day := range []string{"月", "火", "水", "木", "金"} {
+		for i := 1; i <= 6; i++ {
+			periods = append(periods, fmt.Sprintf("%s%d", day, i))
+		}
+	}
+	return append(periods, "集中", "応談", "随時")
+}()
+
+// 登録されている年度とその年度の科目数
+type AcademicYear struct {
+	Year        int
+	CourseCount int
+}
+
+// FindByID, FindByNumber で科目が見つからなかった
+var ErrCourseNotFound = errors.New("course not found")
+
 type CourseRepository interface {
-	Search(CourseQuery) ([]*Course, error)
+	Search(CourseQuery) (*CourseSearchResult, error)
+	// 検索条件に該当するすべての科目を検索結果と同じ順に 1 つずつ fn に渡す
+	// limit, offset, カーソルは無視する
+	// fn がエラーを返すか ctx が終了するとそこで止め、そのエラーを返す
+	Stream(ctx context.Context, query CourseQuery, fn func(*Course) error) error
 	Facet(CourseQuery) ([]*Facet, error)
+	PeriodFacet(CourseQuery) ([]*PeriodFacet, error)
+	// kind の集計、FacetKind から始まる定数のいずれか
+	FacetValues(query CourseQuery, kind string) ([]*FacetValue, error)
+	Years() ([]*AcademicYear, error)
+	// 科目番号が一致する科目を探す、年度は CourseQuery.Year と同様に指定する
+	// 見つからなかった科目番号は結果に含まれない
+	FindByNumbers(numbers []string, years []string) ([]*Course, error)
+	// 科目コードが一致する科目を探す、FindByNumbers と同様
+	FindByCodes(codes []string, years []string) ([]*Course, error)
+	// 見つからなければ ErrCourseNotFound
+	FindByID(id int) (*Course, error)
+	// 年度と科目番号が完全に一致する科目、見つからなければ ErrCourseNotFound
+	FindByNumber(year int, number string) (*Course, error)
 }
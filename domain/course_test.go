@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCourseQuery_WithoutFacetFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   CourseQuery
+		kind    string
+		want    CourseQuery
+		wantErr bool
+	}{
+		{
+			name: "カラムごとの条件と除外する条件を取り除く",
+			query: CourseQuery{
+				Term:        "秋A",
+				ExcludeTerm: "春A",
+				Period:      "月1",
+				FilterType:  "and",
+			},
+			kind: FacetKindTerm,
+			want: CourseQuery{
+				Period:     "月1",
+				FilterType: "and",
+			},
+		},
+		{
+			name: "検索式は検索条件の木にして Filter とまとめてから取り除く",
+			query: CourseQuery{
+				Q:      "情報 term:秋A",
+				Filter: &Filter{Or: []*Filter{{Field: FilterFieldTerm, Value: MultiValue{"春A"}}, {Field: FilterFieldPeriod, Value: MultiValue{"月1"}}}},
+			},
+			kind: FacetKindTerm,
+			want: CourseQuery{
+				Filter: &Filter{And: []*Filter{{Field: FilterFieldKeyword, Value: MultiValue{"情報"}, Position: 1}}, Position: 1},
+			},
+		},
+		{
+			name: "科目番号の先頭 2 文字の集計は科目番号の条件を取り除く",
+			query: CourseQuery{
+				CourseNumber: "GB",
+				Filter:       &Filter{Not: &Filter{Field: FilterFieldCourseNumber, Value: MultiValue{"GB1"}}},
+			},
+			kind: FacetKindDepartment,
+			want: CourseQuery{},
+		},
+		{
+			name: "不正な検索式",
+			query: CourseQuery{
+				Q: "(情報",
+			},
+			kind:    FacetKindTerm,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.query.WithoutFacetFilter(tt.kind)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CourseQuery.WithoutFacetFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("CourseQuery.WithoutFacetFilter() mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
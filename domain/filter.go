@@ -0,0 +1,266 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// 検索条件の木
+// And, Or, Not のいずれか 1 つを持つ節か、Field と Value を持つ葉のどちらか
+//
+// JSON では次のように、節は and, or, not のいずれか、葉はカラム名をキーとする 1 つのキーだけを持つオブジェクトで表す
+// {"and": [{"or": [{"course_name": "情報"}, {"course_overview": "情報"}]}, {"term": "秋A"}, {"not": {"period": "月1"}}]}
+// period, term の葉は {"period": "月1", "match_mode": "overlaps"} のように一致のさせ方も指定できる
+type Filter struct {
+	// すべての子に一致する
+	And []*Filter
+	// いずれかの子に一致する
+	Or []*Filter
+	// 子に一致しない
+	Not *Filter
+
+	// FilterField から始まる定数のいずれか
+	Field string
+	// 複数の値を指定するといずれかに一致する
+	Value MultiValue
+	// period, term の葉の一致のさせ方、MatchMode から始まる定数のいずれか
+	// 空文字列なら CourseQuery.PeriodMatchMode (TermMatchMode) に従う
+	MatchMode string
+	// 検索式の中での位置 (1 文字目が 1)
+	// エラーの位置を示すのに使う、JSON で与えられた場合は 0
+	Position int
+}
+
+// 葉で指定できるカラム
+const (
+	// 科目名・英語の科目名・授業概要・備考のいずれか (CourseQuery.Keyword と同じ)
+	FilterFieldKeyword                  = "keyword"
+	FilterFieldCourseNumber             = "course_number"
+	FilterFieldCourseName               = "course_name"
+	FilterFieldAltCourseName            = "alt_course_name"
+	FilterFieldInstructionalType        = "instructional_type"
+	FilterFieldCredits                  = "credits"
+	FilterFieldStandardRegistrationYear = "standard_registration_year"
+	FilterFieldTerm                     = "term"
+	FilterFieldPeriod                   = "period"
+	FilterFieldClassroom                = "classroom"
+	FilterFieldInstructor               = "instructor"
+	FilterFieldCourseOverview           = "course_overview"
+	FilterFieldRemarks                  = "remarks"
+)
+
+// 葉で指定できるカラムの一覧
+var FilterFields = []string{
+	FilterFieldKeyword,
+	FilterFieldCourseNumber,
+	FilterFieldCourseName,
+	FilterFieldAltCourseName,
+	FilterFieldInstructionalType,
+	FilterFieldCredits,
+	FilterFieldStandardRegistrationYear,
+	FilterFieldTerm,
+	FilterFieldPeriod,
+	FilterFieldClassroom,
+	FilterFieldInstructor,
+	FilterFieldCourseOverview,
+	FilterFieldRemarks,
+}
+
+// 検索条件の木の深さの上限
+// 葉だけの木の深さを 1 とする
+const FilterMaxDepth = 8
+
+// 深さが FilterMaxDepth を超えたところで、残りの子を読まずにエラーにする
+func (f *Filter) UnmarshalJSON(data []byte) error {
+	return f.unmarshalJSON(data, 1)
+}
+
+// depth はこの節の深さ、根を 1 とする
+func (f *Filter) unmarshalJSON(data []byte, depth int) error {
+	if depth > FilterMaxDepth {
+		return fmt.Errorf("filter is nested too deeply, up to %d", FilterMaxDepth)
+	}
+
+	var object map[string]json.RawMessage
+	err := json.Unmarshal(data, &object)
+	if err != nil {
+		return err
+	}
+
+	matchMode := ""
+	if raw, ok := object["match_mode"]; ok {
+		err := json.Unmarshal(raw, &matchMode)
+		if err != nil {
+			return err
+		}
+		delete(object, "match_mode")
+		_, isPeriod := object[FilterFieldPeriod]
+		_, isTerm := object[FilterFieldTerm]
+		if len(object) != 1 || !(isPeriod || isTerm) {
+			return errors.New("'match_mode' is only allowed with 'period' or 'term'")
+		}
+	}
+	if len(object) != 1 {
+		return fmt.Errorf("filter must have exactly one key: %s", string(data))
+	}
+
+	for key, raw := range object {
+		switch key {
+		case "and", "or":
+			var raws []json.RawMessage
+			err := json.Unmarshal(raw, &raws)
+			if err != nil {
+				return err
+			}
+			if len(raws) == 0 {
+				return fmt.Errorf("'%s' must not be empty", key)
+			}
+			children := []*Filter{}
+			for _, r := range raws {
+				if isJSONNull(r) {
+					return fmt.Errorf("'%s' must not contain null", key)
+				}
+				child := &Filter{}
+				err := child.unmarshalJSON(r, depth+1)
+				if err != nil {
+					return err
+				}
+				children = append(children, child)
+			}
+			if key == "and" {
+				*f = Filter{And: children}
+			} else {
+				*f = Filter{Or: children}
+			}
+		case "not":
+			if isJSONNull(raw) {
+				return errors.New("'not' must not be null")
+			}
+			child := &Filter{}
+			err := child.unmarshalJSON(raw, depth+1)
+			if err != nil {
+				return err
+			}
+			*f = Filter{Not: child}
+		default:
+			if !isFilterField(key) {
+				return fmt.Errorf("unknown filter key: %s", key)
+			}
+			var value MultiValue
+			err := json.Unmarshal(raw, &value)
+			if err != nil {
+				return err
+			}
+			if len(value) == 0 {
+				return fmt.Errorf("value for '%s' is empty", key)
+			}
+			*f = Filter{Field: key, Value: value, MatchMode: matchMode}
+		}
+	}
+	return nil
+}
+
+func isJSONNull(raw json.RawMessage) bool {
+	return string(bytes.TrimSpace(raw)) == "null"
+}
+
+func isFilterField(name string) bool {
+	for _, field := range FilterFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Filter) IsLeaf() bool {
+	return len(f.And) == 0 && len(f.Or) == 0 && f.Not == nil
+}
+
+func (f *Filter) children() []*Filter {
+	children := append([]*Filter{}, f.And...)
+	children = append(children, f.Or...)
+	if f.Not != nil {
+		children = append(children, f.Not)
+	}
+	return children
+}
+
+// field の葉を取り除いた木を返す、与えられた木は書き換えない
+// 取り除いた葉は常に一致するものとして扱うので、それを子に持つ or は全体を取り除く
+// not はその下に field の葉があれば全体を取り除く (除外する条件と同じく、条件そのものを無かったことにする)
+// 何も残らなければ nil
+func (f *Filter) WithoutField(field string) *Filter {
+	switch {
+	case f.IsLeaf():
+		if f.Field == field {
+			return nil
+		}
+		return f
+	case f.Not != nil:
+		if f.Not.hasField(field) {
+			return nil
+		}
+		return f
+	case len(f.Or) != 0:
+		children := []*Filter{}
+		for _, child := range f.Or {
+			c := child.WithoutField(field)
+			if c == nil {
+				return nil
+			}
+			children = append(children, c)
+		}
+		return &Filter{Or: children, Position: f.Position}
+	default:
+		children := []*Filter{}
+		for _, child := range f.And {
+			if c := child.WithoutField(field); c != nil {
+				children = append(children, c)
+			}
+		}
+		if len(children) == 0 {
+			return nil
+		}
+		return &Filter{And: children, Position: f.Position}
+	}
+}
+
+func (f *Filter) hasField(field string) bool {
+	if f.IsLeaf() {
+		return f.Field == field
+	}
+	for _, child := range f.children() {
+		if child.hasField(field) {
+			return true
+		}
+	}
+	return false
+}
+
+// 木の深さ
+func (f *Filter) Depth() int {
+	depth := 0
+	for _, child := range f.children() {
+		if d := child.Depth(); d > depth {
+			depth = d
+		}
+	}
+	return depth + 1
+}
+
+// 葉を順にたどる
+func (f *Filter) Walk(fn func(leaf *Filter) error) error {
+	if f.IsLeaf() {
+		return fn(f)
+	}
+	for _, child := range f.children() {
+		err := child.Walk(fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
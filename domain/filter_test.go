@@ -0,0 +1,249 @@
+package domain
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFilter_UnmarshalJSON(t *testing.T) {
+	type args struct {
+		data string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *Filter
+		wantErr bool
+	}{
+		{
+			name: "and, or, not と葉",
+			args: args{
+				data: `{"and": [{"or": [{"course_name": "情報"}, {"course_overview": "情報"}]}, {"term": "秋A"}, {"not": {"period": "月1"}}]}`,
+			},
+			want: &Filter{
+				And: []*Filter{
+					{
+						Or: []*Filter{
+							{Field: FilterFieldCourseName, Value: MultiValue{"情報"}},
+							{Field: FilterFieldCourseOverview, Value: MultiValue{"情報"}},
+						},
+					},
+					{Field: FilterFieldTerm, Value: MultiValue{"秋A"}},
+					{Not: &Filter{Field: FilterFieldPeriod, Value: MultiValue{"月1"}}},
+				},
+			},
+		},
+		{
+			name: "列挙型のカラムは数値や配列も受け付ける",
+			args: args{
+				data: `{"or": [{"instructional_type": [1, 2]}, {"standard_registration_year": 3}]}`,
+			},
+			want: &Filter{
+				Or: []*Filter{
+					{Field: FilterFieldInstructionalType, Value: MultiValue{"1", "2"}},
+					{Field: FilterFieldStandardRegistrationYear, Value: MultiValue{"3"}},
+				},
+			},
+		},
+		{
+			name: "period, term は一致のさせ方を指定できる",
+			args: args{
+				data: `{"and": [{"period": "月3", "match_mode": "overlaps"}, {"match_mode": "exact", "term": "秋AB"}]}`,
+			},
+			want: &Filter{
+				And: []*Filter{
+					{Field: FilterFieldPeriod, Value: MultiValue{"月3"}, MatchMode: MatchModeOverlaps},
+					{Field: FilterFieldTerm, Value: MultiValue{"秋AB"}, MatchMode: MatchModeExact},
+				},
+			},
+		},
+		{
+			name: "period, term 以外に一致のさせ方を指定する",
+			args: args{
+				data: `{"course_name": "情報", "match_mode": "overlaps"}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "一致のさせ方だけ",
+			args: args{
+				data: `{"match_mode": "overlaps"}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "キーが 2 つ",
+			args: args{
+				data: `{"course_name": "情報", "term": "秋A"}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "キーが無い",
+			args: args{
+				data: `{}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "知らないキー",
+			args: args{
+				data: `{"teacher": "山田"}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "空の and",
+			args: args{
+				data: `{"and": []}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "not が null",
+			args: args{
+				data: `{"not": null}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "値が空",
+			args: args{
+				data: `{"course_name": ""}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "値がオブジェクト",
+			args: args{
+				data: `{"course_name": {"contains": "情報"}}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "深さが上限ちょうど",
+			args: args{
+				data: strings.Repeat(`{"not": `, FilterMaxDepth-1) + `{"course_name": "情報"}` + strings.Repeat(`}`, FilterMaxDepth-1),
+			},
+			want: func() *Filter {
+				filter := &Filter{Field: FilterFieldCourseName, Value: MultiValue{"情報"}}
+				for i := 0; i < FilterMaxDepth-1; i++ {
+					filter = &Filter{Not: filter}
+				}
+				return filter
+			}(),
+		},
+		{
+			name: "深さが上限を超える",
+			args: args{
+				data: `{"and": [` + strings.Repeat(`{"or": [`, FilterMaxDepth) + `{"course_name": "情報"}` + strings.Repeat(`]}`, FilterMaxDepth) + `]}`,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := &Filter{}
+			err := json.Unmarshal([]byte(tt.args.data), got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Filter.UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("Filter.UnmarshalJSON() mismatch: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFilter_Depth(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter *Filter
+		want   int
+	}{
+		{
+			name:   "葉",
+			filter: &Filter{Field: FilterFieldCourseName, Value: MultiValue{"情報"}},
+			want:   1,
+		},
+		{
+			name: "最も深い子に合わせる",
+			filter: &Filter{
+				And: []*Filter{
+					{Field: FilterFieldCourseName, Value: MultiValue{"情報"}},
+					{Not: &Filter{Or: []*Filter{{Field: FilterFieldTerm, Value: MultiValue{"秋A"}}}}},
+				},
+			},
+			want: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Depth(); got != tt.want {
+				t.Errorf("Filter.Depth() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_WithoutField(t *testing.T) {
+	name := &Filter{Field: FilterFieldCourseName, Value: MultiValue{"情報"}}
+	term := &Filter{Field: FilterFieldTerm, Value: MultiValue{"秋A"}}
+	period := &Filter{Field: FilterFieldPeriod, Value: MultiValue{"月1"}}
+
+	tests := []struct {
+		name   string
+		filter *Filter
+		want   *Filter
+	}{
+		{
+			name:   "他のカラムの葉はそのまま",
+			filter: name,
+			want:   name,
+		},
+		{
+			name:   "葉だけなら何も残らない",
+			filter: term,
+			want:   nil,
+		},
+		{
+			name:   "and からは葉だけを取り除く",
+			filter: &Filter{And: []*Filter{name, term, period}},
+			want:   &Filter{And: []*Filter{name, period}},
+		},
+		{
+			name:   "葉を子に持つ or は全体を取り除く",
+			filter: &Filter{And: []*Filter{name, {Or: []*Filter{term, period}}}},
+			want:   &Filter{And: []*Filter{name}},
+		},
+		{
+			name:   "or の子の and から取り除く",
+			filter: &Filter{Or: []*Filter{{And: []*Filter{term, period}}, name}},
+			want:   &Filter{Or: []*Filter{{And: []*Filter{period}}, name}},
+		},
+		{
+			name:   "葉を含む not は全体を取り除く",
+			filter: &Filter{And: []*Filter{name, {Not: &Filter{And: []*Filter{term, period}}}}},
+			want:   &Filter{And: []*Filter{name}},
+		},
+		{
+			name:   "葉を含まない not はそのまま",
+			filter: &Filter{Not: period},
+			want:   &Filter{Not: period},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.filter.WithoutField(FilterFieldTerm)
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("Filter.WithoutField() mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,307 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// 検索式の構文エラー
+type QueryParseError struct {
+	// 検索式の中での位置 (1 文字目が 1)
+	Position int
+	Message  string
+}
+
+func (e *QueryParseError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Message, e.Position)
+}
+
+// 検索式の field: で使える名前
+// FilterFields に加えて、よく使うものは短い名前も受け付ける
+var queryFieldAliases = map[string]string{
+	"number":   FilterFieldCourseNumber,
+	"name":     FilterFieldCourseName,
+	"type":     FilterFieldInstructionalType,
+	"grade":    FilterFieldStandardRegistrationYear,
+	"room":     FilterFieldClassroom,
+	"overview": FilterFieldCourseOverview,
+}
+
+// 検索式 (CourseQuery.Q) を Filter に変換する
+// 例: "線形代数" -演習 instructor:山田 (period:月1 OR period:月2)
+// 空白で区切った語はすべてに一致するもの、OR で区切った語はいずれかに一致するもの (and より優先順位が低い)
+// 括弧でまとめられ、先頭に - を付けるとそれに一致しないものになる
+// "" で囲むと空白を含む語句になる
+// field:value で検索するカラムを指定する、省略すると FilterFieldKeyword
+// 括弧・引用符・コロンは全角のものも受け付ける
+func ParseQuery(query string) (*Filter, error) {
+	p := &queryParser{
+		tokens: tokenizeQuery([]rune(query)),
+	}
+	if p.peek().kind == queryTokenEOF {
+		return nil, &QueryParseError{Position: 1, Message: "query is empty"}
+	}
+
+	filter, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token.kind != queryTokenEOF {
+		return nil, &QueryParseError{Position: token.position, Message: fmt.Sprintf("unexpected %q", token.text)}
+	}
+	return filter, nil
+}
+
+type queryTokenKind int
+
+const (
+	queryTokenEOF queryTokenKind = iota
+	queryTokenWord
+	// 引用符で囲まれた語句
+	queryTokenPhrase
+	queryTokenOr
+	queryTokenNot
+	queryTokenOpen
+	queryTokenClose
+	// 閉じられていない引用符
+	queryTokenInvalid
+)
+
+type queryToken struct {
+	kind queryTokenKind
+	// 語句は引用符を取り除いたもの
+	text     string
+	position int
+	// field:"value" のように、語句の前に field: がある
+	field         string
+	fieldPosition int
+}
+
+func isQueryOpen(r rune) bool {
+	return r == '(' || r == '（'
+}
+
+func isQueryClose(r rune) bool {
+	return r == ')' || r == '）'
+}
+
+func isQueryQuote(r rune) bool {
+	return r == '"' || r == '“' || r == '”' || r == '＂'
+}
+
+func isQueryColon(r rune) bool {
+	return r == ':' || r == '：'
+}
+
+func tokenizeQuery(query []rune) []queryToken {
+	tokens := []queryToken{}
+	i := 0
+	for i < len(query) {
+		r := query[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case isQueryOpen(r):
+			tokens = append(tokens, queryToken{kind: queryTokenOpen, text: string(r), position: i + 1})
+			i++
+		case isQueryClose(r):
+			tokens = append(tokens, queryToken{kind: queryTokenClose, text: string(r), position: i + 1})
+			i++
+		case r == '-' && i+1 < len(query) && !unicode.IsSpace(query[i+1]):
+			tokens = append(tokens, queryToken{kind: queryTokenNot, text: "-", position: i + 1})
+			i++
+		case isQueryQuote(r):
+			token, next := tokenizeQueryPhrase(query, i)
+			tokens = append(tokens, token)
+			i = next
+		default:
+			start := i
+			for i < len(query) && !unicode.IsSpace(query[i]) && !isQueryOpen(query[i]) && !isQueryClose(query[i]) && !isQueryQuote(query[i]) {
+				i++
+			}
+			word := string(query[start:i])
+
+			// field:"value"
+			if i < len(query) && isQueryQuote(query[i]) && i > start && isQueryColon(query[i-1]) {
+				token, next := tokenizeQueryPhrase(query, i)
+				token.field = string(query[start : i-1])
+				token.fieldPosition = start + 1
+				tokens = append(tokens, token)
+				i = next
+				continue
+			}
+
+			kind := queryTokenWord
+			if word == "OR" {
+				kind = queryTokenOr
+			}
+			tokens = append(tokens, queryToken{kind: kind, text: word, position: start + 1})
+		}
+	}
+	return append(tokens, queryToken{kind: queryTokenEOF, text: "end of query", position: len(query) + 1})
+}
+
+// query[start] の引用符から閉じる引用符までを語句とする
+func tokenizeQueryPhrase(query []rune, start int) (queryToken, int) {
+	end := start + 1
+	for end < len(query) && !isQueryQuote(query[end]) {
+		end++
+	}
+	if end == len(query) {
+		return queryToken{kind: queryTokenInvalid, text: string(query[start:]), position: start + 1}, end
+	}
+	return queryToken{kind: queryTokenPhrase, text: string(query[start+1 : end]), position: start + 1}, end + 1
+}
+
+type queryParser struct {
+	tokens []queryToken
+	index  int
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.index]
+}
+
+func (p *queryParser) next() queryToken {
+	token := p.tokens[p.index]
+	if token.kind != queryTokenEOF {
+		p.index++
+	}
+	return token
+}
+
+// or := and ("OR" and)*
+func (p *queryParser) parseOr() (*Filter, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	filters := []*Filter{first}
+	for p.peek().kind == queryTokenOr {
+		p.next()
+		filter, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	if len(filters) == 1 {
+		return first, nil
+	}
+	return &Filter{Or: filters, Position: first.Position}, nil
+}
+
+// and := unary+
+func (p *queryParser) parseAnd() (*Filter, error) {
+	filters := []*Filter{}
+	for {
+		kind := p.peek().kind
+		if kind == queryTokenEOF || kind == queryTokenOr || kind == queryTokenClose {
+			break
+		}
+		filter, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+
+	if len(filters) == 0 {
+		token := p.peek()
+		return nil, &QueryParseError{Position: token.position, Message: fmt.Sprintf("expected a term before %q", token.text)}
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return &Filter{And: filters, Position: filters[0].Position}, nil
+}
+
+// unary := "-" unary | "(" or ")" | term
+func (p *queryParser) parseUnary() (*Filter, error) {
+	token := p.next()
+	switch token.kind {
+	case queryTokenNot:
+		filter, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Filter{Not: filter, Position: token.position}, nil
+	case queryTokenOpen:
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != queryTokenClose {
+			return nil, &QueryParseError{Position: token.position, Message: "unclosed parenthesis"}
+		}
+		p.next()
+		return filter, nil
+	case queryTokenWord:
+		return parseQueryTerm(token)
+	case queryTokenPhrase:
+		return parseQueryPhrase(token)
+	case queryTokenInvalid:
+		return nil, &QueryParseError{Position: token.position, Message: "unclosed quotation mark"}
+	default:
+		return nil, &QueryParseError{Position: token.position, Message: fmt.Sprintf("unexpected %q", token.text)}
+	}
+}
+
+// word または field:word
+func parseQueryTerm(token queryToken) (*Filter, error) {
+	runes := []rune(token.text)
+	for i, r := range runes {
+		if !isQueryColon(r) {
+			continue
+		}
+		field, err := resolveQueryField(string(runes[:i]), token.position)
+		if err != nil {
+			return nil, err
+		}
+		value := string(runes[i+1:])
+		if value == "" {
+			return nil, &QueryParseError{Position: token.position + i + 1, Message: fmt.Sprintf("value for %q is empty", field)}
+		}
+		return newQueryLeaf(field, value, token.position), nil
+	}
+	return newQueryLeaf(FilterFieldKeyword, token.text, token.position), nil
+}
+
+// "phrase" または field:"phrase"
+func parseQueryPhrase(token queryToken) (*Filter, error) {
+	if strings.TrimSpace(token.text) == "" {
+		return nil, &QueryParseError{Position: token.position, Message: "phrase is empty"}
+	}
+	if token.fieldPosition == 0 {
+		return newQueryLeaf(FilterFieldKeyword, token.text, token.position), nil
+	}
+	field, err := resolveQueryField(token.field, token.fieldPosition)
+	if err != nil {
+		return nil, err
+	}
+	return newQueryLeaf(field, token.text, token.fieldPosition), nil
+}
+
+// 列挙型のカラムは type:1,2 のようにカンマ区切りで複数の値を指定できる
+func newQueryLeaf(field string, value string, position int) *Filter {
+	values := MultiValue{value}
+	if field == FilterFieldInstructionalType || field == FilterFieldStandardRegistrationYear {
+		values = strings.Split(value, ",")
+	}
+	return &Filter{Field: field, Value: values, Position: position}
+}
+
+func resolveQueryField(name string, position int) (string, error) {
+	if name == "" {
+		return "", &QueryParseError{Position: position, Message: "field name is empty"}
+	}
+	if alias, ok := queryFieldAliases[name]; ok {
+		return alias, nil
+	}
+	if isFilterField(name) {
+		return name, nil
+	}
+	return "", &QueryParseError{Position: position, Message: fmt.Sprintf("unknown field %q", name)}
+}
@@ -0,0 +1,197 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseQuery(t *testing.T) {
+	type args struct {
+		query string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *Filter
+		wantErr *QueryParseError
+	}{
+		{
+			name: "語 1 つ",
+			args: args{
+				query: "線形代数",
+			},
+			want: &Filter{Field: FilterFieldKeyword, Value: MultiValue{"線形代数"}, Position: 1},
+		},
+		{
+			name: "語句、除外、カラムの指定",
+			args: args{
+				query: `"線形代数" -演習 instructor:山田 period:月1`,
+			},
+			want: &Filter{
+				And: []*Filter{
+					{Field: FilterFieldKeyword, Value: MultiValue{"線形代数"}, Position: 1},
+					{Not: &Filter{Field: FilterFieldKeyword, Value: MultiValue{"演習"}, Position: 9}, Position: 8},
+					{Field: FilterFieldInstructor, Value: MultiValue{"山田"}, Position: 12},
+					{Field: FilterFieldPeriod, Value: MultiValue{"月1"}, Position: 26},
+				},
+				Position: 1,
+			},
+		},
+		{
+			name: "語句は空白を含められる",
+			args: args{
+				query: `name:"データ構造 アルゴリズム"`,
+			},
+			want: &Filter{Field: FilterFieldCourseName, Value: MultiValue{"データ構造 アルゴリズム"}, Position: 1},
+		},
+		{
+			name: "OR は and より優先順位が低い",
+			args: args{
+				query: "情報 科学 OR 数学",
+			},
+			want: &Filter{
+				Or: []*Filter{
+					{
+						And: []*Filter{
+							{Field: FilterFieldKeyword, Value: MultiValue{"情報"}, Position: 1},
+							{Field: FilterFieldKeyword, Value: MultiValue{"科学"}, Position: 4},
+						},
+						Position: 1,
+					},
+					{Field: FilterFieldKeyword, Value: MultiValue{"数学"}, Position: 10},
+				},
+				Position: 1,
+			},
+		},
+		{
+			name: "括弧と括弧の否定",
+			args: args{
+				query: "-(period:月1 OR period:月2) 統計",
+			},
+			want: &Filter{
+				And: []*Filter{
+					{
+						Not: &Filter{
+							Or: []*Filter{
+								{Field: FilterFieldPeriod, Value: MultiValue{"月1"}, Position: 3},
+								{Field: FilterFieldPeriod, Value: MultiValue{"月2"}, Position: 16},
+							},
+							Position: 3,
+						},
+						Position: 1,
+					},
+					{Field: FilterFieldKeyword, Value: MultiValue{"統計"}, Position: 27},
+				},
+				Position: 1,
+			},
+		},
+		{
+			name: "全角の括弧・コロン・空白",
+			args: args{
+				query: "（instructor：山田　OR　instructor：西出）",
+			},
+			want: &Filter{
+				Or: []*Filter{
+					{Field: FilterFieldInstructor, Value: MultiValue{"山田"}, Position: 2},
+					{Field: FilterFieldInstructor, Value: MultiValue{"西出"}, Position: 19},
+				},
+				Position: 2,
+			},
+		},
+		{
+			name: "短い名前のカラム",
+			args: args{
+				query: "number:GB1 type:1,2 credits:1.0-2.0",
+			},
+			want: &Filter{
+				And: []*Filter{
+					{Field: FilterFieldCourseNumber, Value: MultiValue{"GB1"}, Position: 1},
+					{Field: FilterFieldInstructionalType, Value: MultiValue{"1", "2"}, Position: 12},
+					{Field: FilterFieldCredits, Value: MultiValue{"1.0-2.0"}, Position: 21},
+				},
+				Position: 1,
+			},
+		},
+		{
+			name: "単語の途中の - は除外ではない",
+			args: args{
+				query: "C-言語",
+			},
+			want: &Filter{Field: FilterFieldKeyword, Value: MultiValue{"C-言語"}, Position: 1},
+		},
+		{
+			name: "空",
+			args: args{
+				query: "  ",
+			},
+			wantErr: &QueryParseError{Position: 1, Message: "query is empty"},
+		},
+		{
+			name: "閉じられていない括弧",
+			args: args{
+				query: "情報 (数学 OR 統計",
+			},
+			wantErr: &QueryParseError{Position: 4, Message: "unclosed parenthesis"},
+		},
+		{
+			name: "対応しない閉じ括弧",
+			args: args{
+				query: "情報) 数学",
+			},
+			wantErr: &QueryParseError{Position: 3, Message: `unexpected ")"`},
+		},
+		{
+			name: "空の括弧",
+			args: args{
+				query: "情報 ()",
+			},
+			wantErr: &QueryParseError{Position: 5, Message: `expected a term before ")"`},
+		},
+		{
+			name: "OR の後に何も無い",
+			args: args{
+				query: "情報 OR",
+			},
+			wantErr: &QueryParseError{Position: 6, Message: `expected a term before "end of query"`},
+		},
+		{
+			name: "閉じられていない引用符",
+			args: args{
+				query: `情報 "線形 代数`,
+			},
+			wantErr: &QueryParseError{Position: 4, Message: "unclosed quotation mark"},
+		},
+		{
+			name: "知らないカラム",
+			args: args{
+				query: "情報 teacher:山田",
+			},
+			wantErr: &QueryParseError{Position: 4, Message: `unknown field "teacher"`},
+		},
+		{
+			name: "値が空",
+			args: args{
+				query: "instructor: 山田",
+			},
+			wantErr: &QueryParseError{Position: 12, Message: `value for "instructor" is empty`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseQuery(tt.args.query)
+			if tt.wantErr != nil {
+				if diff := cmp.Diff(err, tt.wantErr); diff != "" {
+					t.Errorf("ParseQuery() error mismatch: (-got +want)\n%s", diff)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseQuery() error = %v", err)
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("ParseQuery() mismatch: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
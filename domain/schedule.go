@@ -0,0 +1,454 @@
+package domain
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// /timetable/generate の条件
+type ScheduleQuery struct {
+	// 省略時は登録されている最新の年度
+	Year int `json:"year"`
+	// 必ず入れる科目の科目番号または科目コード
+	// 科目コードを指定すると、その科目コードの科目 (クラス) のいずれか 1 つを入れる
+	Required []string `json:"required"`
+	// できるだけ入れる科目、Required と同様
+	// 先頭ほど優先する
+	Optional []string `json:"optional"`
+	// 開講時期 (春A など) ごとの単位数の上限、0 なら制限しない
+	MaxCreditsPerTerm float64 `json:"max_credits_per_term"`
+	// 授業を入れたくない曜日 ("月" など)
+	FreeDays []string `json:"free_days"`
+	// 1 時限に授業を入れたくない
+	AvoidFirstPeriod bool `json:"avoid_first_period"`
+	// 返す時間割の数、省略時は ScheduleDefaultLimit
+	Limit int `json:"limit"`
+}
+
+const (
+	ScheduleDefaultLimit = 5
+	ScheduleMaxLimit     = 20
+)
+
+// 時間割の候補の 1 つ
+type ScheduleItem struct {
+	// ScheduleQuery で指定された科目番号または科目コード
+	Key      string
+	Required bool
+	// 入れられる科目、科目コードで指定された場合は複数になる
+	Sections []*Course
+}
+
+// 時間割の探索の条件
+type ScheduleOptions struct {
+	MaxCreditsPerTerm float64
+	FreeDays          []string
+	AvoidFirstPeriod  bool
+	Limit             int
+	// 探索するノード数の上限、0 なら制限しない
+	MaxNodes int
+	// 探索を打ち切る時刻、ゼロ値なら制限しない
+	Deadline time.Time
+}
+
+// 作成した時間割
+type Schedule struct {
+	Courses []*Course
+	// 展開した開講時期ごとの単位数の合計
+	CreditsByTerm map[int]float64
+	TotalCredits  float64
+	// 入れたくない曜日・1 時限にある必修の科目のコマの数
+	Penalty int
+}
+
+// 入れられなかった候補
+type ScheduleUnplaced struct {
+	Key     string
+	Reasons []*ScheduleReason
+}
+
+// 候補を入れられなかった理由
+const (
+	// 科目番号・科目コードに一致する科目が無い
+	ScheduleReasonNotFound = "not_found"
+	// ConflictWith の科目と Term, Period のコマで重なる
+	ScheduleReasonConflict = "conflict"
+	// Term の単位数が上限を超える
+	ScheduleReasonMaxCredits = "max_credits"
+	// 入れたくない曜日の Period に授業がある
+	ScheduleReasonFreeDay = "free_day"
+	// 1 時限の Period に授業がある
+	ScheduleReasonFirstPeriod = "first_period"
+	// 探索を打ち切ったため確かめられなかった
+	ScheduleReasonSearchLimit = "search_limit"
+)
+
+type ScheduleReason struct {
+	// ScheduleReason から始まる定数のいずれか
+	Code string
+	// 入れようとした科目の科目番号
+	CourseNumber string
+	ConflictWith string
+	Term         int
+	Period       string
+}
+
+type ScheduleResult struct {
+	// 良いものから順に並べる
+	// より多くの候補を入れたもの、優先する候補を入れたもの、Penalty が少ないものの順
+	Schedules []*Schedule
+	// 最も良い時間割に入れられなかった候補
+	// 時間割が 1 つも作れなかった場合は、入れられなかった必修の候補
+	Unplaced []*ScheduleUnplaced
+	// 探索を打ち切った
+	Truncated bool
+}
+
+// 候補から重なりの無い時間割を作る
+// 必修の候補から順に、与えられた順で入れるかを決めていく
+func GenerateSchedules(items []*ScheduleItem, options ScheduleOptions) *ScheduleResult {
+	ordered := []*ScheduleItem{}
+	for _, required := range []bool{true, false} {
+		for _, item := range items {
+			if item.Required == required {
+				ordered = append(ordered, item)
+			}
+		}
+	}
+
+	s := &scheduleSearch{
+		items:    ordered,
+		options:  options,
+		state:    newScheduleState(),
+		deepest:  -1,
+		maxSaved: options.Limit,
+	}
+	if s.maxSaved <= 0 {
+		s.maxSaved = ScheduleDefaultLimit
+	}
+
+	result := &ScheduleResult{
+		Schedules: []*Schedule{},
+		Unplaced:  []*ScheduleUnplaced{},
+	}
+	for _, item := range ordered {
+		if item.Required && len(item.Sections) == 0 {
+			result.Unplaced = append(result.Unplaced, &ScheduleUnplaced{
+				Key:     item.Key,
+				Reasons: []*ScheduleReason{{Code: ScheduleReasonNotFound}},
+			})
+		}
+	}
+	if len(result.Unplaced) != 0 {
+		return result
+	}
+
+	s.visit(0)
+	result.Truncated = s.truncated
+	for _, found := range s.found {
+		result.Schedules = append(result.Schedules, found.schedule)
+	}
+
+	if len(s.found) == 0 {
+		// 最も深くまで入れられた状態で、次の必修の候補を入れられなかった理由を示す
+		if s.deepest < 0 {
+			return result
+		}
+		item := ordered[s.deepest]
+		result.Unplaced = append(result.Unplaced, &ScheduleUnplaced{
+			Key:     item.Key,
+			Reasons: s.explain(item, s.deepestState),
+		})
+		return result
+	}
+
+	best := s.found[0]
+	for i, item := range ordered {
+		if best.placed[i] {
+			continue
+		}
+		unplaced := &ScheduleUnplaced{Key: item.Key}
+		if len(item.Sections) == 0 {
+			unplaced.Reasons = []*ScheduleReason{{Code: ScheduleReasonNotFound}}
+		} else {
+			unplaced.Reasons = s.explain(item, best.state)
+		}
+		result.Unplaced = append(result.Unplaced, unplaced)
+	}
+	return result
+}
+
+// 時間割に入れた科目と、それが占めるコマ・単位数
+type scheduleState struct {
+	courses  []*Course
+	occupied map[TimetableCell]*Course
+	credits  map[int]float64
+	penalty  int
+}
+
+func newScheduleState() *scheduleState {
+	return &scheduleState{
+		courses:  []*Course{},
+		occupied: map[TimetableCell]*Course{},
+		credits:  map[int]float64{},
+	}
+}
+
+func (st *scheduleState) clone() *scheduleState {
+	copied := &scheduleState{
+		courses:  append([]*Course{}, st.courses...),
+		occupied: map[TimetableCell]*Course{},
+		credits:  map[int]float64{},
+		penalty:  st.penalty,
+	}
+	for cell, course := range st.occupied {
+		copied.occupied[cell] = course
+	}
+	for term, credits := range st.credits {
+		copied.credits[term] = credits
+	}
+	return copied
+}
+
+type foundSchedule struct {
+	schedule *Schedule
+	// 順に並べた候補のうち入れたもの
+	placed []bool
+	state  *scheduleState
+}
+
+type scheduleSearch struct {
+	items     []*ScheduleItem
+	options   ScheduleOptions
+	state     *scheduleState
+	placed    []bool
+	nodes     int
+	truncated bool
+
+	// 良いものから順に maxSaved 個まで
+	found    []*foundSchedule
+	maxSaved int
+
+	// 時間割が作れなかったときのために、必修の候補を入れられなかった最も深い位置とその状態
+	deepest      int
+	deepestState *scheduleState
+}
+
+func (s *scheduleSearch) visit(i int) {
+	if s.truncated {
+		return
+	}
+	s.nodes++
+	if s.options.MaxNodes > 0 && s.nodes > s.options.MaxNodes {
+		s.truncated = true
+		return
+	}
+	// 時刻の取得はノードごとには行わない
+	if !s.options.Deadline.IsZero() && s.nodes%256 == 0 && time.Now().After(s.options.Deadline) {
+		s.truncated = true
+		return
+	}
+
+	if i == len(s.items) {
+		s.record()
+		return
+	}
+
+	item := s.items[i]
+	placedAny := false
+	for _, section := range item.Sections {
+		if len(s.reasons(section, item.Required, s.state)) != 0 {
+			continue
+		}
+		placedAny = true
+		s.place(section, item.Required)
+		s.placed = append(s.placed, true)
+		s.visit(i + 1)
+		s.placed = s.placed[:len(s.placed)-1]
+		s.unplace(section, item.Required)
+	}
+
+	if !item.Required {
+		s.placed = append(s.placed, false)
+		s.visit(i + 1)
+		s.placed = s.placed[:len(s.placed)-1]
+	} else if !placedAny && i > s.deepest {
+		s.deepest = i
+		s.deepestState = s.state.clone()
+	}
+}
+
+func (s *scheduleSearch) place(course *Course, required bool) {
+	s.state.courses = append(s.state.courses, course)
+	for _, cell := range course.TimetableCells() {
+		s.state.occupied[cell] = course
+		if required && s.isAvoided(cell) {
+			s.state.penalty++
+		}
+	}
+	if credits, ok := courseCredits(course); ok {
+		for _, term := range ExpandTerms(course.Term) {
+			s.state.credits[term] += credits
+		}
+	}
+}
+
+func (s *scheduleSearch) unplace(course *Course, required bool) {
+	s.state.courses = s.state.courses[:len(s.state.courses)-1]
+	for _, cell := range course.TimetableCells() {
+		delete(s.state.occupied, cell)
+		if required && s.isAvoided(cell) {
+			s.state.penalty--
+		}
+	}
+	if credits, ok := courseCredits(course); ok {
+		for _, term := range ExpandTerms(course.Term) {
+			s.state.credits[term] -= credits
+		}
+	}
+}
+
+// 入れたくない曜日・1 時限のコマか
+func (s *scheduleSearch) isAvoided(cell TimetableCell) bool {
+	return s.freeDayOf(cell) || s.isFirstPeriod(cell)
+}
+
+func (s *scheduleSearch) freeDayOf(cell TimetableCell) bool {
+	for _, day := range s.options.FreeDays {
+		if strings.HasPrefix(cell.Period, day) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *scheduleSearch) isFirstPeriod(cell TimetableCell) bool {
+	if !s.options.AvoidFirstPeriod {
+		return false
+	}
+	_, size := utf8.DecodeRuneInString(cell.Period)
+	return cell.Period[size:] == "1"
+}
+
+// course を state に入れられない理由、入れられるなら空
+// 入れたくない曜日・1 時限は必修でない候補にだけ適用する
+func (s *scheduleSearch) reasons(course *Course, required bool, state *scheduleState) []*ScheduleReason {
+	reasons := []*ScheduleReason{}
+	for _, placed := range state.courses {
+		if placed.ID == course.ID {
+			reasons = append(reasons, &ScheduleReason{Code: ScheduleReasonConflict, CourseNumber: course.CourseNumber, ConflictWith: placed.CourseNumber})
+			return reasons
+		}
+	}
+
+	conflicted := map[*Course]bool{}
+	freeDay, firstPeriod := false, false
+	for _, cell := range course.TimetableCells() {
+		if other, ok := state.occupied[cell]; ok && !conflicted[other] {
+			conflicted[other] = true
+			reasons = append(reasons, &ScheduleReason{Code: ScheduleReasonConflict, CourseNumber: course.CourseNumber, ConflictWith: other.CourseNumber, Term: cell.Term, Period: cell.Period})
+		}
+		if required {
+			continue
+		}
+		if !freeDay && s.freeDayOf(cell) {
+			freeDay = true
+			reasons = append(reasons, &ScheduleReason{Code: ScheduleReasonFreeDay, CourseNumber: course.CourseNumber, Period: cell.Period})
+		}
+		if !firstPeriod && s.isFirstPeriod(cell) {
+			firstPeriod = true
+			reasons = append(reasons, &ScheduleReason{Code: ScheduleReasonFirstPeriod, CourseNumber: course.CourseNumber, Period: cell.Period})
+		}
+	}
+
+	if credits, ok := courseCredits(course); ok && s.options.MaxCreditsPerTerm > 0 {
+		for _, term := range ExpandTerms(course.Term) {
+			if state.credits[term]+credits > s.options.MaxCreditsPerTerm {
+				reasons = append(reasons, &ScheduleReason{Code: ScheduleReasonMaxCredits, CourseNumber: course.CourseNumber, Term: term})
+				break
+			}
+		}
+	}
+	return reasons
+}
+
+// item のいずれの科目も state に入れられない理由
+func (s *scheduleSearch) explain(item *ScheduleItem, state *scheduleState) []*ScheduleReason {
+	reasons := []*ScheduleReason{}
+	for _, section := range item.Sections {
+		reasons = append(reasons, s.reasons(section, item.Required, state)...)
+	}
+	// 入れられるのに入っていないのは、探索を打ち切ったため
+	if len(reasons) == 0 {
+		reasons = append(reasons, &ScheduleReason{Code: ScheduleReasonSearchLimit})
+	}
+	return reasons
+}
+
+func (s *scheduleSearch) record() {
+	schedule := &Schedule{
+		Courses:       append([]*Course{}, s.state.courses...),
+		CreditsByTerm: map[int]float64{},
+		Penalty:       s.state.penalty,
+	}
+	for _, course := range schedule.Courses {
+		if credits, ok := courseCredits(course); ok {
+			schedule.TotalCredits += credits
+			for _, term := range ExpandTerms(course.Term) {
+				schedule.CreditsByTerm[term] += credits
+			}
+		}
+	}
+	found := &foundSchedule{
+		schedule: schedule,
+		placed:   append([]bool{}, s.placed...),
+	}
+
+	index := len(s.found)
+	for index > 0 && s.better(found, s.found[index-1]) {
+		index--
+	}
+	if index >= s.maxSaved {
+		return
+	}
+	found.state = s.state.clone()
+	s.found = append(s.found, nil)
+	copy(s.found[index+1:], s.found[index:])
+	s.found[index] = found
+	if len(s.found) > s.maxSaved {
+		s.found = s.found[:s.maxSaved]
+	}
+}
+
+// a が b より良い時間割か
+func (s *scheduleSearch) better(a *foundSchedule, b *foundSchedule) bool {
+	countA, countB := 0, 0
+	for i := range a.placed {
+		if a.placed[i] {
+			countA++
+		}
+		if b.placed[i] {
+			countB++
+		}
+	}
+	if countA != countB {
+		return countA > countB
+	}
+	for i := range a.placed {
+		if a.placed[i] != b.placed[i] {
+			return a.placed[i]
+		}
+	}
+	return a.schedule.Penalty < b.schedule.Penalty
+}
+
+// 単位数が数値であればその値
+func courseCredits(course *Course) (float64, bool) {
+	credits, err := strconv.ParseFloat(course.Credits, 64)
+	if err != nil || credits < 0 {
+		return 0, false
+	}
+	return credits, true
+}
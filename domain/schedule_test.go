@@ -0,0 +1,162 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerateSchedules(t *testing.T) {
+	course := func(id int, number string, credits string, term int, periods ...string) *Course {
+		return &Course{ID: id, CourseNumber: number, Credits: credits, Term: []int{term}, Period: periods}
+	}
+	// 秋A の月1
+	a := course(1, "A", "2.0", 4, "月1")
+	// 科目コードが同じ 2 つのクラス、1 つ目は a と重なる
+	b1 := course(2, "B1", "1.0", 4, "月1")
+	b2 := course(3, "B2", "1.0", 4, "火2")
+	// a と重なる
+	c := course(4, "C", "1.0", 9, "月1")
+	// 金曜
+	d := course(5, "D", "1.0", 4, "金2")
+	// 1 時限と 2 時限のクラス
+	e1 := course(6, "E1", "1.0", 5, "水1")
+	e2 := course(7, "E2", "1.0", 5, "水2")
+
+	numbers := func(schedules []*Schedule) [][]string {
+		got := [][]string{}
+		for _, schedule := range schedules {
+			courseNumbers := []string{}
+			for _, course := range schedule.Courses {
+				courseNumbers = append(courseNumbers, course.CourseNumber)
+			}
+			got = append(got, courseNumbers)
+		}
+		return got
+	}
+
+	tests := []struct {
+		name          string
+		items         []*ScheduleItem
+		options       ScheduleOptions
+		wantSchedules [][]string
+		wantUnplaced  []*ScheduleUnplaced
+		wantTruncated bool
+	}{
+		{
+			name: "重ならないクラスを選び、入れられない候補の理由を示す",
+			items: []*ScheduleItem{
+				{Key: "C", Sections: []*Course{c}},
+				{Key: "A", Required: true, Sections: []*Course{a}},
+				{Key: "B", Required: true, Sections: []*Course{b1, b2}},
+			},
+			wantSchedules: [][]string{{"A", "B2"}},
+			wantUnplaced: []*ScheduleUnplaced{
+				{
+					Key: "C",
+					Reasons: []*ScheduleReason{
+						{Code: ScheduleReasonConflict, CourseNumber: "C", ConflictWith: "A", Term: 4, Period: "月1"},
+					},
+				},
+			},
+		},
+		{
+			name: "優先する候補を入れたものから並べる",
+			items: []*ScheduleItem{
+				{Key: "A", Required: true, Sections: []*Course{a}},
+				{Key: "B", Sections: []*Course{b2}},
+				{Key: "D", Sections: []*Course{d}},
+			},
+			options: ScheduleOptions{
+				MaxCreditsPerTerm: 3,
+			},
+			wantSchedules: [][]string{{"A", "B2"}, {"A", "D"}, {"A"}},
+			wantUnplaced: []*ScheduleUnplaced{
+				{
+					Key: "D",
+					Reasons: []*ScheduleReason{
+						{Code: ScheduleReasonMaxCredits, CourseNumber: "D", Term: 4},
+					},
+				},
+			},
+		},
+		{
+			name: "入れたくない曜日・1 時限",
+			items: []*ScheduleItem{
+				{Key: "E", Required: true, Sections: []*Course{e1, e2}},
+				{Key: "D", Sections: []*Course{d}},
+			},
+			options: ScheduleOptions{
+				FreeDays:         []string{"金"},
+				AvoidFirstPeriod: true,
+			},
+			wantSchedules: [][]string{{"E2"}, {"E1"}},
+			wantUnplaced: []*ScheduleUnplaced{
+				{
+					Key: "D",
+					Reasons: []*ScheduleReason{
+						{Code: ScheduleReasonFreeDay, CourseNumber: "D", Period: "金2"},
+					},
+				},
+			},
+		},
+		{
+			name: "必修の候補が重なると時間割を作れない",
+			items: []*ScheduleItem{
+				{Key: "A", Required: true, Sections: []*Course{a}},
+				{Key: "C", Required: true, Sections: []*Course{c}},
+			},
+			wantSchedules: [][]string{},
+			wantUnplaced: []*ScheduleUnplaced{
+				{
+					Key: "C",
+					Reasons: []*ScheduleReason{
+						{Code: ScheduleReasonConflict, CourseNumber: "C", ConflictWith: "A", Term: 4, Period: "月1"},
+					},
+				},
+			},
+		},
+		{
+			name: "必修の候補が見つからない",
+			items: []*ScheduleItem{
+				{Key: "A", Required: true, Sections: []*Course{a}},
+				{Key: "X", Required: true},
+				{Key: "Y"},
+			},
+			wantSchedules: [][]string{},
+			wantUnplaced: []*ScheduleUnplaced{
+				{
+					Key:     "X",
+					Reasons: []*ScheduleReason{{Code: ScheduleReasonNotFound}},
+				},
+			},
+		},
+		{
+			name: "探索するノード数の上限",
+			items: []*ScheduleItem{
+				{Key: "A", Required: true, Sections: []*Course{a}},
+				{Key: "B", Sections: []*Course{b2}},
+			},
+			options: ScheduleOptions{
+				MaxNodes: 2,
+			},
+			wantSchedules: [][]string{},
+			wantUnplaced:  []*ScheduleUnplaced{},
+			wantTruncated: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GenerateSchedules(tt.items, tt.options)
+			if diff := cmp.Diff(numbers(got.Schedules), tt.wantSchedules); diff != "" {
+				t.Errorf("GenerateSchedules() schedules mismatch: (-got +want)\n%s", diff)
+			}
+			if diff := cmp.Diff(got.Unplaced, tt.wantUnplaced); diff != "" {
+				t.Errorf("GenerateSchedules() unplaced mismatch: (-got +want)\n%s", diff)
+			}
+			if got.Truncated != tt.wantTruncated {
+				t.Errorf("GenerateSchedules() truncated = %v, want %v", got.Truncated, tt.wantTruncated)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package domain
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sylms/csv2sql/kdb"
+)
+
+// 時間割の 1 コマ
+type TimetableCell struct {
+	// 春A から秋C、または夏季休業中・春季休業中の開講時期の番号
+	// 通年・春学期・秋学期は ExpandTerms で春A から秋C に展開したもの
+	Term int
+	// "月1" のような曜時限
+	Period string
+}
+
+// /course/fits で埋まっているコマとして受け付ける値
+// {"term": "春AB", "period": "月1,2"} のように、開講時期と曜時限の組み合わせのすべてのコマを表す
+type TimetableSlot struct {
+	// csv2sql/kdb の TermParser で認識できる形式
+	Term string `json:"term"`
+	// csv2sql/kdb の PeriodParser で認識できる形式
+	Period string `json:"period"`
+}
+
+// /course/fits の検索条件
+// CourseQuery の条件に加えて、時間割の空いているコマに収まる科目に絞り込む
+type CourseFitsQuery struct {
+	CourseQuery
+	// 埋まっているコマ
+	Occupied []TimetableSlot `json:"occupied"`
+	// 履修している科目の科目番号
+	// それらの科目のコマも埋まっているものとする、年度は CourseQuery.Year から探す
+	CourseNumbers []string `json:"course_numbers"`
+}
+
+// /timetable/check の検索条件
+type TimetableCheckQuery struct {
+	// 省略時は登録されている最新の年度
+	Year int `json:"year"`
+	// 時間割に入っている科目の科目番号
+	CourseNumbers []string `json:"course_numbers"`
+}
+
+// 2 つの科目の重なり
+type TimetableConflict struct {
+	Courses [2]*Course
+	// 両方の科目の授業があるコマ
+	Cells []TimetableCell
+}
+
+// 時間割の検査結果
+type TimetableCheck struct {
+	// 与えられた順
+	Courses   []*Course
+	Conflicts []*TimetableConflict
+	// 展開した開講時期ごとの単位数の合計
+	// 複数の開講時期にまたがる科目はそれぞれの開講時期に単位数をすべて加える
+	CreditsByTerm map[int]float64
+	// すべての科目の単位数の合計
+	TotalCredits float64
+}
+
+// 時間割の科目どうしの重なりと、開講時期ごとの単位数を求める
+// 単位数が数値でない科目は単位数の合計に含めない
+func CheckTimetable(courses []*Course) *TimetableCheck {
+	check := &TimetableCheck{
+		Courses:       courses,
+		Conflicts:     []*TimetableConflict{},
+		CreditsByTerm: map[int]float64{},
+	}
+
+	cells := [][]TimetableCell{}
+	for _, course := range courses {
+		cells = append(cells, course.TimetableCells())
+
+		credits, ok := courseCredits(course)
+		if !ok {
+			continue
+		}
+		check.TotalCredits += credits
+		for _, term := range ExpandTerms(course.Term) {
+			check.CreditsByTerm[term] += credits
+		}
+	}
+
+	for i := range courses {
+		for j := i + 1; j < len(courses); j++ {
+			shared := sharedCells(cells[i], cells[j])
+			if len(shared) != 0 {
+				check.Conflicts = append(check.Conflicts, &TimetableConflict{
+					Courses: [2]*Course{courses[i], courses[j]},
+					Cells:   shared,
+				})
+			}
+		}
+	}
+	return check
+}
+
+// a と b の両方にあるコマ、a の順
+func sharedCells(a []TimetableCell, b []TimetableCell) []TimetableCell {
+	inB := map[TimetableCell]bool{}
+	for _, cell := range b {
+		inB[cell] = true
+	}
+	shared := []TimetableCell{}
+	for _, cell := range a {
+		if inB[cell] {
+			shared = append(shared, cell)
+		}
+	}
+	return shared
+}
+
+// 開講時期を時間割の単位に展開する
+// 通年は春A から秋C、春学期は春A から春C、秋学期は秋A から秋C のすべてとし、重複は取り除く
+func ExpandTerms(terms []int) []int {
+	expanded := []int{}
+	seen := map[int]bool{}
+	add := func(term int) {
+		if !seen[term] {
+			seen[term] = true
+			expanded = append(expanded, term)
+		}
+	}
+	for _, term := range terms {
+		switch term {
+		case kdb.TermAllCode:
+			for t := kdb.TermSpringACode; t <= kdb.TermFallCCode; t++ {
+				add(t)
+			}
+		case kdb.TermSpringCode:
+			for t := kdb.TermSpringACode; t <= kdb.TermSpringCCode; t++ {
+				add(t)
+			}
+		case kdb.TermFallCode:
+			for t := kdb.TermFallACode; t <= kdb.TermFallCCode; t++ {
+				add(t)
+			}
+		default:
+			add(term)
+		}
+	}
+	return expanded
+}
+
+// 展開した開講時期 term に授業がある科目の開講時期 (展開する前のもの) の一覧
+// 春A なら春A, 通年, 春学期
+func TermsCovering(term int) []int {
+	switch {
+	case kdb.TermSpringACode <= term && term <= kdb.TermSpringCCode:
+		return []int{term, kdb.TermAllCode, kdb.TermSpringCode}
+	case kdb.TermFallACode <= term && term <= kdb.TermFallCCode:
+		return []int{term, kdb.TermAllCode, kdb.TermFallCode}
+	}
+	return []int{term}
+}
+
+// 展開した開講時期が terms のいずれかと重なる科目の開講時期 (展開する前のもの) の一覧、番号の順
+// 春A なら春A, 通年, 春学期、通年なら春A から秋C と通年, 春学期, 秋学期
+func TermsOverlapping(terms []int) []int {
+	seen := map[int]bool{}
+	for _, term := range ExpandTerms(terms) {
+		for _, t := range TermsCovering(term) {
+			seen[t] = true
+		}
+	}
+	overlapping := []int{}
+	for term := range seen {
+		overlapping = append(overlapping, term)
+	}
+	sort.Ints(overlapping)
+	return overlapping
+}
+
+// 曜日と時限からなる、毎週決まったコマの曜時限か
+// 集中・応談・随時は false
+func IsWeeklyPeriod(period string) bool {
+	day, size := utf8.DecodeRuneInString(period)
+	if !strings.ContainsRune("月火水木金土日", day) {
+		return false
+	}
+	number := period[size:]
+	return len(number) == 1 && '0' <= number[0] && number[0] <= '9'
+}
+
+// 開講時期と曜時限の組み合わせからコマの一覧を作る
+// 毎週決まったコマではない曜時限は含めない
+func newTimetableCells(terms []int, periods []string) []TimetableCell {
+	cells := []TimetableCell{}
+	seen := map[TimetableCell]bool{}
+	for _, term := range ExpandTerms(terms) {
+		for _, period := range periods {
+			cell := TimetableCell{Term: term, Period: period}
+			if !IsWeeklyPeriod(period) || seen[cell] {
+				continue
+			}
+			seen[cell] = true
+			cells = append(cells, cell)
+		}
+	}
+	return cells
+}
+
+// 科目の授業があるコマ
+// 集中・応談・随時の科目は決まったコマを占めないので空になる
+func (c *Course) TimetableCells() []TimetableCell {
+	return newTimetableCells(c.Term, c.Period)
+}
+
+// 埋まっているコマ
+// 値は TermParser, PeriodParser で解釈できることを検証しておく
+func (s TimetableSlot) Cells() ([]TimetableCell, error) {
+	terms := []int{}
+	for _, termStr := range kdb.TermParser(s.Term) {
+		term, err := kdb.TermStrToInt(termStr)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+	periods, err := kdb.PeriodParser(s.Period)
+	if err != nil {
+		return nil, err
+	}
+	return newTimetableCells(terms, periods), nil
+}
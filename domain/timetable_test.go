@@ -0,0 +1,186 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandTerms(t *testing.T) {
+	tests := []struct {
+		name  string
+		terms []int
+		want  []int
+	}{
+		{
+			name:  "モジュールはそのまま",
+			terms: []int{4, 5},
+			want:  []int{4, 5},
+		},
+		{
+			name:  "通年",
+			terms: []int{9},
+			want:  []int{1, 2, 3, 4, 5, 6},
+		},
+		{
+			name:  "春学期と重なるモジュール",
+			terms: []int{2, 10},
+			want:  []int{2, 1, 3},
+		},
+		{
+			name:  "秋学期と夏季休業中",
+			terms: []int{11, 7},
+			want:  []int{4, 5, 6, 7},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExpandTerms(tt.terms); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExpandTerms() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTermsOverlapping(t *testing.T) {
+	tests := []struct {
+		name  string
+		terms []int
+		want  []int
+	}{
+		{
+			name:  "春A は通年と春学期にも重なる",
+			terms: []int{1},
+			want:  []int{1, 9, 10},
+		},
+		{
+			name:  "秋A と秋B",
+			terms: []int{4, 5},
+			want:  []int{4, 5, 9, 11},
+		},
+		{
+			name:  "通年はすべてのモジュールに重なる",
+			terms: []int{9},
+			want:  []int{1, 2, 3, 4, 5, 6, 9, 10, 11},
+		},
+		{
+			name:  "夏季休業中",
+			terms: []int{7},
+			want:  []int{7},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TermsOverlapping(tt.terms); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TermsOverlapping() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCourse_TimetableCells(t *testing.T) {
+	tests := []struct {
+		name   string
+		course Course
+		want   []TimetableCell
+	}{
+		{
+			name: "開講時期と曜時限の組み合わせ、重複は取り除く",
+			course: Course{
+				Term:   []int{4, 5},
+				Period: []string{"月3", "月4", "月3"},
+			},
+			want: []TimetableCell{
+				{Term: 4, Period: "月3"},
+				{Term: 4, Period: "月4"},
+				{Term: 5, Period: "月3"},
+				{Term: 5, Period: "月4"},
+			},
+		},
+		{
+			name: "集中はコマを占めない",
+			course: Course{
+				Term:   []int{7},
+				Period: []string{"集中"},
+			},
+			want: []TimetableCell{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.course.TimetableCells(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Course.TimetableCells() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimetableSlot_Cells(t *testing.T) {
+	tests := []struct {
+		name    string
+		slot    TimetableSlot
+		want    []TimetableCell
+		wantErr bool
+	}{
+		{
+			name: "春学期の月1,2",
+			slot: TimetableSlot{Term: "春学期", Period: "月1,2"},
+			want: []TimetableCell{
+				{Term: 1, Period: "月1"},
+				{Term: 1, Period: "月2"},
+				{Term: 2, Period: "月1"},
+				{Term: 2, Period: "月2"},
+				{Term: 3, Period: "月1"},
+				{Term: 3, Period: "月2"},
+			},
+		},
+		{
+			name:    "曜時限が不正",
+			slot:    TimetableSlot{Term: "春A", Period: "月"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.slot.Cells()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TimetableSlot.Cells() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TimetableSlot.Cells() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckTimetable(t *testing.T) {
+	// 通年の月1 と秋AB の月1,2 は秋A, 秋B の月1 で重なる
+	allYear := &Course{CourseNumber: "A", Credits: "2.0", Term: []int{9}, Period: []string{"月1"}}
+	fall := &Course{CourseNumber: "B", Credits: "1.0", Term: []int{4, 5}, Period: []string{"月1", "月2"}}
+	// 春学期の月2 は秋AB の科目とは重ならない
+	spring := &Course{CourseNumber: "C", Credits: "1.5", Term: []int{10}, Period: []string{"月2"}}
+	// 単位数が数値でない集中の科目
+	intensive := &Course{CourseNumber: "D", Credits: "-", Term: []int{7}, Period: []string{"集中"}}
+
+	got := CheckTimetable([]*Course{allYear, fall, spring, intensive})
+
+	wantConflicts := []*TimetableConflict{
+		{
+			Courses: [2]*Course{allYear, fall},
+			Cells: []TimetableCell{
+				{Term: 4, Period: "月1"},
+				{Term: 5, Period: "月1"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got.Conflicts, wantConflicts) {
+		t.Errorf("CheckTimetable() conflicts = %+v, want %+v", got.Conflicts, wantConflicts)
+	}
+
+	wantCreditsByTerm := map[int]float64{1: 3.5, 2: 3.5, 3: 3.5, 4: 3, 5: 3, 6: 2}
+	if !reflect.DeepEqual(got.CreditsByTerm, wantCreditsByTerm) {
+		t.Errorf("CheckTimetable() credits by term = %v, want %v", got.CreditsByTerm, wantCreditsByTerm)
+	}
+	if got.TotalCredits != 4.5 {
+		t.Errorf("CheckTimetable() total credits = %v, want 4.5", got.TotalCredits)
+	}
+}
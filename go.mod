@@ -18,6 +18,7 @@ require (
 	github.com/rs/cors v1.8.0 // indirect
 	github.com/sergi/go-diff v1.2.0 // indirect
 	github.com/sylms/csv2sql v0.0.0-20220111103726-a9f2cb0b2fa7 // indirect
+	golang.org/x/text v0.3.7 // indirect
 	gopkg.in/airbrake/gobrake.v2 v2.0.9 // indirect
 	gopkg.in/gemnasium/logrus-airbrake-hook.v2 v2.1.2 // indirect
 	gopkg.in/guregu/null.v3 v3.5.0 // indirect
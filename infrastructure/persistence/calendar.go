@@ -0,0 +1,201 @@
+package persistence
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sylms/azuki/domain"
+	"github.com/sylms/csv2sql/kdb"
+)
+
+type calendarPersistence struct {
+	dir string
+}
+
+// dir にある {年度}.json から学年暦を読む
+// ファイルはリクエストのたびに読むので、書き換えれば再起動しなくても反映される
+func NewCalendarPersistence(dir string) domain.CalendarRepository {
+	return &calendarPersistence{
+		dir: dir,
+	}
+}
+
+// 学年暦のファイルの形式
+//
+//	{
+//	  "terms": {"春A": {"start": "2021-04-12", "end": "2021-05-20"}, ...},
+//	  "periods": {"1": {"start": "08:40", "end": "09:55"}, ...},
+//	  "holidays": ["2021-04-29", ...],
+//	  "substitutes": [{"date": "2021-07-26", "day": "月"}, ...],
+//	  "exams": {"春C": {"start": "2021-08-02", "end": "2021-08-06"}, ...}
+//	}
+type CalendarFile struct {
+	// キーは csv2sql/kdb の TermStrToInt で認識できる開講時期、ただし通年・春学期・秋学期は除く
+	Terms map[string]CalendarDateRangeFile `json:"terms"`
+	// キーは時限の番号
+	Periods     map[string]CalendarClockRangeFile `json:"periods"`
+	Holidays    []string                          `json:"holidays"`
+	Substitutes []CalendarSubstituteFile          `json:"substitutes"`
+	// キーは Terms と同様
+	Exams map[string]CalendarDateRangeFile `json:"exams"`
+}
+
+type CalendarSubstituteFile struct {
+	Date string `json:"date"`
+	// その日に授業を行う曜日
+	Day string `json:"day"`
+}
+
+type CalendarDateRangeFile struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+type CalendarClockRangeFile struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+func (p *calendarPersistence) FindByYear(year int) (*domain.AcademicCalendar, error) {
+	data, err := os.ReadFile(filepath.Join(p.dir, fmt.Sprintf("%d.json", year)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, domain.ErrCalendarNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file CalendarFile
+	err = json.Unmarshal(data, &file)
+	if err != nil {
+		return nil, fmt.Errorf("calendar %d: %w", year, err)
+	}
+	calendar, err := toAcademicCalendar(year, file)
+	if err != nil {
+		return nil, fmt.Errorf("calendar %d: %w", year, err)
+	}
+	return calendar, nil
+}
+
+func toAcademicCalendar(year int, file CalendarFile) (*domain.AcademicCalendar, error) {
+	calendar := &domain.AcademicCalendar{
+		Year:        year,
+		Periods:     map[int]*domain.ClockRange{},
+		Holidays:    []time.Time{},
+		Substitutes: []*domain.SubstituteDay{},
+	}
+
+	terms, err := toTermDateRanges(file.Terms)
+	if err != nil {
+		return nil, fmt.Errorf("'terms' error: %w", err)
+	}
+	calendar.Terms = terms
+
+	for periodStr, clocks := range file.Periods {
+		period, err := strconv.Atoi(periodStr)
+		if err != nil || period < 0 || 9 < period {
+			return nil, fmt.Errorf("'periods' error: %s, 0 - 9", periodStr)
+		}
+		start, err := parseCalendarClock(clocks.Start)
+		if err != nil {
+			return nil, fmt.Errorf("'periods' error: %s: %w", periodStr, err)
+		}
+		end, err := parseCalendarClock(clocks.End)
+		if err != nil {
+			return nil, fmt.Errorf("'periods' error: %s: %w", periodStr, err)
+		}
+		if end <= start {
+			return nil, fmt.Errorf("'periods' error: %s ends before it starts", periodStr)
+		}
+		calendar.Periods[period] = &domain.ClockRange{Start: start, End: end}
+	}
+
+	for _, dateStr := range file.Holidays {
+		date, err := parseCalendarDate(dateStr)
+		if err != nil {
+			return nil, fmt.Errorf("'holidays' error: %w", err)
+		}
+		calendar.Holidays = append(calendar.Holidays, date)
+	}
+	sort.Slice(calendar.Holidays, func(i, j int) bool {
+		return calendar.Holidays[i].Before(calendar.Holidays[j])
+	})
+
+	for _, substitute := range file.Substitutes {
+		date, err := parseCalendarDate(substitute.Date)
+		if err != nil {
+			return nil, fmt.Errorf("'substitutes' error: %w", err)
+		}
+		if _, ok := domain.ParseCalendarWeekday(substitute.Day); !ok {
+			return nil, fmt.Errorf("'substitutes' error: %s: unknown day %s", substitute.Date, substitute.Day)
+		}
+		if len(calendar.TermsOn(date)) == 0 {
+			return nil, fmt.Errorf("'substitutes' error: %s is outside of terms", substitute.Date)
+		}
+		calendar.Substitutes = append(calendar.Substitutes, &domain.SubstituteDay{Date: date, Day: substitute.Day})
+	}
+	sort.Slice(calendar.Substitutes, func(i, j int) bool {
+		return calendar.Substitutes[i].Date.Before(calendar.Substitutes[j].Date)
+	})
+
+	exams, err := toTermDateRanges(file.Exams)
+	if err != nil {
+		return nil, fmt.Errorf("'exams' error: %w", err)
+	}
+	for termStr := range file.Exams {
+		// toTermDateRanges で確かめてあるのでエラーにならない
+		term, _ := kdb.TermStrToInt(termStr)
+		if _, ok := calendar.Terms[term]; !ok {
+			return nil, fmt.Errorf("'exams' error: %s has no term dates", termStr)
+		}
+	}
+	calendar.Exams = exams
+	return calendar, nil
+}
+
+// 開講時期ごとの日付の範囲
+func toTermDateRanges(file map[string]CalendarDateRangeFile) (map[int]*domain.DateRange, error) {
+	ranges := map[int]*domain.DateRange{}
+	for termStr, dates := range file {
+		term, err := kdb.TermStrToInt(termStr)
+		if err != nil {
+			return nil, err
+		}
+		if len(domain.ExpandTerms([]int{term})) != 1 {
+			return nil, fmt.Errorf("%s must be split into modules", termStr)
+		}
+		start, err := parseCalendarDate(dates.Start)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", termStr, err)
+		}
+		end, err := parseCalendarDate(dates.End)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", termStr, err)
+		}
+		if end.Before(start) {
+			return nil, fmt.Errorf("%s ends before it starts", termStr)
+		}
+		ranges[term] = &domain.DateRange{Start: start, End: end}
+	}
+	return ranges, nil
+}
+
+// "2021-04-12" を CalendarLocation の 0 時にする
+func parseCalendarDate(s string) (time.Time, error) {
+	return time.ParseInLocation(domain.CalendarDateLayout, s, domain.CalendarLocation)
+}
+
+// "08:40" を 0 時からの経過時間にする
+func parseCalendarClock(s string) (time.Duration, error) {
+	clock, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
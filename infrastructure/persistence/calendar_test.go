@@ -0,0 +1,164 @@
+package persistence
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sylms/azuki/domain"
+	"github.com/sylms/csv2sql/kdb"
+)
+
+func Test_calendarPersistence_FindByYear(t *testing.T) {
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(2021, month, day, 0, 0, 0, 0, domain.CalendarLocation)
+	}
+	clock := func(hour int, minute int) time.Duration {
+		return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute
+	}
+
+	p := NewCalendarPersistence("testdata/calendar")
+
+	t.Run("年度のファイルを読む", func(t *testing.T) {
+		got, err := p.FindByYear(2021)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Year != 2021 {
+			t.Errorf("Year = %d, want 2021", got.Year)
+		}
+		if len(got.Terms) != 6 {
+			t.Errorf("len(Terms) = %d, want 6", len(got.Terms))
+		}
+		wantSpringA := &domain.DateRange{Start: date(time.April, 12), End: date(time.May, 19)}
+		if !reflect.DeepEqual(got.Terms[kdb.TermSpringACode], wantSpringA) {
+			t.Errorf("Terms[春A] = %+v, want %+v", got.Terms[kdb.TermSpringACode], wantSpringA)
+		}
+		wantFirst := &domain.ClockRange{Start: clock(8, 40), End: clock(9, 55)}
+		if !reflect.DeepEqual(got.Periods[1], wantFirst) {
+			t.Errorf("Periods[1] = %+v, want %+v", got.Periods[1], wantFirst)
+		}
+		// 日付の順に並べ替える
+		wantHolidays := []time.Time{date(time.April, 29), date(time.May, 3), date(time.May, 4), date(time.May, 5), date(time.November, 3), date(time.November, 23)}
+		if !reflect.DeepEqual(got.Holidays, wantHolidays) {
+			t.Errorf("Holidays = %v, want %v", got.Holidays, wantHolidays)
+		}
+		wantSubstitutes := []*domain.SubstituteDay{{Date: date(time.May, 19), Day: "木"}, {Date: date(time.July, 26), Day: "月"}}
+		if !reflect.DeepEqual(got.Substitutes, wantSubstitutes) {
+			t.Errorf("Substitutes = %v, want %v", got.Substitutes, wantSubstitutes)
+		}
+		wantExams := map[int]*domain.DateRange{kdb.TermSpringCCode: {Start: date(time.August, 4), End: date(time.August, 10)}}
+		if !reflect.DeepEqual(got.Exams, wantExams) {
+			t.Errorf("Exams = %v, want %v", got.Exams, wantExams)
+		}
+	})
+
+	t.Run("ファイルが無ければ ErrCalendarNotFound", func(t *testing.T) {
+		_, err := p.FindByYear(2019)
+		if !errors.Is(err, domain.ErrCalendarNotFound) {
+			t.Errorf("error = %v, want %v", err, domain.ErrCalendarNotFound)
+		}
+	})
+
+	t.Run("開講時期が不正", func(t *testing.T) {
+		_, err := p.FindByYear(2020)
+		if err == nil || errors.Is(err, domain.ErrCalendarNotFound) {
+			t.Errorf("error = %v, want a parse error", err)
+		}
+	})
+}
+
+func Test_toAcademicCalendar(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    CalendarFile
+		wantErr bool
+	}{
+		{
+			name: "空のファイル",
+			file: CalendarFile{},
+		},
+		{
+			name: "春学期はモジュールに分けて書く",
+			file: CalendarFile{
+				Terms: map[string]CalendarDateRangeFile{"春学期": {Start: "2021-04-12", End: "2021-08-10"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "授業期間の終わりが始まりより前",
+			file: CalendarFile{
+				Terms: map[string]CalendarDateRangeFile{"春A": {Start: "2021-05-19", End: "2021-04-12"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "日付の書式が不正",
+			file: CalendarFile{
+				Terms: map[string]CalendarDateRangeFile{"春A": {Start: "2021/04/12", End: "2021-05-19"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "時限が不正",
+			file: CalendarFile{
+				Periods: map[string]CalendarClockRangeFile{"10": {Start: "21:10", End: "22:25"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "時刻が不正",
+			file: CalendarFile{
+				Periods: map[string]CalendarClockRangeFile{"1": {Start: "8:40am", End: "09:55"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "授業の終わりが始まりより前",
+			file: CalendarFile{
+				Periods: map[string]CalendarClockRangeFile{"1": {Start: "09:55", End: "08:40"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "振替授業日の曜日が不正",
+			file: CalendarFile{
+				Terms:       map[string]CalendarDateRangeFile{"春A": {Start: "2021-04-12", End: "2021-05-19"}},
+				Substitutes: []CalendarSubstituteFile{{Date: "2021-05-19", Day: "Mon"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "振替授業日が授業期間の外",
+			file: CalendarFile{
+				Terms:       map[string]CalendarDateRangeFile{"春A": {Start: "2021-04-12", End: "2021-05-19"}},
+				Substitutes: []CalendarSubstituteFile{{Date: "2021-05-20", Day: "月"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "授業期間の無い開講時期の試験期間",
+			file: CalendarFile{
+				Terms: map[string]CalendarDateRangeFile{"春A": {Start: "2021-04-12", End: "2021-05-19"}},
+				Exams: map[string]CalendarDateRangeFile{"春B": {Start: "2021-06-28", End: "2021-06-30"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "休日の書式が不正",
+			file: CalendarFile{
+				Holidays: []string{"4/29"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := toAcademicCalendar(2021, tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("toAcademicCalendar() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,8 +1,13 @@
 package persistence
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -36,11 +41,52 @@ type CoursesPostgresql struct {
 	UpdatedAt                time.Time      `db:"updated_at"`
 }
 
+// courses のすべてのカラム
+// 検索結果に計算した列を付け加えるときは select * ではなくこれを使う
+const courseColumns = `id, course_number, course_name, instructional_type, credits, standard_registration_year, term, period_, classroom, instructor, course_overview, remarks, credited_auditors, application_conditions, alt_course_name, course_code, course_code_name, csv_updated_at, year, created_at, updated_at`
+
+// 検索結果の 1 行
+type CourseSearchRowPostgresql struct {
+	CoursesPostgresql
+	// limit, offset, カーソルを適用する前の件数
+	TotalCount int `db:"total_count"`
+	// カーソルを適用した後、limit, offset を適用する前の件数
+	RestCount int `db:"rest_count"`
+	// カーソルを発行するためのソートキーの値
+	SortKeys pq.StringArray `db:"sort_keys"`
+	// CourseQuery.Facets を指定した場合の集計、集計の種類をキーとする JSON
+	Facets []byte `db:"facets"`
+}
+
+// 検索結果が 1 行も無いときに改めて数えた件数
+type CourseCountRowPostgresql struct {
+	TotalCount int    `db:"total_count"`
+	Facets     []byte `db:"facets"`
+}
+
 type FacetPostgresql struct {
 	Term      int `db:"term"`
 	TermCount int `db:"term_count"`
 }
 
+type PeriodFacetPostgresql struct {
+	Period string `db:"period"`
+	Count  int    `db:"count"`
+}
+
+type FacetValuePostgresql struct {
+	Value string `db:"value" json:"value"`
+	Count int    `db:"count" json:"count"`
+}
+
+type AcademicYearPostgresql struct {
+	Year        int `db:"year"`
+	CourseCount int `db:"course_count"`
+}
+
+// Stream でカーソルから 1 度に取り出す件数
+const streamFetchSize = 500
+
 type coursePersistence struct {
 	db *sqlx.DB
 }
@@ -51,7 +97,12 @@ func NewCoursePersistence(db *sqlx.DB) domain.CourseRepository {
 	}
 }
 
-func (p *coursePersistence) Search(query domain.CourseQuery) ([]*domain.Course, error) {
+func (p *coursePersistence) Search(query domain.CourseQuery) (*domain.CourseSearchResult, error) {
+	// limit 0 は件数と集計だけを求めるのに使うので、科目は検索せずに数える
+	if query.Limit == 0 {
+		return p.searchCount(query)
+	}
+
 	queryStr, queryArgs, err := buildSearchCourseQuery(query)
 	if err != nil {
 		return nil, err
@@ -59,19 +110,123 @@ func (p *coursePersistence) Search(query domain.CourseQuery) ([]*domain.Course,
 
 	// とりあえず具体的な PostgreSQL と指定
 	// TODO: これはもっと抽象にするべき？調査
-	var selectResultRows []*CoursesPostgresql
+	var selectResultRows []*CourseSearchRowPostgresql
 	err = p.db.Select(&selectResultRows, queryStr, queryArgs...)
 	if err != nil {
 		return nil, err
 	}
 
-	var courses []*domain.Course
+	result := &domain.CourseSearchResult{}
 	for _, row := range selectResultRows {
 		course := row.toCourse()
-		courses = append(courses, &course)
+		result.Courses = append(result.Courses, &course)
 	}
 
-	return courses, nil
+	if len(selectResultRows) == 0 {
+		// offset やカーソルで該当件数より後ろを指定されていると 1 行も返ってこず件数がわからないので、改めて数える
+		// 集計は検索条件に該当する科目が無くても値があり得るので、要求されていれば同様に改めて求める
+		if query.Offset > 0 || query.Cursor != "" || len(query.Facets) != 0 {
+			return p.searchCount(query)
+		}
+		return result, nil
+	}
+
+	lastRow := selectResultRows[len(selectResultRows)-1]
+	result.Total = lastRow.TotalCount
+	result.Facets, err = decodeFacets(lastRow.Facets)
+	if err != nil {
+		return nil, err
+	}
+	result.HasNext = query.Offset+len(selectResultRows) < lastRow.RestCount
+	if result.HasNext {
+		// カーソルには並び順の名前しか使わないので、プレースホルダーは捨てる
+		sortKeys, _, _ := buildSortKeys(query, []interface{}{}, 1)
+		result.NextCursor, err = encodeCursor(sortKeys, lastRow.SortKeys)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// 科目を含まない検索結果、件数と集計だけを求める
+func (p *coursePersistence) searchCount(query domain.CourseQuery) (*domain.CourseSearchResult, error) {
+	countQueryStr, countQueryArgs, err := buildCountCourseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	var countRow CourseCountRowPostgresql
+	err = p.db.Get(&countRow, countQueryStr, countQueryArgs...)
+	if err != nil {
+		return nil, err
+	}
+	facets, err := decodeFacets(countRow.Facets)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.CourseSearchResult{
+		Total:  countRow.TotalCount,
+		Facets: facets,
+	}, nil
+}
+
+// サーバー側のカーソルで streamFetchSize 件ずつ取り出しながら fn に渡す
+// 結果全体を読み込まないので、件数が多くてもメモリの使用量は変わらない
+func (p *coursePersistence) Stream(ctx context.Context, query domain.CourseQuery, fn func(*domain.Course) error) error {
+	queryStr, queryArgs, err := buildStreamCourseQuery(query)
+	if err != nil {
+		return err
+	}
+
+	// カーソルはトランザクションの中でのみ使える
+	tx, err := p.db.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `declare course_stream no scroll cursor for `+queryStr, queryArgs...)
+	if err != nil {
+		return err
+	}
+
+	for {
+		count, err := fetchCourseStream(ctx, tx, fn)
+		if err != nil {
+			return err
+		}
+		if count < streamFetchSize {
+			break
+		}
+	}
+
+	return tx.Commit()
+}
+
+// course_stream のカーソルから streamFetchSize 件まで取り出して fn に渡し、取り出した件数を返す
+func fetchCourseStream(ctx context.Context, tx *sqlx.Tx, fn func(*domain.Course) error) (int, error) {
+	rows, err := tx.QueryxContext(ctx, fmt.Sprintf(`fetch %d from course_stream`, streamFetchSize))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var row CoursesPostgresql
+		err = rows.StructScan(&row)
+		if err != nil {
+			return count, err
+		}
+		course := row.toCourse()
+		err = fn(&course)
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, rows.Err()
 }
 
 func (p *coursePersistence) Facet(query domain.CourseQuery) ([]*domain.Facet, error) {
@@ -97,6 +252,139 @@ func (p *coursePersistence) Facet(query domain.CourseQuery) ([]*domain.Facet, er
 	return facets, nil
 }
 
+func (p *coursePersistence) PeriodFacet(query domain.CourseQuery) ([]*domain.PeriodFacet, error) {
+	queryStr, queryArgs, err := buildGetPeriodFacetQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var selectResultRows []*PeriodFacetPostgresql
+	err = p.db.Select(&selectResultRows, queryStr, queryArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	facets := []*domain.PeriodFacet{}
+	for _, row := range selectResultRows {
+		facet := domain.PeriodFacet(*row)
+		facets = append(facets, &facet)
+	}
+
+	return facets, nil
+}
+
+// kind の集計に使う検索条件は呼び出し側で domain.CourseQuery.WithoutFacetFilter を適用しておく
+func (p *coursePersistence) FacetValues(query domain.CourseQuery, kind string) ([]*domain.FacetValue, error) {
+	queryStr, queryArgs, err := buildGetFacetValuesQuery(query, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	var selectResultRows []*FacetValuePostgresql
+	err = p.db.Select(&selectResultRows, queryStr, queryArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	return toFacetValues(selectResultRows), nil
+}
+
+func toFacetValues(rows []*FacetValuePostgresql) []*domain.FacetValue {
+	facets := []*domain.FacetValue{}
+	for _, row := range rows {
+		facet := domain.FacetValue(*row)
+		facets = append(facets, &facet)
+	}
+	return facets
+}
+
+// buildFacetsExpr で求めた集計の JSON を集計の種類ごとの値にする
+// 集計を要求されていない (列が無い) 場合は nil
+func decodeFacets(data []byte) (map[string][]*domain.FacetValue, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var rows map[string][]*FacetValuePostgresql
+	err := json.Unmarshal(data, &rows)
+	if err != nil {
+		return nil, err
+	}
+	facets := map[string][]*domain.FacetValue{}
+	for kind, kindRows := range rows {
+		facets[kind] = toFacetValues(kindRows)
+	}
+	return facets, nil
+}
+
+func (p *coursePersistence) Years() ([]*domain.AcademicYear, error) {
+	const queryStr = `select year, count(*) as course_count from courses group by year order by year desc`
+
+	var selectResultRows []*AcademicYearPostgresql
+	err := p.db.Select(&selectResultRows, queryStr)
+	if err != nil {
+		return nil, err
+	}
+
+	years := []*domain.AcademicYear{}
+	for _, row := range selectResultRows {
+		year := domain.AcademicYear(*row)
+		years = append(years, &year)
+	}
+
+	return years, nil
+}
+
+func (p *coursePersistence) FindByNumbers(numbers []string, years []string) ([]*domain.Course, error) {
+	return p.findBy("course_number", numbers, years)
+}
+
+func (p *coursePersistence) FindByCodes(codes []string, years []string) ([]*domain.Course, error) {
+	return p.findBy("course_code", codes, years)
+}
+
+func (p *coursePersistence) FindByID(id int) (*domain.Course, error) {
+	return p.findOne(`select * from courses where id = $1`, id)
+}
+
+func (p *coursePersistence) FindByNumber(year int, number string) (*domain.Course, error) {
+	return p.findOne(`select * from courses where year = $1 and course_number = $2`, year, number)
+}
+
+// 1 つの科目を探す、見つからなければ domain.ErrCourseNotFound
+func (p *coursePersistence) findOne(queryStr string, queryArgs ...interface{}) (*domain.Course, error) {
+	var row CoursesPostgresql
+	err := p.db.Get(&row, queryStr, queryArgs...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrCourseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	course := row.toCourse()
+	return &course, nil
+}
+
+// dbColumnName が values のいずれかに一致する科目を探す
+func (p *coursePersistence) findBy(dbColumnName string, values []string, years []string) ([]*domain.Course, error) {
+	queryYear, _, queryArgs := buildYearQuery(years, []interface{}{pq.StringArray(values)}, 2)
+	queryStr := `select * from courses where ` + dbColumnName + ` = any($1::text[]) and ` + queryYear + ` order by year desc, id`
+
+	var selectResultRows []*CoursesPostgresql
+	err := p.db.Select(&selectResultRows, queryStr, queryArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	courses := []*domain.Course{}
+	for _, row := range selectResultRows {
+		course := row.toCourse()
+		courses = append(courses, &course)
+	}
+
+	return courses, nil
+}
+
 // domain.Course に変換
 // pq パッケージに依存しているところを整形する
 func (c *CoursesPostgresql) toCourse() domain.Course {
@@ -132,9 +420,8 @@ func (c *CoursesPostgresql) toCourse() domain.Course {
 }
 
 func buildSearchCourseQuery(options domain.CourseQuery) (string, []interface{}, error) {
-	// それぞれのカラムに対してカラム内検索の AND/OR が指定されている場合はそれで構築を行なう
-	// それぞれのカラムに対して検索文字列を構築したらそれぞれの間を FilterType で埋める
-	// 全体に対して offset, limit を指定する
+	// 検索条件で絞り込み、ソートキーを計算する内側の select と
+	// カーソルの続きを取り出して並び替え、limit, offset を適用する外側の select に分ける
 
 	// PostgreSQL へ渡す $1, $2 プレースホルダーのインクリメントのカウンタ
 	placeholderCount := 1
@@ -142,26 +429,60 @@ func buildSearchCourseQuery(options domain.CourseQuery) (string, []interface{},
 	// PostgreSQL へ渡す select 文のプレースホルダーに割り当てる変数を格納
 	selectArgs := []interface{}{}
 
-	// それぞれのカラムに対する小さなクエリの集合
-	queryLists := []string{}
+	sortKeys, placeholderCount, selectArgs := buildSortKeys(options, selectArgs, placeholderCount)
+
+	// ソートキーを計算する列
+	querySortKeys, sortKeyColumnNames := buildSortKeyColumns(sortKeys)
+
+	// 集計を要求されていれば、絞り込んだ科目を共通テーブル式にして検索結果と集計の両方をそこから求める
+	// 1 度の走査で済み、件数と検索結果が同じスナップショットのものになる
+	queryWith := ""
+	queryFacets := ""
+	var queryInner string
+	if len(options.Facets) == 0 {
+		// where 部分を構築
+		var queryWhere string
+		var err error
+		queryWhere, placeholderCount, selectArgs, err = buildWhereQuery(options, selectArgs, placeholderCount)
+		if err != nil {
+			return "", nil, err
+		}
+		queryInner = `select ` + courseColumns + `, count(*) over() as total_count` + querySortKeys + ` from ` + courseSearchFrom + ` ` + queryWhere
+	} else {
+		kinds := uniqueFacetKinds(options.Facets)
+		var queryFiltered string
+		var err error
+		queryFiltered, placeholderCount, selectArgs, err = buildFacetFilteredQuery(options, kinds, querySortKeys, selectArgs, placeholderCount)
+		if err != nil {
+			return "", nil, err
+		}
+		queryWith = `with filtered as (` + queryFiltered + `) `
+		queryInner = `select *, count(*) over() as total_count from filtered where hit`
+		var queryFacetsExpr string
+		queryFacetsExpr, placeholderCount, selectArgs, err = buildFacetsExpr(kinds, selectArgs, placeholderCount)
+		if err != nil {
+			return "", nil, err
+		}
+		queryFacets = `, ` + queryFacetsExpr + ` as facets`
+	}
 
-	// where 部分を構築
-	queryCourseName, placeholderCount, selectArgs := buildSimpleQuery(options.CourseName, options.CourseNameFilterType, "course_name", selectArgs, placeholderCount)
-	queryLists = append(queryLists, queryCourseName)
-	queryCourseOverview, placeholderCount, selectArgs := buildSimpleQuery(options.CourseOverview, options.CourseOverviewFilterType, "course_overview", selectArgs, placeholderCount)
-	queryLists = append(queryLists, queryCourseOverview)
-	queryCourseNumber, placeholderCount, selectArgs := buildSimpleQuery(options.CourseNumber, options.CourseOverviewFilterType, "course_number", selectArgs, placeholderCount)
-	queryLists = append(queryLists, queryCourseNumber)
-	queryPeriod, placeholderCount, selectArgs := buildArrayQuery(options.Period, options.CourseOverviewFilterType, "period_", selectArgs, placeholderCount)
-	queryLists = append(queryLists, queryPeriod)
-	queryTerm, placeholderCount, selectArgs := buildArrayQuery(options.Term, options.CourseOverviewFilterType, "term", selectArgs, placeholderCount)
-	queryLists = append(queryLists, queryTerm)
-
-	// カラムごとに生成されたクエリを接続
-	queryWhere := connectEachSimpleQuery(queryLists, options.FilterType)
+	// カーソルが指定されていればその続きから
+	queryKeyset := ""
+	if options.Cursor != "" {
+		keys, err := decodeCursor(options.Cursor, sortKeys)
+		if err != nil {
+			return "", nil, err
+		}
+		queryKeyset, placeholderCount, selectArgs = buildKeysetQuery(sortKeys, sortKeyColumnNames, keys, selectArgs, placeholderCount)
+		queryKeyset = "where " + queryKeyset + " "
+	}
 
 	// order by
-	const queryOrderBy = "order by id asc "
+	queryOrderBy := buildSortKeyOrderBy(sortKeys, sortKeyColumnNames)
+	sortKeysText := []string{}
+	for _, columnName := range sortKeyColumnNames {
+		sortKeysText = append(sortKeysText, columnName+"::text")
+	}
 
 	// limit 部分を構築
 	queryLimit := fmt.Sprintf(`limit $%d `, placeholderCount)
@@ -172,48 +493,246 @@ func buildSearchCourseQuery(options domain.CourseQuery) (string, []interface{},
 	queryOffset := fmt.Sprintf(`offset $%d`, placeholderCount)
 	selectArgs = append(selectArgs, strconv.Itoa(options.Offset))
 
-	const queryHead = `select * from courses `
-	queryWhere = "where " + queryWhere
-	if queryWhere == "where ()" {
-		queryWhere = ""
+	queryHead := queryWith + `select ` + courseColumns + `, total_count, count(*) over() as rest_count, array[` + strings.Join(sortKeysText, ", ") + `] as sort_keys` + queryFacets + ` from (` + queryInner + `) as s1 `
+	return queryHead + queryKeyset + queryOrderBy + queryLimit + queryOffset, selectArgs, nil
+}
+
+// ソートキーを sort_key_1, sort_key_2, ... の列として計算する select の列と、その列名
+func buildSortKeyColumns(sortKeys []sortKey) (string, []string) {
+	querySortKeys := ""
+	columnNames := []string{}
+	for i, key := range sortKeys {
+		columnName := fmt.Sprintf("sort_key_%d", i+1)
+		querySortKeys += fmt.Sprintf(`, %s as %s`, key.expr, columnName)
+		columnNames = append(columnNames, columnName)
 	}
-	return queryHead + queryWhere + queryOrderBy + queryLimit + queryOffset, selectArgs, nil
+	return querySortKeys, columnNames
 }
 
-func buildSimpleQuery(rawStr string, filterType string, dbColumnName string, selectArgs []interface{}, placeholderCount int) (string, int, []interface{}) {
-	separatedStrList := util.SplitSpace(rawStr)
-	resQuery := ""
-	for count, separseparatedStr := range separatedStrList {
-		if count == 0 {
-			resQuery += fmt.Sprintf(`%s like $%d `, dbColumnName, placeholderCount)
-		} else {
-			resQuery += fmt.Sprintf(`%s %s like $%d `, filterType, dbColumnName, placeholderCount)
+// buildSortKeyColumns の列で並べる order by 句
+func buildSortKeyOrderBy(sortKeys []sortKey, columnNames []string) string {
+	orderByLists := []string{}
+	for i, key := range sortKeys {
+		direction := "asc"
+		if key.desc {
+			direction = "desc"
 		}
-		placeholderCount++
-		// 現時点では、キーワードを含むものを検索
-		if dbColumnName == "course_number" {
-			selectArgs = append(selectArgs, separseparatedStr+"%")
-		} else {
-			selectArgs = append(selectArgs, "%"+separseparatedStr+"%")
+		orderByLists = append(orderByLists, key.collate(columnNames[i])+" "+direction)
+	}
+	return "order by " + strings.Join(orderByLists, ", ") + " "
+}
+
+// 検索条件に該当するすべての科目を検索結果と同じ順に取り出すクエリを構築する
+// limit, offset, カーソル、集計は無視する
+func buildStreamCourseQuery(options domain.CourseQuery) (string, []interface{}, error) {
+	// PostgreSQL へ渡す $1, $2 プレースホルダーのインクリメントのカウンタ
+	placeholderCount := 1
+
+	// PostgreSQL へ渡す select 文のプレースホルダーに割り当てる変数を格納
+	selectArgs := []interface{}{}
+
+	sortKeys, placeholderCount, selectArgs := buildSortKeys(options, selectArgs, placeholderCount)
+	querySortKeys, sortKeyColumnNames := buildSortKeyColumns(sortKeys)
+
+	queryWhere, _, selectArgs, err := buildWhereQuery(options, selectArgs, placeholderCount)
+	if err != nil {
+		return "", nil, err
+	}
+
+	queryInner := `select ` + courseColumns + querySortKeys + ` from ` + courseSearchFrom + ` ` + queryWhere
+	query := `select ` + courseColumns + ` from (` + queryInner + `) as s1 ` + buildSortKeyOrderBy(sortKeys, sortKeyColumnNames)
+	return strings.TrimSpace(query), selectArgs, nil
+}
+
+// 週の最初の授業の曜時限を 月1 = 11, 月2 = 12, ..., 日8 = 78 のような数値にしたもの
+// 集中・応談・随時のみの科目は最後に並べる
+const firstPeriodExpr = `coalesce((select min(strpos('月火水木金土日', substr(p, 1, 1)) * 10 + (case when substr(p, 2) ~ '^[0-9]$' then substr(p, 2)::int else 0 end)) from unnest(period_) as p where p <> '' and strpos('月火水木金土日', substr(p, 1, 1)) > 0), 999)`
+
+// 科目名を日本語の順序で並べるための照合順序
+const japaneseCollation = `"ja-x-icu"`
+
+// 検索結果の並び順
+// 最後は必ず一意になる id で並べる
+func buildSortKeys(options domain.CourseQuery, selectArgs []interface{}, placeholderCount int) ([]sortKey, int, []interface{}) {
+	sorts := options.Sort
+	// 全文検索ではキーワードがあれば関連度順を既定とする
+	if len(sorts) == 0 && options.SearchMode == domain.SearchModeFulltext && len(splitKeyword(options)) != 0 {
+		sorts = []domain.CourseSort{
+			{
+				Key: domain.SortKeyRelevance,
+			},
 		}
 	}
-	return resQuery, placeholderCount, selectArgs
+
+	sortKeys := []sortKey{}
+	for _, sort := range sorts {
+		key := sortKey{
+			name: sort.Key,
+			desc: sort.Order == domain.SortOrderDesc,
+		}
+		switch sort.Key {
+		case domain.SortKeyCourseNumber:
+			key.expr = "course_number"
+		case domain.SortKeyCourseName:
+			key.expr = "course_name"
+			key.collation = japaneseCollation
+		case domain.SortKeyCredits:
+			key.expr = "coalesce(" + creditsNumericExpr + ", -1)"
+		case domain.SortKeyStandardRegistrationYear:
+			key.expr = "standard_registration_year"
+		case domain.SortKeyPeriod:
+			key.expr = firstPeriodExpr
+		case domain.SortKeyRelevance:
+			// 関連度は省略時は降順
+			key.desc = sort.Order != domain.SortOrderAsc
+			key.expr, placeholderCount, selectArgs = buildRelevanceExpr(options, selectArgs, placeholderCount)
+		}
+		sortKeys = append(sortKeys, key)
+	}
+
+	sortKeys = append(sortKeys, sortKey{
+		name: "id",
+		expr: "id",
+	})
+	return sortKeys, placeholderCount, selectArgs
 }
 
-func connectEachSimpleQuery(queryLists []string, filterType string) string {
-	resStr := ""
-	for _, query := range queryLists {
-		if query != "" {
-			if resStr != "" {
-				resStr += filterType
-			}
-			resStr += "(" + query + ")"
+// キーワードに対する関連度
+// キーワードごとに、科目名に含まれていれば 2、科目名がそれから始まっていればさらに 1、授業概要に含まれていれば 1 を加える
+// 全文検索では buildFulltextRelevanceExpr による
+func buildRelevanceExpr(options domain.CourseQuery, selectArgs []interface{}, placeholderCount int) (string, int, []interface{}) {
+	if options.SearchMode == domain.SearchModeFulltext {
+		return buildFulltextRelevanceExpr(options, selectArgs, placeholderCount)
+	}
+
+	keywords := append(util.SplitSpace(normalizeForSearch(options.CourseName)), util.SplitSpace(normalizeForSearch(options.CourseOverview))...)
+	keywords = append(keywords, util.SplitSpace(normalizeForSearch(options.Keyword))...)
+	if len(keywords) == 0 {
+		return "0", placeholderCount, selectArgs
+	}
+
+	scores := []string{}
+	for _, keyword := range keywords {
+		scores = append(scores, fmt.Sprintf(`(case when normalized_course_name like $%d then 2 else 0 end) + (case when normalized_course_name like $%d then 1 else 0 end) + (case when normalized_course_overview like $%d then 1 else 0 end)`, placeholderCount, placeholderCount+1, placeholderCount))
+		placeholderCount += 2
+		selectArgs = append(selectArgs, "%"+keyword+"%", keyword+"%")
+	}
+	return "(" + strings.Join(scores, " + ") + ")", placeholderCount, selectArgs
+}
+
+// 検索条件に該当する科目数を数えるクエリを構築する
+// 集計を要求されていれば buildSearchCourseQuery と同様に集計も求める
+func buildCountCourseQuery(options domain.CourseQuery) (string, []interface{}, error) {
+	if len(options.Facets) != 0 {
+		kinds := uniqueFacetKinds(options.Facets)
+		queryFiltered, placeholderCount, selectArgs, err := buildFacetFilteredQuery(options, kinds, "", []interface{}{}, 1)
+		if err != nil {
+			return "", nil, err
 		}
+		queryFacetsExpr, _, selectArgs, err := buildFacetsExpr(kinds, selectArgs, placeholderCount)
+		if err != nil {
+			return "", nil, err
+		}
+		return `with filtered as (` + queryFiltered + `) select count(*) filter (where hit) as total_count, ` + queryFacetsExpr + ` as facets from filtered`, selectArgs, nil
 	}
-	return "(" + resStr + ")"
+
+	queryWhere, _, selectArgs, err := buildWhereQuery(options, []interface{}{}, 1)
+	if err != nil {
+		return "", nil, err
+	}
+
+	const queryHead = `select count(*) as total_count from ` + courseSearchFrom + ` `
+	return queryHead + queryWhere, selectArgs, nil
+}
+
+// 検索条件から where 句を構築する
+func buildWhereQuery(options domain.CourseQuery, selectArgs []interface{}, placeholderCount int) (string, int, []interface{}, error) {
+	queryFilter, placeholderCount, selectArgs, err := buildFilterCondition(options, selectArgs, placeholderCount)
+	if err != nil {
+		return "", placeholderCount, selectArgs, err
+	}
+	queryWhere, placeholderCount, selectArgs := buildScopedWhereQuery(options, queryFilter, selectArgs, placeholderCount)
+	return queryWhere, placeholderCount, selectArgs, nil
+}
+
+// カラムごとの条件・検索式・検索条件の木を 1 つの木にまとめてから条件を構築する
+// 条件が無ければ空文字列
+func buildFilterCondition(options domain.CourseQuery, selectArgs []interface{}, placeholderCount int) (string, int, []interface{}, error) {
+	filter, err := buildCourseFilter(options)
+	if err != nil {
+		return "", placeholderCount, selectArgs, err
+	}
+	if filter == nil {
+		return "", placeholderCount, selectArgs, nil
+	}
+	queryFilter, placeholderCount, selectArgs := buildFilterQuery(filter, selectArgs, placeholderCount)
+	return queryFilter, placeholderCount, selectArgs, nil
+}
+
+// 年度と埋まっているコマの絞り込みに queryFilter を加えた where 句を構築する
+func buildScopedWhereQuery(options domain.CourseQuery, queryFilter string, selectArgs []interface{}, placeholderCount int) (string, int, []interface{}) {
+	// 年度と埋まっているコマは FilterType に関わらず常に絞り込む
+	queryYear, placeholderCount, selectArgs := buildYearQuery(options.Year, selectArgs, placeholderCount)
+	queryWhere := "where " + queryYear + " "
+	if queryFilter != "" {
+		queryWhere += "and (" + queryFilter + ") "
+	}
+	if len(options.Occupied) != 0 {
+		var queryOccupied string
+		queryOccupied, placeholderCount, selectArgs = buildOccupiedQuery(options.Occupied, selectArgs, placeholderCount)
+		queryWhere += "and " + queryOccupied + " "
+	}
+	return queryWhere, placeholderCount, selectArgs
+}
+
+// 年度の絞り込みのクエリを構築する
+// 年度が指定されていない場合は最新の年度とする
+func buildYearQuery(years []string, selectArgs []interface{}, placeholderCount int) (string, int, []interface{}) {
+	const queryLatestYear = `year = (select max(year) from courses)`
+
+	includeLatest := len(years) == 0
+	specifiedYears := []string{}
+	for _, year := range years {
+		if year == domain.YearLatest {
+			includeLatest = true
+		} else {
+			specifiedYears = append(specifiedYears, year)
+		}
+	}
+
+	conditions := []string{}
+	if len(specifiedYears) != 0 {
+		conditions = append(conditions, fmt.Sprintf(`year = any($%d::int[])`, placeholderCount))
+		placeholderCount++
+		selectArgs = append(selectArgs, pq.StringArray(specifiedYears))
+	}
+	if includeLatest {
+		conditions = append(conditions, queryLatestYear)
+	}
+	return "(" + strings.Join(conditions, " or ") + ")", placeholderCount, selectArgs
+}
+
+// 正規化したキーワード 1 つに対する条件
+// dbColumnName は course_search の正規化したカラム
+func buildTextCondition(keyword string, dbColumnName string, selectArgs []interface{}, placeholderCount int) (string, int, []interface{}) {
+	condition := fmt.Sprintf(`%s like $%d`, dbColumnName, placeholderCount)
+	if dbColumnName == "normalized_classroom" {
+		// 教室はカンマ区切りで複数入っていることがあるので、それぞれに対して前方一致させる
+		condition = fmt.Sprintf(`exists (select 1 from unnest(string_to_array(normalized_classroom, ',')) as room where room like $%d)`, placeholderCount)
+	}
+	placeholderCount++
+
+	// 科目番号と教室 (建物) は前方一致、それ以外はキーワードを含むものを検索
+	if dbColumnName == "normalized_course_number" || dbColumnName == "normalized_classroom" {
+		selectArgs = append(selectArgs, keyword+"%")
+	} else {
+		selectArgs = append(selectArgs, "%"+keyword+"%")
+	}
+	return condition, placeholderCount, selectArgs
 }
 
-func buildArrayQuery(rawStr string, filterType string, dbColumnName string, selectArgs []interface{}, placeholderCount int) (string, int, []interface{}) {
+// 曜時限・開講時期の配列のカラムと与えられた値の集合を matchMode に従って比べるクエリを構築する
+func buildArrayQuery(rawStr string, dbColumnName string, matchMode string, selectArgs []interface{}, placeholderCount int) (string, int, []interface{}) {
 	var separatedStrList []string
 	if dbColumnName == "period_" {
 		separatedStrList, _ = kdb.PeriodParser(rawStr)
@@ -225,6 +744,9 @@ func buildArrayQuery(rawStr string, filterType string, dbColumnName string, sele
 			termInt, _ := kdb.TermStrToInt(term)
 			termsInt = append(termsInt, termInt)
 		}
+		if matchMode == matchModeHeldIn {
+			termsInt = domain.TermsOverlapping(termsInt)
+		}
 		for _, termInt := range termsInt {
 			separatedStrList = append(separatedStrList, strconv.Itoa(termInt))
 		}
@@ -241,50 +763,229 @@ func buildArrayQuery(rawStr string, filterType string, dbColumnName string, sele
 			placeholderCount++
 			selectArgs = append(selectArgs, separseparatedStr)
 		}
-		if dbColumnName == "period_" {
-			resQuery += fmt.Sprintf(`]::varchar[] @> %s and array[]::varchar[] <> %s`, dbColumnName, dbColumnName)
-		}
+		arrayType := "varchar[]"
 		if dbColumnName == "term" {
-			resQuery += fmt.Sprintf(`]::int[] @> %s and array[]::int[] <> %s`, dbColumnName, dbColumnName)
+			arrayType = "int[]"
+		}
+		resQuery += "]::" + arrayType
+		switch matchMode {
+		case domain.MatchModeOverlaps, matchModeHeldIn:
+			resQuery = fmt.Sprintf(`%s && %s`, resQuery, dbColumnName)
+		case domain.MatchModeContainsAll:
+			resQuery = fmt.Sprintf(`%s @> %s`, dbColumnName, resQuery)
+		case domain.MatchModeExact:
+			// 配列の @> は重複を区別しないので、両方向に含まれれば集合として一致する
+			resQuery = fmt.Sprintf(`%s @> %s and %s @> %s`, dbColumnName, resQuery, resQuery, dbColumnName)
+		default:
+			resQuery = fmt.Sprintf(`%s @> %s and array[]::%s <> %s`, resQuery, dbColumnName, arrayType, dbColumnName)
 		}
 	}
 	return resQuery, placeholderCount, selectArgs
 }
 
-// TODO: buildSearchCourseQuery とほぼ同じなところを抜き出す
-func buildGetFacetQuery(options domain.CourseQuery) (string, []interface{}, error) {
-	// それぞれのカラムに対してカラム内検索の AND/OR が指定されている場合はそれで構築を行なう
-	// それぞれのカラムに対して検索文字列を構築したらそれぞれの間を FilterType で埋める
-	// 全体に対して offset, limit を指定する
+// 列挙型のカラムが与えられた値のいずれかに一致するかのクエリを構築する
+// 配列のカラムはいずれかの要素が一致すれば良い
+func buildEnumQuery(values []string, dbColumnName string, selectArgs []interface{}, placeholderCount int) (string, int, []interface{}) {
+	if len(values) == 0 {
+		return "", placeholderCount, selectArgs
+	}
+
+	resQuery := ""
+	if dbColumnName == "instructional_type" {
+		resQuery = fmt.Sprintf(`%s::text = any($%d::text[])`, dbColumnName, placeholderCount)
+	}
+	if dbColumnName == "standard_registration_year" {
+		resQuery = fmt.Sprintf(`%s::text[] && $%d::text[]`, dbColumnName, placeholderCount)
+	}
+	placeholderCount++
+	selectArgs = append(selectArgs, pq.StringArray(values))
+	return resQuery, placeholderCount, selectArgs
+}
+
+// credits は varchar なので、数値として解釈できるもののみを比較の対象とする
+const creditsNumericExpr = `(case when credits ~ '^[0-9]+(\.[0-9]+)?$' then credits::numeric end)`
 
+func buildCreditsQuery(rawStr string, selectArgs []interface{}, placeholderCount int) (string, int, []interface{}) {
+	if rawStr == "" {
+		return "", placeholderCount, selectArgs
+	}
+
+	// 不正な値は validateSearchCourseQuery で弾かれている
+	minCredits, maxCredits, _ := util.ParseCreditsRange(rawStr)
+	conditions := []string{}
+	if minCredits != nil {
+		conditions = append(conditions, fmt.Sprintf(`%s >= $%d`, creditsNumericExpr, placeholderCount))
+		placeholderCount++
+		selectArgs = append(selectArgs, strconv.FormatFloat(*minCredits, 'f', -1, 64))
+	}
+	if maxCredits != nil {
+		conditions = append(conditions, fmt.Sprintf(`%s <= $%d`, creditsNumericExpr, placeholderCount))
+		placeholderCount++
+		selectArgs = append(selectArgs, strconv.FormatFloat(*maxCredits, 'f', -1, 64))
+	}
+	return strings.Join(conditions, " and "), placeholderCount, selectArgs
+}
+
+func buildGetFacetQuery(options domain.CourseQuery) (string, []interface{}, error) {
 	// PostgreSQL へ渡す $1, $2 プレースホルダーのインクリメントのカウンタ
 	placeholderCount := 1
 
 	// PostgreSQL へ渡す select 文のプレースホルダーに割り当てる変数を格納
 	selectArgs := []interface{}{}
 
-	// それぞれのカラムに対する小さなクエリの集合
-	queryLists := []string{}
-
 	// where 部分を構築
-	queryCourseName, placeholderCount, selectArgs := buildSimpleQuery(options.CourseName, options.CourseNameFilterType, "course_name", selectArgs, placeholderCount)
-	queryLists = append(queryLists, queryCourseName)
-	queryCourseOverview, placeholderCount, selectArgs := buildSimpleQuery(options.CourseOverview, options.CourseOverviewFilterType, "course_overview", selectArgs, placeholderCount)
-	queryLists = append(queryLists, queryCourseOverview)
-	queryCourseNumber, placeholderCount, selectArgs := buildSimpleQuery(options.CourseNumber, options.CourseOverviewFilterType, "course_number", selectArgs, placeholderCount)
-	queryLists = append(queryLists, queryCourseNumber)
-	queryPeriod, placeholderCount, selectArgs := buildArrayQuery(options.Period, options.CourseOverviewFilterType, "period_", selectArgs, placeholderCount)
-	queryLists = append(queryLists, queryPeriod)
-	queryTerm, _, selectArgs := buildArrayQuery(options.Term, options.CourseOverviewFilterType, "term", selectArgs, placeholderCount)
-	queryLists = append(queryLists, queryTerm)
-
-	// カラムごとに生成されたクエリを接続
-	queryWhere := connectEachSimpleQuery(queryLists, options.FilterType)
-
-	const queryHead = `select unnest(term) as term from courses `
-	queryWhere = "where " + queryWhere
-	if queryWhere == "where ()" {
-		queryWhere = ""
+	queryWhere, _, selectArgs, err := buildWhereQuery(options, selectArgs, placeholderCount)
+	if err != nil {
+		return "", nil, err
 	}
+
+	const queryHead = `select unnest(term) as term from ` + courseSearchFrom + ` `
 	return `select term, count(term) as term_count from(` + queryHead + queryWhere + `) as s1 group by term`, selectArgs, nil
 }
+
+// 曜時限ごとに該当する科目数を数えるクエリを構築する
+// 同じ曜時限が複数回入っている科目も 1 つと数える
+func buildGetPeriodFacetQuery(options domain.CourseQuery) (string, []interface{}, error) {
+	queryWhere, _, selectArgs, err := buildWhereQuery(options, []interface{}{}, 1)
+	if err != nil {
+		return "", nil, err
+	}
+
+	const queryHead = `select id, unnest(period_) as period from ` + courseSearchFrom + ` `
+	return `select period, count(distinct id) as count from (` + queryHead + queryWhere + `) as s1 where period <> '' group by period`, selectArgs, nil
+}
+
+// 集計の種類ごとの、科目 1 つから集計の値を取り出す式
+// 配列のカラムは unnest して要素ごとに数える
+var facetValueExprs = map[string]string{
+	domain.FacetKindTerm:                     "unnest(term)::text",
+	domain.FacetKindPeriod:                   "unnest(period_)",
+	domain.FacetKindInstructionalType:        "instructional_type::text",
+	domain.FacetKindCredits:                  "credits",
+	domain.FacetKindStandardRegistrationYear: "unnest(standard_registration_year)",
+	domain.FacetKindDepartment:               "substr(course_number, 1, 2)",
+	domain.FacetKindInstructor:               "unnest(instructor)",
+}
+
+// CourseQuery.Facets で要求された集計の、値ごとに該当する科目数を数えるクエリを構築する
+func buildGetFacetValuesQuery(options domain.CourseQuery, kind string) (string, []interface{}, error) {
+	// PostgreSQL へ渡す $1, $2 プレースホルダーのインクリメントのカウンタ
+	placeholderCount := 1
+
+	// PostgreSQL へ渡す select 文のプレースホルダーに割り当てる変数を格納
+	selectArgs := []interface{}{}
+
+	// where 部分を構築
+	queryWhere, placeholderCount, selectArgs, err := buildWhereQuery(options, selectArgs, placeholderCount)
+	if err != nil {
+		return "", nil, err
+	}
+
+	query, _, selectArgs, err := buildFacetCountQuery(kind, courseSearchFrom, queryWhere, selectArgs, placeholderCount)
+	if err != nil {
+		return "", nil, err
+	}
+	return query, selectArgs, nil
+}
+
+// from から queryWhere で絞り込んだ科目について、集計の値ごとに該当する科目数を数えるクエリを構築する
+// 同じ値が複数回入っている科目も 1 つと数える
+// 該当する科目の多い順、同じなら値の順に並べる
+func buildFacetCountQuery(kind string, from string, queryWhere string, selectArgs []interface{}, placeholderCount int) (string, int, []interface{}, error) {
+	valueExpr, ok := facetValueExprs[kind]
+	if !ok {
+		return "", placeholderCount, selectArgs, fmt.Errorf("unknown facet: %s", kind)
+	}
+
+	queryHead := `select id, ` + valueExpr + ` as value from ` + from + ` `
+	query := `select value, count(distinct id) as count from (` + queryHead + queryWhere + `) as s1 where value <> '' group by value order by count desc, value`
+	if kind == domain.FacetKindInstructor {
+		query += fmt.Sprintf(` limit $%d`, placeholderCount)
+		placeholderCount++
+		selectArgs = append(selectArgs, strconv.Itoa(domain.FacetInstructorLimit))
+	}
+	return query, placeholderCount, selectArgs, nil
+}
+
+// 重複を取り除いた集計の種類、指定された順
+func uniqueFacetKinds(kinds []string) []string {
+	unique := []string{}
+	for _, kind := range kinds {
+		if !util.Contains(unique, kind) {
+			unique = append(unique, kind)
+		}
+	}
+	return unique
+}
+
+// 検索結果と集計の元になる、年度などで絞り込んだ科目の select 文を構築する
+// 検索条件に該当するかを hit 列に、kinds[i] の集計の対象になるか (その集計の対象のカラムに対する条件を取り除いて該当するか) を facet_{i+1} 列に持つ
+// columns はそれに加えて計算する列
+func buildFacetFilteredQuery(options domain.CourseQuery, kinds []string, columns string, selectArgs []interface{}, placeholderCount int) (string, int, []interface{}, error) {
+	queryHit, placeholderCount, selectArgs, err := buildFilterCondition(options, selectArgs, placeholderCount)
+	if err != nil {
+		return "", placeholderCount, selectArgs, err
+	}
+	conditions := []string{queryHit}
+	for _, kind := range kinds {
+		facetOptions, err := options.WithoutFacetFilter(kind)
+		if err != nil {
+			return "", placeholderCount, selectArgs, err
+		}
+		var queryFacet string
+		queryFacet, placeholderCount, selectArgs, err = buildFilterCondition(facetOptions, selectArgs, placeholderCount)
+		if err != nil {
+			return "", placeholderCount, selectArgs, err
+		}
+		conditions = append(conditions, queryFacet)
+	}
+
+	// 条件が無いものは常に該当する
+	queryColumns := ""
+	queryAny := []string{}
+	always := false
+	for i, condition := range conditions {
+		columnName := "hit"
+		if i != 0 {
+			columnName = fmt.Sprintf("facet_%d", i)
+		}
+		if condition == "" {
+			always = true
+			queryColumns += `, true as ` + columnName
+			continue
+		}
+		queryAny = append(queryAny, "("+condition+")")
+		// like の対象が null のときなど、条件が null になる科目は該当しないものとする
+		queryColumns += fmt.Sprintf(`, coalesce(%s, false) as %s`, condition, columnName)
+	}
+
+	// 検索条件にもいずれの集計にも該当しない科目は取り除く
+	queryFilter := ""
+	if !always {
+		queryFilter = strings.Join(queryAny, " or ")
+	}
+	queryWhere, placeholderCount, selectArgs := buildScopedWhereQuery(options, queryFilter, selectArgs, placeholderCount)
+
+	return `select ` + courseColumns + queryColumns + columns + ` from ` + courseSearchFrom + ` ` + queryWhere, placeholderCount, selectArgs, nil
+}
+
+// buildFacetFilteredQuery の filtered から kinds の集計を求め、集計の種類をキーとする JSON にする式を構築する
+// {"term": [{"value": "1", "count": 2}, ...], ...}
+func buildFacetsExpr(kinds []string, selectArgs []interface{}, placeholderCount int) (string, int, []interface{}, error) {
+	pairs := []string{}
+	for i, kind := range kinds {
+		queryKind := fmt.Sprintf(`$%d::text`, placeholderCount)
+		placeholderCount++
+		selectArgs = append(selectArgs, kind)
+
+		var queryCount string
+		var err error
+		queryCount, placeholderCount, selectArgs, err = buildFacetCountQuery(kind, "filtered", fmt.Sprintf("where facet_%d", i+1), selectArgs, placeholderCount)
+		if err != nil {
+			return "", placeholderCount, selectArgs, err
+		}
+		queryValues := `(select coalesce(json_agg(json_build_object('value', value, 'count', count) order by count desc, value), '[]'::json) from (` + queryCount + `) as f)`
+		pairs = append(pairs, queryKind+", "+queryValues)
+	}
+	return `json_build_object(` + strings.Join(pairs, ", ") + `)`, placeholderCount, selectArgs, nil
+}
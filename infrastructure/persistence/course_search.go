@@ -0,0 +1,155 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sylms/azuki/util"
+)
+
+// 検索キーワードと比較するための、正規化した文字列を格納するテーブル
+// courses と id で対応し、courses に科目が追加・更新されるとトリガーで更新する
+const courseSearchTable = `create table if not exists course_search (
+	course_id integer primary key references courses (id) on delete cascade,
+	normalized_course_number text not null,
+	normalized_course_name text not null,
+	normalized_alt_course_name text not null,
+	normalized_course_overview text not null,
+	normalized_remarks text not null,
+	normalized_classroom text not null,
+	normalized_instructor text not null,
+	normalized_bigrams text[] not null,
+	version integer not null
+)`
+
+// 正規化した値の求め方 (normalize_for_search, course_search_bigrams, courseSearchColumns) の版
+// 求め方を変えたら増やすと、次の起動時に courseSearchBackfill が古い版の行を作り直す
+const courseSearchVersion = 1
+
+// normalized_bigrams, version が無いころに作った course_search に追加する
+// 列を追加したときにあった行は version が 0 になるので、この後の courseSearchBackfill で作り直される
+var courseSearchAddedColumns = []string{
+	`alter table course_search add column if not exists normalized_bigrams text[] not null default '{}'`,
+	`alter table course_search add column if not exists version integer not null default 0`,
+}
+
+// 検索条件を組み立てるときの from 句
+// course_search はトリガーで courses に追従するので、すべての科目に対応する行がある
+const courseSearchFrom = `courses left join course_search on course_search.course_id = courses.id`
+
+// course_search のカラムと、courses (c) からそれを求める式
+// キーワードは空白を含まないため、複数の教員にまたがって一致することはない
+var courseSearchColumns = []struct {
+	name string
+	expr string
+}{
+	{name: "normalized_course_number", expr: "normalize_for_search(c.course_number)"},
+	{name: "normalized_course_name", expr: "normalize_for_search(c.course_name)"},
+	{name: "normalized_alt_course_name", expr: "normalize_for_search(c.alt_course_name)"},
+	{name: "normalized_course_overview", expr: "normalize_for_search(c.course_overview)"},
+	{name: "normalized_remarks", expr: "normalize_for_search(c.remarks)"},
+	{name: "normalized_classroom", expr: "normalize_for_search(c.classroom)"},
+	{name: "normalized_instructor", expr: "normalize_for_search(array_to_string(c.instructor, ' '))"},
+	// keywordColumns の連続する 2 文字の組、カラムごとに取り出してまとめる
+	// pg_trgm は 2 文字のキーワードにインデックスを使えないので、こちらで絞り込む
+	{name: "normalized_bigrams", expr: "course_search_bigrams(normalize_for_search(c.course_name), normalize_for_search(c.alt_course_name), normalize_for_search(c.course_overview), normalize_for_search(c.remarks))"},
+	{name: "version", expr: strconv.Itoa(courseSearchVersion)},
+}
+
+// 検索キーワードと検索対象の文字列の両方に適用する正規化
+// 検索対象の文字列には同じことを SQL の normalize_for_search で行う
+func normalizeForSearch(text string) string {
+	return util.Normalize(text, util.NormalizeOptions{
+		FoldKana: true,
+	})
+}
+
+// normalizeForSearch と同じ正規化を行う SQL の関数
+// normalize は PostgreSQL 13 から使える
+func courseSearchNormalizeFunction() string {
+	katakana, hiragana := []rune{}, []rune{}
+	for r := 'ァ'; r <= 'ヶ'; r++ {
+		katakana = append(katakana, r)
+		hiragana = append(hiragana, r-('ァ'-'ぁ'))
+	}
+	// ローマ数字は util.Normalize と同じく、英字・数字と隣り合っていない i, v, x の並び全体が 1 から 39 の正しい表記のものだけを置き換える
+	return fmt.Sprintf(`create or replace function normalize_for_search(t text) returns text language plpgsql immutable strict as $$
+declare
+	v int;
+begin
+	t := lower(normalize(t, NFKC));
+	if t ~ '(?<![a-z0-9])[ivx]+(?![a-z0-9])' then
+		for v in 1..39 loop
+			t := regexp_replace(t, '(?<![a-z0-9])' || repeat('x', v / 10) || (array['', 'i', 'ii', 'iii', 'iv', 'v', 'vi', 'vii', 'viii', 'ix'])[v %% 10 + 1] || '(?![a-z0-9])', v::text, 'g');
+		end loop;
+	end if;
+	return translate(t, '%s', '%s');
+end
+$$`, string(katakana), string(hiragana))
+}
+
+// 文字列それぞれの連続する 2 文字の組、重複は除く
+// Go の keywordBigrams と同じく、空白も 1 文字として扱う
+const courseSearchBigramsFunction = `create or replace function course_search_bigrams(variadic texts text[]) returns text[] language sql immutable as $$
+select coalesce(array_agg(distinct substr(t, i, 2)), '{}') from unnest(texts) as t, generate_series(1, char_length(t) - 1) as i
+$$`
+
+// courses (c) から course_search に追加し、既にあれば更新する
+// where 句を付けて使う
+func courseSearchUpsert() string {
+	names, exprs, updates := []string{}, []string{}, []string{}
+	for _, column := range courseSearchColumns {
+		names = append(names, column.name)
+		exprs = append(exprs, column.expr)
+		updates = append(updates, fmt.Sprintf("%s = excluded.%s", column.name, column.name))
+	}
+	return fmt.Sprintf(`insert into course_search (course_id, %s) select c.id, %s from courses c %%s on conflict (course_id) do update set %s`,
+		strings.Join(names, ", "), strings.Join(exprs, ", "), strings.Join(updates, ", "))
+}
+
+// courses の行が追加・更新されたら course_search を更新するトリガー
+func courseSearchTrigger() []string {
+	return []string{
+		`create or replace function course_search_sync() returns trigger language plpgsql as $$
+begin
+	` + fmt.Sprintf(courseSearchUpsert(), `where c.id = new.id`) + `;
+	return null;
+end
+$$`,
+		`drop trigger if exists course_search_sync on courses`,
+		`create trigger course_search_sync after insert or update on courses for each row execute function course_search_sync()`,
+	}
+}
+
+// トリガーを作る前からあって course_search に無い科目と、古い版の行の科目だけを入れ直す
+// 普段の起動では対象が無いので、Migrate のトランザクションが courses をロックする時間は短い
+func courseSearchBackfill() string {
+	return fmt.Sprintf(courseSearchUpsert(), fmt.Sprintf(`where not exists (select 1 from course_search s where s.course_id = c.id and s.version = %d)`, courseSearchVersion))
+}
+
+// WaitForCourses で courses が作られたかを確かめる間隔
+const coursesPollInterval = 2 * time.Second
+
+// csv2sql が courses を作るまで待つ
+// docker-compose では azuki と csv2sql が同時に起動するので、Migrate の前に呼び出す
+func WaitForCourses(db *sqlx.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		var exists bool
+		err := db.Get(&exists, `select to_regclass('courses') is not null`)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.New("courses table is not created")
+		}
+		time.Sleep(coursesPollInterval)
+	}
+}
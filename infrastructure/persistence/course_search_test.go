@@ -0,0 +1,284 @@
+package persistence
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/sylms/azuki/domain"
+)
+
+func Test_normalize_for_search(t *testing.T) {
+	db, err := createDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// SQL の normalize_for_search は normalizeForSearch と同じ結果になる
+	texts := []string{
+		"ﾌﾟﾛｸﾞﾗﾐﾝｸﾞ",
+		"ＧＡ１０１０１",
+		"解析学II",
+		"解析学Ⅱ",
+		"Analysis ii, Analysis XXXIX",
+		"iiii vx vision 3v207 ix-x",
+		"情報科学類 カタカナ ヴァ ヷ ー",
+		"",
+	}
+	for _, text := range texts {
+		var got string
+		err = db.Get(&got, `select normalize_for_search($1)`, text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := normalizeForSearch(text)
+		if got != want {
+			t.Errorf("normalize_for_search(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func Test_coursePersistence_Search_insertedAfterMigrate(t *testing.T) {
+	db, err := createDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// csv2sql が azuki の起動後に新しい年度を追加したとき
+	id, err := insertTestCourse(db, "ZZ00001", "ﾃｽﾄ科目", "{1}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &coursePersistence{db: db}
+	tests := []struct {
+		name  string
+		query domain.CourseQuery
+	}{
+		{
+			name: "科目名",
+			query: domain.CourseQuery{
+				CourseName:           "てすと",
+				CourseNameFilterType: "and",
+			},
+		},
+		{
+			name: "科目番号",
+			query: domain.CourseQuery{
+				CourseNumber:           "zz0000",
+				CourseNumberFilterType: "and",
+			},
+		},
+		{
+			name: "否定の条件でも除かれない",
+			query: domain.CourseQuery{
+				Year:           domain.MultiValue{"2022"},
+				ExcludeRemarks: "オンライン",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.Limit = 10
+			got, err := p.Search(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Courses) != 1 || got.Courses[0].ID != id {
+				t.Errorf("coursePersistence.Search() = %+v, want only %d", got.Courses, id)
+			}
+		})
+	}
+
+	// 更新にも追従する
+	_, err = db.Exec(`update courses set course_name = 'ﾍﾞﾂﾉ科目' where id = $1`, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.Search(domain.CourseQuery{CourseName: "べつの", CourseNameFilterType: "and", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Courses) != 1 || got.Courses[0].ID != id {
+		t.Errorf("coursePersistence.Search() after update = %+v, want only %d", got.Courses, id)
+	}
+}
+
+func Test_coursePersistence_Search_bigramPrefilter(t *testing.T) {
+	db, err := createDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Migrate の後に追加した科目も normalized_bigrams で絞り込める
+	_, err = insertTestCourse(db, "ZZ00001", "ﾃｽﾄ科目", "{1}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &coursePersistence{db: db}
+	// normalized_bigrams で絞り込んでも、courses を直接 like で調べたときと同じ科目が見つかる
+	keywords := []string{"情報", "法制", "てす", "線形代数", "情"}
+	for _, keyword := range keywords {
+		t.Run(keyword, func(t *testing.T) {
+			var want []int
+			err := db.Select(&want, `select id from courses where normalize_for_search(course_name) like $1 or normalize_for_search(alt_course_name) like $1 or normalize_for_search(course_overview) like $1 or normalize_for_search(remarks) like $1 order by id`,
+				"%"+normalizeForSearch(keyword)+"%")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(want) == 0 {
+				t.Fatalf("no course contains %s", keyword)
+			}
+
+			got, err := p.Search(domain.CourseQuery{Keyword: keyword, FilterType: "and", Limit: 1000})
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotIDs := []int{}
+			for _, course := range got.Courses {
+				gotIDs = append(gotIDs, course.ID)
+			}
+			sort.Ints(gotIDs)
+			if !reflect.DeepEqual(gotIDs, want) {
+				t.Errorf("coursePersistence.Search() = %v, want %v", gotIDs, want)
+			}
+		})
+	}
+}
+
+func TestMigrate_backfillOnlyMissingOrStale(t *testing.T) {
+	db, err := createDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []int
+	err = db.Select(&ids, `select id from courses order by id limit 3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 {
+		t.Fatalf("got %d courses, want 3", len(ids))
+	}
+	stale, current, missing := ids[0], ids[1], ids[2]
+
+	// 古い版の行、今の版の行、course_search に無い科目を用意する
+	_, err = db.Exec(`update course_search set normalized_course_name = 'stale', version = 0 where course_id = $1`, stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`update course_search set normalized_course_name = 'current' where course_id = $1`, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`delete from course_search where course_id = $1`, missing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Migrate(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		id   int
+		// 空なら courses から正規化し直した値になっている
+		want string
+	}{
+		{name: "古い版の行は作り直す", id: stale},
+		{name: "今の版の行はそのまま", id: current, want: "current"},
+		{name: "無い科目は入れる", id: missing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got struct {
+				Name       string `db:"normalized_course_name"`
+				Normalized string `db:"normalized"`
+				Version    int    `db:"version"`
+			}
+			err := db.Get(&got, `select s.normalized_course_name, normalize_for_search(c.course_name) as normalized, s.version from course_search s join courses c on c.id = s.course_id where s.course_id = $1`, tt.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want
+			if want == "" {
+				want = got.Normalized
+			}
+			if got.Name != want {
+				t.Errorf("normalized_course_name = %q, want %q", got.Name, want)
+			}
+			if got.Version != courseSearchVersion {
+				t.Errorf("version = %d, want %d", got.Version, courseSearchVersion)
+			}
+		})
+	}
+}
+
+// キーワードの文字数ごとに、絞り込みにインデックスを使えるか
+// 1 文字: 使えない、2 文字: normalized_bigrams、3 文字以上: normalized_bigrams か trigram
+func Test_buildKeywordCondition_index(t *testing.T) {
+	db, err := createDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		keyword   string
+		wantIndex bool
+	}{
+		{keyword: "情", wantIndex: false},
+		{keyword: "情報", wantIndex: true},
+		{keyword: "情報科", wantIndex: true},
+		{keyword: "線形代数", wantIndex: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.keyword, func(t *testing.T) {
+			tx, err := db.Beginx()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tx.Rollback()
+			// テストデータは少ないので、インデックスを使えるときは必ず使わせる
+			_, err = tx.Exec(`set local enable_seqscan = off`)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			condition, _, selectArgs := buildKeywordCondition(normalizeForSearch(tt.keyword), []interface{}{}, 1)
+			var plan []string
+			err = tx.Select(&plan, `explain select course_id from course_search where `+condition, selectArgs...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := strings.Contains(strings.Join(plan, "\n"), "Index Scan")
+			if got != tt.wantIndex {
+				t.Errorf("index scan = %v, want %v\n%s", got, tt.wantIndex, strings.Join(plan, "\n"))
+			}
+		})
+	}
+}
+
+func Test_course_search_bigrams(t *testing.T) {
+	db, err := createDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// SQL の course_search_bigrams は keywordBigrams と同じ組を取り出す
+	texts := []string{"線形代数", "情報", "情", "ab cd", "ああああ", ""}
+	for _, text := range texts {
+		var got []string
+		err = db.Select(&got, `select unnest(course_search_bigrams($1)) order by 1`, text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string(keywordBigrams(text))
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) && !(len(got) == 0 && len(want) == 0) {
+			t.Errorf("course_search_bigrams(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
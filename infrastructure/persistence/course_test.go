@@ -1,16 +1,32 @@
 package persistence
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/jmoiron/sqlx"
 	"github.com/sylms/azuki/domain"
 	"github.com/sylms/azuki/testutils"
 )
 
-func Test_coursePersistence_Search(t *testing.T) {
+// testutils.CreateDB に加えて、検索に必要なテーブルを用意する
+func createDB() (*sqlx.DB, error) {
 	db, err := testutils.CreateDB()
+	if err != nil {
+		return nil, err
+	}
+	err = Migrate(db)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func Test_coursePersistence_Search(t *testing.T) {
+	db, err := createDB()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -452,87 +468,2481 @@ func Test_coursePersistence_Search(t *testing.T) {
 				},
 			},
 		},
-		// TODO: CourseOverview + CourseOverviewFilterType = {and,or} の確認
-		// TODO: CourseName, CourseOverview たちをまとめる FilterType の確認
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			p := tt.fields
-			got, err := p.Search(tt.args.query)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("coursePersistence.Search() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if diff := cmp.Diff(got, tt.want, cmpopts.IgnoreFields(domain.Course{}, "CSVUpdatedAt", "CreatedAt", "UpdatedAt")); diff != "" {
-				t.Errorf("coursePersistence.Search() mismatch: (-got +want)\n%s", diff)
-			}
-		})
-	}
-}
-
-func Test_coursePersistence_Facet(t *testing.T) {
-	db, err := testutils.CreateDB()
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	type args struct {
-		query domain.CourseQuery
-	}
-	tests := []struct {
-		name    string
-		fields  coursePersistence
-		args    args
-		want    []*domain.Facet
-		wantErr bool
-	}{
 		{
-			name: "temp",
+			name: "Instructor の部分一致検索が動作する",
 			fields: coursePersistence{
 				db: db,
 			},
 			args: args{
 				query: domain.CourseQuery{
-					CourseName:           "情報",
+					Instructor:           "天笠",
+					InstructorFilterType: "and",
+					Limit:                50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11931"],
+				testdata1Courses["GB11956"],
+			},
+		},
+		{
+			name: "Instructor の名前のみでも検索できる",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Instructor:           "武志",
+					InstructorFilterType: "and",
+					Limit:                50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB10244"],
+			},
+		},
+		{
+			name: "スペース区切りで複数 Instructor を与え InstructorFilterType = and がきちんと働く",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Instructor:           "西出 亀山",
+					InstructorFilterType: "and",
+					Limit:                50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA15111"],
+			},
+		},
+		{
+			name: "スペース区切りで複数 Instructor を与え InstructorFilterType = or がきちんと働く",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Instructor:           "西出 馬場",
+					InstructorFilterType: "or",
+					Limit:                50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA12301"],
+				testdata1Courses["GA15111"],
+				testdata1Courses["GB11601"],
+				testdata1Courses["GB11611"],
+			},
+		},
+		{
+			name: "Instructor と CourseName を FilterType = and で組み合わせる",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:           "実験",
 					CourseNameFilterType: "and",
+					Instructor:           "天笠",
+					InstructorFilterType: "and",
+					FilterType:           "and",
 					Limit:                50,
 				},
 			},
-			want: []*domain.Facet{
-				{
-					Term:      1,
-					TermCount: 1,
+			want: []*domain.Course{
+				testdata1Courses["GB11956"],
+			},
+		},
+		{
+			name: "複数の InstructionalType のいずれかに一致する科目を返す",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					InstructionalType: domain.MultiValue{"4", "6"},
+					Limit:             50,
 				},
-				{
-					Term:      5,
-					TermCount: 2,
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB10244"],
+				testdata1Courses["GB10414"],
+				testdata1Courses["GB10524"],
+				testdata1Courses["GB11404"],
+				testdata1Courses["GB11514"],
+				testdata1Courses["GB11956"],
+			},
+		},
+		{
+			name: "Credits を単一の値で指定",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Credits: "3.0",
+					Limit:   50,
 				},
-				{
-					Term:      2,
-					TermCount: 2,
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11931"],
+			},
+		},
+		{
+			name: "Credits を上限のみの範囲で指定",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Credits: "-1.0",
+					Limit:   50,
 				},
-				{
-					Term:      4,
-					TermCount: 1,
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA12301"],
+				testdata1Courses["GA14201"],
+				testdata1Courses["GB11514"],
+			},
+		},
+		{
+			name: "Credits を範囲で指定",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Credits: "2.5-3.5",
+					Limit:   50,
 				},
-				{
-					Term:      3,
-					TermCount: 1,
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11931"],
+			},
+		},
+		{
+			name: "StandardRegistrationYear は複数年次にまたがる科目にも一致する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					StandardRegistrationYear: domain.MultiValue{"3"},
+					Limit:                    50,
 				},
 			},
-			wantErr: false,
+			want: []*domain.Course{
+				testdata1Courses["GB10414"],
+			},
 		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			p := tt.fields
-			got, err := p.Facet(tt.args.query)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("coursePersistence.Facet() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if diff := cmp.Diff(got, tt.want); diff != "" {
-				t.Errorf("coursePersistence.Facet() mismatch: (-got +want)\n%s", diff)
+		{
+			name: "StandardRegistrationYear = ? の科目は存在しない",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					StandardRegistrationYear: domain.MultiValue{"?"},
+					Limit:                    50,
+				},
+			},
+			want: nil,
+		},
+		{
+			name: "InstructionalType と Credits を FilterType = and で組み合わせる",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					InstructionalType: domain.MultiValue{"1"},
+					Credits:           "3.0",
+					FilterType:        "and",
+					Limit:             50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11931"],
+			},
+		},
+		{
+			name: "Classroom で建物を前方一致検索する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Classroom:           "3A",
+					ClassroomFilterType: "and",
+					Limit:               50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA15111"],
+				testdata1Courses["GA18212"],
+				testdata1Courses["GB10244"],
+				testdata1Courses["GB10414"],
+				testdata1Courses["GB11404"],
+				testdata1Courses["GB11514"],
+				testdata1Courses["GB11601"],
+				testdata1Courses["GB11611"],
+				testdata1Courses["GB11621"],
+			},
+		},
+		{
+			name: "Classroom がカンマ区切りで複数ある場合はそれぞれに前方一致する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Classroom:           "3C2",
+					ClassroomFilterType: "and",
+					Limit:               50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11956"],
+			},
+		},
+		{
+			name: "Remarks のキーワード検索が動作する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Remarks:           "対面",
+					RemarksFilterType: "and",
+					Limit:             50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA15241"],
+				testdata1Courses["GB10244"],
+				testdata1Courses["GB11514"],
+			},
+		},
+		{
+			name: "スペース区切りで複数 Remarks を与え RemarksFilterType = and がきちんと働く",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Remarks:           "同時双方向 実験",
+					RemarksFilterType: "and",
+					Limit:             50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11956"],
+			},
+		},
+		{
+			name: "スペース区切りで複数 Remarks を与え RemarksFilterType = or がきちんと働く",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Remarks:           "対面 同時双方向",
+					RemarksFilterType: "or",
+					Limit:             50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA15241"],
+				testdata1Courses["GB10244"],
+				testdata1Courses["GB11514"],
+				testdata1Courses["GB11931"],
+				testdata1Courses["GB11956"],
+			},
+		},
+		{
+			name: "スペース区切りで複数 CourseNumber を与えると CourseNumberFilterType で検索する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseNumber:           "GA101 GB115",
+					CourseNumberFilterType: "or",
+					Limit:                  50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA10101"],
+				testdata1Courses["GB11514"],
+			},
+		},
+		{
+			name: "CourseNumberFilterType を省略すると CourseOverviewFilterType で検索する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseNumber:             "GA101 GB115",
+					CourseOverviewFilterType: "or",
+					Limit:                    50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA10101"],
+				testdata1Courses["GB11514"],
+			},
+		},
+		{
+			name: "CourseNumberFilterType は CourseOverviewFilterType より優先する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseNumber:             "GA101 GB115",
+					CourseNumberFilterType:   "or",
+					CourseOverviewFilterType: "and",
+					Limit:                    50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA10101"],
+				testdata1Courses["GB11514"],
+			},
+		},
+		{
+			name: "Year を指定して検索",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:           "情報社会と法制度",
+					CourseNameFilterType: "and",
+					Year:                 domain.MultiValue{"2021"},
+					Limit:                50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA10101"],
+			},
+		},
+		{
+			name: "Year に latest を指定すると最新の年度を検索する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:           "情報社会と法制度",
+					CourseNameFilterType: "and",
+					Year:                 domain.MultiValue{domain.YearLatest},
+					Limit:                50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA10101"],
+			},
+		},
+		{
+			name: "登録されていない Year を指定すると何も返さない",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:           "情報社会と法制度",
+					CourseNameFilterType: "and",
+					Year:                 domain.MultiValue{"2020"},
+					Limit:                50,
+				},
+			},
+			want: nil,
+		},
+		{
+			name: "登録されていない Year と latest を組み合わせる",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:           "情報社会と法制度",
+					CourseNameFilterType: "and",
+					Year:                 domain.MultiValue{"2020", domain.YearLatest},
+					Limit:                50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA10101"],
+			},
+		},
+		{
+			name: "単位数の降順、科目番号の昇順で並べる",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseNumber: "GB11",
+					Sort: []domain.CourseSort{
+						{
+							Key:   domain.SortKeyCredits,
+							Order: domain.SortOrderDesc,
+						},
+						{
+							Key: domain.SortKeyCourseNumber,
+						},
+					},
+					Limit: 50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11931"],
+				testdata1Courses["GB11404"],
+				testdata1Courses["GB11601"],
+				testdata1Courses["GB11611"],
+				testdata1Courses["GB11621"],
+				testdata1Courses["GB11956"],
+				testdata1Courses["GB11514"],
+			},
+		},
+		{
+			name: "週の最初の授業の曜時限で並べる",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:           "線形代数 微分",
+					CourseNameFilterType: "or",
+					Sort: []domain.CourseSort{
+						{
+							Key: domain.SortKeyPeriod,
+						},
+					},
+					Limit: 50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB10244"],
+				testdata1Courses["GB10524"],
+				testdata1Courses["GA15241"],
+				testdata1Courses["GA15311"],
+				testdata1Courses["GA15341"],
+			},
+		},
+		{
+			name: "科目名を日本語の順序で並べる",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseNumber: "GA12301 GB11514 GB11931 GB11956 GA18212 GB11404",
+					Sort: []domain.CourseSort{
+						{
+							Key: domain.SortKeyCourseName,
+						},
+					},
+					Limit: 50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA12301"],
+				testdata1Courses["GB11514"],
+				testdata1Courses["GB11931"],
+				testdata1Courses["GB11956"],
+				testdata1Courses["GA18212"],
+				testdata1Courses["GB11404"],
+			},
+		},
+		{
+			name: "標準履修年次の降順で並べる",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseNumber: "GB10",
+					Sort: []domain.CourseSort{
+						{
+							Key:   domain.SortKeyStandardRegistrationYear,
+							Order: domain.SortOrderDesc,
+						},
+					},
+					Limit: 50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB10414"],
+				testdata1Courses["GB10244"],
+				testdata1Courses["GB10524"],
+			},
+		},
+		{
+			name: "キーワードに対する関連度で並べる",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:           "線形代数",
+					CourseNameFilterType: "and",
+					Sort: []domain.CourseSort{
+						{
+							Key: domain.SortKeyRelevance,
+						},
+					},
+					Limit: 50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB10244"],
+				testdata1Courses["GA15241"],
+			},
+		},
+		{
+			name: "半角カナで検索しても一致する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:           "ﾌﾟﾛｸﾞﾗﾐﾝｸﾞ",
+					CourseNameFilterType: "and",
+					Limit:                50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA18212"],
+			},
+		},
+		{
+			name: "ひらがなで検索してもカタカナに一致する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:           "しみゅれーしょん",
+					CourseNameFilterType: "and",
+					Limit:                50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11514"],
+			},
+		},
+		{
+			name: "全角英数字の科目番号で検索しても一致する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseNumber: "ＧＡ１８",
+					Limit:        50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA18212"],
+			},
+		},
+		{
+			name: "アラビア数字で検索してもローマ数字に一致する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:           "解析学2",
+					CourseNameFilterType: "and",
+					Limit:                50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB10414"],
+			},
+		},
+		{
+			name: "検索式で除外する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Q:          "線形代数 -空間",
+					FilterType: "and",
+					Limit:      50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA15241"],
+			},
+		},
+		{
+			name: "検索式で OR とカラムを指定する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Q:          "instructor:天笠 OR name:統計学",
+					FilterType: "and",
+					Limit:      50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11621"],
+				testdata1Courses["GB11931"],
+				testdata1Courses["GB11956"],
+			},
+		},
+		{
+			name: "検索式の語句とカラムの除外",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Q:          `name:"データ構造とアルゴリズム" -name:実験`,
+					FilterType: "and",
+					Limit:      50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11931"],
+			},
+		},
+		{
+			name: "検索式と他の条件は and でつなぐ",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Q:          "instructor:天笠 OR name:統計学",
+					Credits:    "3.0",
+					FilterType: "or",
+					Limit:      50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11931"],
+			},
+		},
+		{
+			name: "検索条件の木で or, not を組み合わせる",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Filter: &domain.Filter{
+						And: []*domain.Filter{
+							{
+								Or: []*domain.Filter{
+									{Field: domain.FilterFieldCourseName, Value: domain.MultiValue{"情報"}},
+									{Field: domain.FilterFieldCourseOverview, Value: domain.MultiValue{"情報"}},
+								},
+							},
+							{Field: domain.FilterFieldTerm, Value: domain.MultiValue{"秋AB"}},
+							{
+								Not: &domain.Filter{Field: domain.FilterFieldPeriod, Value: domain.MultiValue{"月5月6"}},
+							},
+						},
+					},
+					FilterType: "and",
+					Limit:      50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA10201"],
+				testdata1Courses["GA12301"],
+				testdata1Courses["GB11404"],
+			},
+		},
+		{
+			name: "検索条件の木の葉に複数の値を指定するといずれかに一致する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Filter: &domain.Filter{
+						Field: domain.FilterFieldInstructor,
+						Value: domain.MultiValue{"天笠", "秋本"},
+					},
+					FilterType: "and",
+					Limit:      50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11621"],
+				testdata1Courses["GB11931"],
+				testdata1Courses["GB11956"],
+			},
+		},
+		{
+			name: "Period = 月3月4 の既定の一致のさせ方では月5 もある科目は含まれない",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Period:     "月3月4",
+					FilterType: "and",
+					Limit:      50,
+				},
+			},
+			want: nil,
+		},
+		{
+			name: "Period = 月3月4 で PeriodMatchMode = overlaps",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Period:          "月3月4",
+					PeriodMatchMode: domain.MatchModeOverlaps,
+					FilterType:      "and",
+					Limit:           50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11956"],
+			},
+		},
+		{
+			name: "Period = 月1月2 で PeriodMatchMode = contains_all",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Period:          "月1月2",
+					PeriodMatchMode: domain.MatchModeContainsAll,
+					FilterType:      "and",
+					Limit:           50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB10244"],
+				testdata1Courses["GB11931"],
+			},
+		},
+		{
+			name: "Period = 月1 で PeriodMatchMode = contains_all",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Period:          "月1",
+					PeriodMatchMode: domain.MatchModeContainsAll,
+					FilterType:      "and",
+					Limit:           50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB10244"],
+				testdata1Courses["GB11931"],
+			},
+		},
+		{
+			name: "Period = 月3月4 で PeriodMatchMode = exact",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Period:          "月3月4",
+					PeriodMatchMode: domain.MatchModeExact,
+					FilterType:      "and",
+					Limit:           50,
+				},
+			},
+			want: nil,
+		},
+		{
+			name: "PeriodMatchMode = exact は曜時限の重複と順序を区別しない",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Period:          "月5月4月3",
+					PeriodMatchMode: domain.MatchModeExact,
+					FilterType:      "and",
+					Limit:           50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11956"],
+			},
+		},
+		{
+			name: "Term = 秋C で TermMatchMode = overlaps",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Term:          "秋C",
+					TermMatchMode: domain.MatchModeOverlaps,
+					FilterType:    "and",
+					Limit:         50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11514"],
+				testdata1Courses["GB11931"],
+				testdata1Courses["GB11956"],
+			},
+		},
+		{
+			name: "Term = 秋ABC で TermMatchMode = exact",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Term:          "秋ABC",
+					TermMatchMode: domain.MatchModeExact,
+					FilterType:    "and",
+					Limit:         50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11931"],
+				testdata1Courses["GB11956"],
+			},
+		},
+		{
+			name: "PeriodMatchMode は検索式の period にも適用する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Q:               "period:月5",
+					PeriodMatchMode: domain.MatchModeOverlaps,
+					FilterType:      "and",
+					Limit:           50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA10101"],
+				testdata1Courses["GB11956"],
+			},
+		},
+		{
+			name: "検索条件の木の葉で指定した一致のさせ方を優先する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Filter: &domain.Filter{
+						Field:     domain.FilterFieldPeriod,
+						Value:     domain.MultiValue{"月5"},
+						MatchMode: domain.MatchModeOverlaps,
+					},
+					PeriodMatchMode: domain.MatchModeExact,
+					FilterType:      "and",
+					Limit:           50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA10101"],
+				testdata1Courses["GB11956"],
+			},
+		},
+		{
+			name: "ExcludeTerm で秋A, 秋B に開講する科目を除く",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					ExcludeTerm: "秋A秋B",
+					FilterType:  "and",
+					Limit:       50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA14201"],
+				testdata1Courses["GA15111"],
+				testdata1Courses["GA15241"],
+				testdata1Courses["GB10244"],
+				testdata1Courses["GB11514"],
+			},
+		},
+		{
+			name: "ExcludePeriod で月1 または月3 がある科目を除く",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Period:        "月1月2月3月4月5月6",
+					ExcludePeriod: "月1月3",
+					FilterType:    "and",
+					Limit:         50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA10101"],
+			},
+		},
+		{
+			name: "ExcludeInstructionalType で講義を除く",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseNumber:             "GB1",
+					ExcludeInstructionalType: domain.MultiValue{"1"},
+					FilterType:               "and",
+					Limit:                    50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB10244"],
+				testdata1Courses["GB10414"],
+				testdata1Courses["GB10524"],
+				testdata1Courses["GB11404"],
+				testdata1Courses["GB11514"],
+				testdata1Courses["GB11956"],
+			},
+		},
+		{
+			name: "ExcludeRemarks でいずれかのキーワードを備考に含む科目を除く",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseNumber:   "GB1",
+					ExcludeRemarks: "対面 同時双方向",
+					FilterType:     "and",
+					Limit:          50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB10414"],
+				testdata1Courses["GB10524"],
+				testdata1Courses["GB11404"],
+				testdata1Courses["GB11601"],
+				testdata1Courses["GB11611"],
+				testdata1Courses["GB11621"],
+			},
+		},
+		{
+			name: "除外する条件は FilterType = or でも and でつなぐ",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseNumber:         "GB116",
+					Instructor:           "天笠",
+					InstructorFilterType: "and",
+					ExcludeTerm:          "秋C",
+					FilterType:           "or",
+					Limit:                50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11601"],
+				testdata1Courses["GB11611"],
+				testdata1Courses["GB11621"],
+			},
+		},
+		{
+			name: "Occupied のコマと重なる科目を除く",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseNumber: "GB11",
+					Occupied: []domain.TimetableCell{
+						{Term: 4, Period: "月1"},
+						{Term: 6, Period: "木1"},
+					},
+					FilterType: "and",
+					Limit:      50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11404"],
+				testdata1Courses["GB11601"],
+				testdata1Courses["GB11611"],
+				testdata1Courses["GB11621"],
+				testdata1Courses["GB11956"],
+			},
+		},
+		{
+			name: "Occupied は開講時期と曜時限の両方が重なるときだけ除く",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseNumber: "GA15",
+					Occupied: []domain.TimetableCell{
+						{Term: 3, Period: "金3"},
+					},
+					FilterType: "and",
+					Limit:      50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA15111"],
+				testdata1Courses["GA15311"],
+				testdata1Courses["GA15341"],
+			},
+		},
+		// TODO: CourseOverview + CourseOverviewFilterType = {and,or} の確認
+		// TODO: CourseName, CourseOverview たちをまとめる FilterType の確認
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.fields
+			got, err := p.Search(tt.args.query)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("coursePersistence.Search() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if diff := cmp.Diff(got.Courses, tt.want, cmpopts.IgnoreFields(domain.Course{}, "CSVUpdatedAt", "CreatedAt", "UpdatedAt")); diff != "" {
+				t.Errorf("coursePersistence.Search() mismatch: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_coursePersistence_Search_excludeTerm(t *testing.T) {
+	db, err := createDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// testdata1.sql には通年・春学期・秋学期の科目が無いので追加する
+	for _, course := range []struct {
+		number string
+		term   string
+	}{
+		{number: "ZZ00001", term: "{9}"},
+		{number: "ZZ00002", term: "{10}"},
+		{number: "ZZ00003", term: "{11}"},
+		{number: "ZZ00004", term: "{1,2}"},
+		{number: "ZZ00005", term: "{7}"},
+	} {
+		_, err := insertTestCourse(db, course.number, "開講時期のテスト", course.term)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := &coursePersistence{db: db}
+	tests := []struct {
+		name        string
+		excludeTerm string
+		want        []string
+	}{
+		{
+			name:        "春A は通年と春学期の科目も除く",
+			excludeTerm: "春A",
+			want:        []string{"ZZ00003", "ZZ00005"},
+		},
+		{
+			name:        "秋C は通年と秋学期の科目も除く",
+			excludeTerm: "秋C",
+			want:        []string{"ZZ00002", "ZZ00004", "ZZ00005"},
+		},
+		{
+			name:        "通年はすべてのモジュールの科目を除く",
+			excludeTerm: "通年",
+			want:        []string{"ZZ00005"},
+		},
+		{
+			name:        "春学期は春A から春C の科目も除く",
+			excludeTerm: "春学期",
+			want:        []string{"ZZ00003", "ZZ00005"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.Search(domain.CourseQuery{
+				CourseNumber:           "ZZ",
+				CourseNumberFilterType: "and",
+				ExcludeTerm:            tt.excludeTerm,
+				FilterType:             "and",
+				Limit:                  50,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotNumbers := []string{}
+			for _, course := range got.Courses {
+				gotNumbers = append(gotNumbers, course.CourseNumber)
+			}
+			if diff := cmp.Diff(gotNumbers, tt.want); diff != "" {
+				t.Errorf("coursePersistence.Search() mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_coursePersistence_Search_total(t *testing.T) {
+	db, err := createDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		query domain.CourseQuery
+	}
+	tests := []struct {
+		name      string
+		fields    coursePersistence
+		args      args
+		want      []*domain.Course
+		wantTotal int
+		wantErr   bool
+	}{
+		{
+			name: "limit, offset を適用する前の件数を返す",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Instructor:           "天笠",
+					InstructorFilterType: "and",
+					Limit:                1,
+					Offset:               1,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11956"],
+			},
+			wantTotal: 2,
+		},
+		{
+			name: "offset が該当件数を超えていても件数を返す",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Instructor:           "天笠",
+					InstructorFilterType: "and",
+					Limit:                1,
+					Offset:               5,
+				},
+			},
+			want:      nil,
+			wantTotal: 2,
+		},
+		{
+			name: "limit 0 は科目を返さずに件数だけを返す",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Instructor:           "天笠",
+					InstructorFilterType: "and",
+					Limit:                0,
+				},
+			},
+			want:      nil,
+			wantTotal: 2,
+		},
+		{
+			name: "該当する科目が無い",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:           "存在しない科目",
+					CourseNameFilterType: "and",
+					Limit:                50,
+				},
+			},
+			want:      nil,
+			wantTotal: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.fields
+			got, err := p.Search(tt.args.query)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("coursePersistence.Search() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if diff := cmp.Diff(got.Courses, tt.want, cmpopts.IgnoreFields(domain.Course{}, "CSVUpdatedAt", "CreatedAt", "UpdatedAt")); diff != "" {
+				t.Errorf("coursePersistence.Search() mismatch: (-got +want)\n%s", diff)
+			}
+			if got.Total != tt.wantTotal {
+				t.Errorf("coursePersistence.Search() total = %d, want %d", got.Total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func Test_coursePersistence_Search_cursor(t *testing.T) {
+	db, err := createDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		fields  coursePersistence
+		query   domain.CourseQuery
+		wantIDs [][]int
+	}{
+		{
+			name: "カーソルをたどってすべてのページを取得できる",
+			fields: coursePersistence{
+				db: db,
+			},
+			query: domain.CourseQuery{
+				CourseName:           "情報",
+				CourseNameFilterType: "and",
+				Limit:                2,
+			},
+			wantIDs: [][]int{
+				{18010, 18014},
+				{18020, 18022},
+			},
+		},
+		{
+			name: "FilterType = or で組み合わせた条件でもカーソルをたどれる",
+			fields: coursePersistence{
+				db: db,
+			},
+			query: domain.CourseQuery{
+				CourseName:           "線形代数",
+				CourseNameFilterType: "and",
+				Instructor:           "天笠",
+				InstructorFilterType: "and",
+				FilterType:           "or",
+				Limit:                3,
+			},
+			wantIDs: [][]int{
+				{18029, 18047, 18066},
+				{18067},
+			},
+		},
+		{
+			name: "昇順・降順が混ざった並び順でもカーソルをたどれる",
+			fields: coursePersistence{
+				db: db,
+			},
+			query: domain.CourseQuery{
+				CourseNumber: "GB11",
+				Sort: []domain.CourseSort{
+					{
+						Key:   domain.SortKeyCredits,
+						Order: domain.SortOrderDesc,
+					},
+				},
+				Limit: 3,
+			},
+			wantIDs: [][]int{
+				{18066, 18060, 18062},
+				{18063, 18064, 18067},
+				{18061},
+			},
+		},
+		{
+			name: "科目名の順でカーソルをたどれる",
+			fields: coursePersistence{
+				db: db,
+			},
+			query: domain.CourseQuery{
+				CourseNumber: "GA12301 GB11514 GB11931 GB11956 GA18212 GB11404",
+				Sort: []domain.CourseSort{
+					{
+						Key: domain.SortKeyCourseName,
+					},
+				},
+				Limit: 4,
+			},
+			wantIDs: [][]int{
+				{18014, 18061, 18066, 18067},
+				{18034, 18060},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.fields
+			query := tt.query
+			for page, wantIDs := range tt.wantIDs {
+				got, err := p.Search(query)
+				if err != nil {
+					t.Fatalf("coursePersistence.Search() page %d error = %v", page, err)
+				}
+
+				gotIDs := []int{}
+				for _, course := range got.Courses {
+					gotIDs = append(gotIDs, course.ID)
+				}
+				if diff := cmp.Diff(gotIDs, wantIDs); diff != "" {
+					t.Errorf("coursePersistence.Search() page %d mismatch: (-got +want)\n%s", page, diff)
+				}
+
+				wantHasNext := page != len(tt.wantIDs)-1
+				if got.HasNext != wantHasNext {
+					t.Errorf("coursePersistence.Search() page %d HasNext = %v, want %v", page, got.HasNext, wantHasNext)
+				}
+				if wantHasNext == (got.NextCursor == "") {
+					t.Errorf("coursePersistence.Search() page %d NextCursor = %q", page, got.NextCursor)
+				}
+
+				query.Cursor = got.NextCursor
+			}
+		})
+	}
+}
+
+func Test_coursePersistence_Search_facets(t *testing.T) {
+	db, err := createDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		fields     coursePersistence
+		query      domain.CourseQuery
+		want       []*domain.Course
+		wantTotal  int
+		wantFacets map[string][]*domain.FacetValue
+	}{
+		{
+			name: "検索結果と合わせて集計の対象のカラムに対する条件を取り除いた集計を返す",
+			fields: coursePersistence{
+				db: db,
+			},
+			query: domain.CourseQuery{
+				Instructor:           "天笠",
+				InstructorFilterType: "and",
+				Credits:              "3.0",
+				FilterType:           "and",
+				Facets:               []string{domain.FacetKindCredits, domain.FacetKindStandardRegistrationYear},
+				Limit:                50,
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11931"],
+			},
+			wantTotal: 1,
+			wantFacets: map[string][]*domain.FacetValue{
+				domain.FacetKindCredits: {
+					{Value: "2.0", Count: 1},
+					{Value: "3.0", Count: 1},
+				},
+				domain.FacetKindStandardRegistrationYear: {
+					{Value: "2", Count: 1},
+				},
+			},
+		},
+		{
+			name: "検索式・検索条件の木の集計の対象のカラムに対する条件も取り除く",
+			fields: coursePersistence{
+				db: db,
+			},
+			query: domain.CourseQuery{
+				Instructor:           "天笠",
+				InstructorFilterType: "and",
+				Q:                    "credits:3.0 grade:2",
+				Filter:               &domain.Filter{Not: &domain.Filter{Field: domain.FilterFieldCredits, Value: domain.MultiValue{"2.0"}}},
+				FilterType:           "and",
+				Facets:               []string{domain.FacetKindCredits},
+				Limit:                50,
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11931"],
+			},
+			wantTotal: 1,
+			wantFacets: map[string][]*domain.FacetValue{
+				domain.FacetKindCredits: {
+					{Value: "2.0", Count: 1},
+					{Value: "3.0", Count: 1},
+				},
+			},
+		},
+		{
+			name: "除外する条件の集計の対象のカラムに対する条件も取り除く",
+			fields: coursePersistence{
+				db: db,
+			},
+			query: domain.CourseQuery{
+				Instructor:           "天笠",
+				InstructorFilterType: "and",
+				Q:                    "term:秋B",
+				ExcludeTerm:          "秋A",
+				FilterType:           "and",
+				Facets:               []string{domain.FacetKindTerm},
+				Limit:                50,
+			},
+			want:      nil,
+			wantTotal: 0,
+			wantFacets: map[string][]*domain.FacetValue{
+				domain.FacetKindTerm: {
+					{Value: "4", Count: 2},
+					{Value: "5", Count: 2},
+					{Value: "6", Count: 2},
+				},
+			},
+		},
+		{
+			name: "該当する科目が無くても集計を返す",
+			fields: coursePersistence{
+				db: db,
+			},
+			query: domain.CourseQuery{
+				Instructor:           "天笠",
+				InstructorFilterType: "and",
+				Credits:              "9.0",
+				FilterType:           "and",
+				Facets:               []string{domain.FacetKindCredits, domain.FacetKindCredits},
+				Limit:                50,
+			},
+			want:      nil,
+			wantTotal: 0,
+			wantFacets: map[string][]*domain.FacetValue{
+				domain.FacetKindCredits: {
+					{Value: "2.0", Count: 1},
+					{Value: "3.0", Count: 1},
+				},
+			},
+		},
+		{
+			name: "offset が該当件数を超えていても件数と集計を返す",
+			fields: coursePersistence{
+				db: db,
+			},
+			query: domain.CourseQuery{
+				Instructor:           "天笠",
+				InstructorFilterType: "and",
+				Facets:               []string{domain.FacetKindInstructionalType},
+				Limit:                1,
+				Offset:               5,
+			},
+			want:      nil,
+			wantTotal: 2,
+			wantFacets: map[string][]*domain.FacetValue{
+				domain.FacetKindInstructionalType: {
+					{Value: "1", Count: 1},
+					{Value: "6", Count: 1},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.fields
+			got, err := p.Search(tt.query)
+			if err != nil {
+				t.Fatalf("coursePersistence.Search() error = %v", err)
+			}
+			if diff := cmp.Diff(got.Courses, tt.want, cmpopts.IgnoreFields(domain.Course{}, "CSVUpdatedAt", "CreatedAt", "UpdatedAt")); diff != "" {
+				t.Errorf("coursePersistence.Search() mismatch: (-got +want)\n%s", diff)
+			}
+			if got.Total != tt.wantTotal {
+				t.Errorf("coursePersistence.Search() total = %d, want %d", got.Total, tt.wantTotal)
+			}
+			if diff := cmp.Diff(got.Facets, tt.wantFacets); diff != "" {
+				t.Errorf("coursePersistence.Search() facets mismatch: (-got +want)\n%s", diff)
+			}
+
+			// /facet で 1 種類ずつ求めた集計と一致する
+			for kind, facets := range got.Facets {
+				facetQuery, err := tt.query.WithoutFacetFilter(kind)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want, err := p.FacetValues(facetQuery, kind)
+				if err != nil {
+					t.Fatalf("coursePersistence.FacetValues() error = %v", err)
+				}
+				if diff := cmp.Diff(facets, want); diff != "" {
+					t.Errorf("coursePersistence.FacetValues() %s mismatch: (-search +facet)\n%s", kind, diff)
+				}
+			}
+		})
+	}
+}
+
+func Test_coursePersistence_Search_fulltext(t *testing.T) {
+	db, err := createDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		query domain.CourseQuery
+	}
+	tests := []struct {
+		name    string
+		fields  coursePersistence
+		args    args
+		want    []*domain.Course
+		wantErr bool
+	}{
+		{
+			name: "科目名に一致するものが授業概要や備考に一致するものより上にくる",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Keyword:    "統計",
+					SearchMode: domain.SearchModeFulltext,
+					FilterType: "and",
+					Limit:      50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11621"],
+				testdata1Courses["GB11611"],
+				testdata1Courses["GB11601"],
+			},
+		},
+		{
+			name: "科目名が同じだけ一致する場合はキーワードに近いものが上にくる",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Keyword:    "確率論",
+					SearchMode: domain.SearchModeFulltext,
+					FilterType: "and",
+					Limit:      50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11601"],
+				testdata1Courses["GB11611"],
+				testdata1Courses["GB11621"],
+			},
+		},
+		{
+			name: "授業概要にも一致する方が上にくる",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Keyword:    "線形代数",
+					SearchMode: domain.SearchModeFulltext,
+					FilterType: "and",
+					Limit:      50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB10244"],
+				testdata1Courses["GA15241"],
+			},
+		},
+		{
+			name: "英語の科目名に大文字・小文字を区別せずに一致する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Keyword:    "STATISTICS",
+					SearchMode: domain.SearchModeFulltext,
+					FilterType: "and",
+					Limit:      50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11611"],
+				testdata1Courses["GB11621"],
+			},
+		},
+		{
+			name: "1 文字のキーワードは無視する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Keyword:    "線形代数 空",
+					SearchMode: domain.SearchModeFulltext,
+					FilterType: "and",
+					Limit:      50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB10244"],
+				testdata1Courses["GA15241"],
+			},
+		},
+		{
+			name: "並び順を指定すると関連度順にはならない",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Keyword:    "統計",
+					SearchMode: domain.SearchModeFulltext,
+					FilterType: "and",
+					Sort: []domain.CourseSort{
+						{
+							Key: domain.SortKeyCourseNumber,
+						},
+					},
+					Limit: 50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB11601"],
+				testdata1Courses["GB11611"],
+				testdata1Courses["GB11621"],
+			},
+		},
+		{
+			name: "like では 1 文字のキーワードも使う",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Keyword:    "線形代数 空",
+					SearchMode: domain.SearchModeLike,
+					FilterType: "and",
+					Limit:      50,
+				},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GB10244"],
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.fields
+			got, err := p.Search(tt.args.query)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("coursePersistence.Search() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if diff := cmp.Diff(got.Courses, tt.want, cmpopts.IgnoreFields(domain.Course{}, "CSVUpdatedAt", "CreatedAt", "UpdatedAt")); diff != "" {
+				t.Errorf("coursePersistence.Search() mismatch: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_coursePersistence_Stream(t *testing.T) {
+	db, err := createDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		fields  coursePersistence
+		query   domain.CourseQuery
+		wantIDs []int
+	}{
+		{
+			name: "limit, offset を無視してすべての科目を検索結果と同じ順に渡す",
+			fields: coursePersistence{
+				db: db,
+			},
+			query: domain.CourseQuery{
+				CourseName:           "情報",
+				CourseNameFilterType: "and",
+				Limit:                1,
+				Offset:               1,
+			},
+			wantIDs: []int{18010, 18014, 18020, 18022},
+		},
+		{
+			name: "並び順を指定できる",
+			fields: coursePersistence{
+				db: db,
+			},
+			query: domain.CourseQuery{
+				CourseNumber: "GB11",
+				Sort: []domain.CourseSort{
+					{
+						Key:   domain.SortKeyCredits,
+						Order: domain.SortOrderDesc,
+					},
+				},
+				Limit: 1,
+			},
+			wantIDs: []int{18066, 18060, 18062, 18063, 18064, 18067, 18061},
+		},
+		{
+			name: "該当する科目が無い",
+			fields: coursePersistence{
+				db: db,
+			},
+			query: domain.CourseQuery{
+				CourseName:           "存在しない科目",
+				CourseNameFilterType: "and",
+				Limit:                50,
+			},
+			wantIDs: []int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.fields
+			gotIDs := []int{}
+			err := p.Stream(context.Background(), tt.query, func(course *domain.Course) error {
+				gotIDs = append(gotIDs, course.ID)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("coursePersistence.Stream() error = %v", err)
+			}
+			if diff := cmp.Diff(gotIDs, tt.wantIDs); diff != "" {
+				t.Errorf("coursePersistence.Stream() mismatch: (-got +want)\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("fn のエラーで止める", func(t *testing.T) {
+		p := coursePersistence{
+			db: db,
+		}
+		wantErr := errors.New("stop")
+		count := 0
+		err := p.Stream(context.Background(), domain.CourseQuery{Limit: 50}, func(course *domain.Course) error {
+			count++
+			return wantErr
+		})
+		if !errors.Is(err, wantErr) {
+			t.Errorf("coursePersistence.Stream() error = %v, wantErr %v", err, wantErr)
+		}
+		if count != 1 {
+			t.Errorf("coursePersistence.Stream() count = %d, want 1", count)
+		}
+	})
+
+	t.Run("終了した ctx ではエラーになる", func(t *testing.T) {
+		p := coursePersistence{
+			db: db,
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := p.Stream(ctx, domain.CourseQuery{Limit: 50}, func(course *domain.Course) error {
+			t.Error("fn must not be called")
+			return nil
+		})
+		if err == nil {
+			t.Error("coursePersistence.Stream() must return an error")
+		}
+	})
+}
+
+func Test_coursePersistence_Facet(t *testing.T) {
+	db, err := createDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		query domain.CourseQuery
+	}
+	tests := []struct {
+		name    string
+		fields  coursePersistence
+		args    args
+		want    []*domain.Facet
+		wantErr bool
+	}{
+		{
+			name: "temp",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:           "情報",
+					CourseNameFilterType: "and",
+					Limit:                50,
+				},
+			},
+			want: []*domain.Facet{
+				{
+					Term:      1,
+					TermCount: 1,
+				},
+				{
+					Term:      5,
+					TermCount: 2,
+				},
+				{
+					Term:      2,
+					TermCount: 2,
+				},
+				{
+					Term:      4,
+					TermCount: 1,
+				},
+				{
+					Term:      3,
+					TermCount: 1,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Instructor で絞り込んだ開講時期の集計",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Instructor:           "天笠",
+					InstructorFilterType: "and",
+					Limit:                50,
+				},
+			},
+			want: []*domain.Facet{
+				{
+					Term:      4,
+					TermCount: 2,
+				},
+				{
+					Term:      5,
+					TermCount: 2,
+				},
+				{
+					Term:      6,
+					TermCount: 2,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "除外する条件を集計にも反映する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Instructor:           "天笠",
+					InstructorFilterType: "and",
+					ExcludePeriod:        "月5",
+					Limit:                50,
+				},
+			},
+			want: []*domain.Facet{
+				{
+					Term:      4,
+					TermCount: 1,
+				},
+				{
+					Term:      5,
+					TermCount: 1,
+				},
+				{
+					Term:      6,
+					TermCount: 1,
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.fields
+			got, err := p.Facet(tt.args.query)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("coursePersistence.Facet() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			// 集計結果の並び順は保証されない
+			sortFacets := cmpopts.SortSlices(func(a, b *domain.Facet) bool { return a.Term < b.Term })
+			if diff := cmp.Diff(got, tt.want, sortFacets); diff != "" {
+				t.Errorf("coursePersistence.Facet() mismatch: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_coursePersistence_PeriodFacet(t *testing.T) {
+	db, err := createDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		query domain.CourseQuery
+	}
+	tests := []struct {
+		name    string
+		fields  coursePersistence
+		args    args
+		want    []*domain.PeriodFacet
+		wantErr bool
+	}{
+		{
+			name: "同じ曜時限が複数回ある科目も 1 つと数える",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Instructor:           "天笠",
+					InstructorFilterType: "and",
+					Limit:                50,
+				},
+			},
+			want: []*domain.PeriodFacet{
+				{Period: "月1", Count: 1},
+				{Period: "月2", Count: 1},
+				{Period: "月3", Count: 1},
+				{Period: "月4", Count: 1},
+				{Period: "月5", Count: 1},
+			},
+			wantErr: false,
+		},
+		{
+			name: "開講時期で絞り込む",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Term:       "春AB",
+					FilterType: "and",
+					Limit:      50,
+				},
+			},
+			want: []*domain.PeriodFacet{
+				{Period: "月1", Count: 1},
+				{Period: "月2", Count: 1},
+				{Period: "木5", Count: 1},
+				{Period: "木6", Count: 1},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.fields
+			got, err := p.PeriodFacet(tt.args.query)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("coursePersistence.PeriodFacet() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			// 集計結果の並び順は保証されない
+			sortFacets := cmpopts.SortSlices(func(a, b *domain.PeriodFacet) bool { return a.Period < b.Period })
+			if diff := cmp.Diff(got, tt.want, sortFacets); diff != "" {
+				t.Errorf("coursePersistence.PeriodFacet() mismatch: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_coursePersistence_FacetValues(t *testing.T) {
+	db, err := createDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		query domain.CourseQuery
+		kind  string
+	}
+	tests := []struct {
+		name    string
+		fields  coursePersistence
+		args    args
+		want    []*domain.FacetValue
+		wantErr bool
+	}{
+		{
+			name: "temp",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:           "情報",
+					CourseNameFilterType: "and",
+					Limit:                50,
+				},
+				kind: domain.FacetKindTerm,
+			},
+			want: []*domain.FacetValue{
+				{Value: "1", Count: 1},
+				{Value: "5", Count: 2},
+				{Value: "2", Count: 2},
+				{Value: "4", Count: 1},
+				{Value: "3", Count: 1},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Instructor で絞り込んだ開講時期の集計",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Instructor:           "天笠",
+					InstructorFilterType: "and",
+					Limit:                50,
+				},
+				kind: domain.FacetKindTerm,
+			},
+			want: []*domain.FacetValue{
+				{Value: "4", Count: 2},
+				{Value: "5", Count: 2},
+				{Value: "6", Count: 2},
+			},
+			wantErr: false,
+		},
+		{
+			name: "除外する条件を集計にも反映する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Instructor:           "天笠",
+					InstructorFilterType: "and",
+					ExcludePeriod:        "月5",
+					Limit:                50,
+				},
+				kind: domain.FacetKindTerm,
+			},
+			want: []*domain.FacetValue{
+				{Value: "4", Count: 1},
+				{Value: "5", Count: 1},
+				{Value: "6", Count: 1},
+			},
+			wantErr: false,
+		},
+		{
+			name: "同じ曜時限が複数回ある科目も 1 つと数える",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Instructor:           "天笠",
+					InstructorFilterType: "and",
+					Limit:                50,
+				},
+				kind: domain.FacetKindPeriod,
+			},
+			want: []*domain.FacetValue{
+				{Value: "月1", Count: 1},
+				{Value: "月2", Count: 1},
+				{Value: "月3", Count: 1},
+				{Value: "月4", Count: 1},
+				{Value: "月5", Count: 1},
+			},
+			wantErr: false,
+		},
+		{
+			name: "開講時期で絞り込んだ曜時限の集計",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Term:       "春AB",
+					FilterType: "and",
+					Limit:      50,
+				},
+				kind: domain.FacetKindPeriod,
+			},
+			want: []*domain.FacetValue{
+				{Value: "月1", Count: 1},
+				{Value: "月2", Count: 1},
+				{Value: "木5", Count: 1},
+				{Value: "木6", Count: 1},
+			},
+			wantErr: false,
+		},
+		{
+			name: "授業方法の集計",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Instructor:           "天笠",
+					InstructorFilterType: "and",
+					Limit:                50,
+				},
+				kind: domain.FacetKindInstructionalType,
+			},
+			want: []*domain.FacetValue{
+				{Value: "1", Count: 1},
+				{Value: "6", Count: 1},
+			},
+			wantErr: false,
+		},
+		{
+			name: "単位数の集計",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Instructor:           "天笠",
+					InstructorFilterType: "and",
+					Limit:                50,
+				},
+				kind: domain.FacetKindCredits,
+			},
+			want: []*domain.FacetValue{
+				{Value: "2.0", Count: 1},
+				{Value: "3.0", Count: 1},
+			},
+			wantErr: false,
+		},
+		{
+			name: "標準履修年次の集計",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Instructor:           "天笠",
+					InstructorFilterType: "and",
+					Limit:                50,
+				},
+				kind: domain.FacetKindStandardRegistrationYear,
+			},
+			want: []*domain.FacetValue{
+				{Value: "2", Count: 2},
+			},
+			wantErr: false,
+		},
+		{
+			name: "科目番号の先頭 2 文字で集計する",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Term:       "春AB",
+					FilterType: "and",
+					Limit:      50,
+				},
+				kind: domain.FacetKindDepartment,
+			},
+			want: []*domain.FacetValue{
+				{Value: "GA", Count: 1},
+				{Value: "GB", Count: 1},
+			},
+			wantErr: false,
+		},
+		{
+			name: "担当教員の集計",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Instructor:           "天笠",
+					InstructorFilterType: "and",
+					Limit:                50,
+				},
+				kind: domain.FacetKindInstructor,
+			},
+			want: []*domain.FacetValue{
+				{Value: "天笠 俊之", Count: 2},
+				{Value: "藤田 典久", Count: 1},
+				{Value: "長谷部 浩二", Count: 1},
+			},
+			wantErr: false,
+		},
+		{
+			name: "未知の集計",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				query: domain.CourseQuery{
+					Limit: 50,
+				},
+				kind: "classroom",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.fields
+			got, err := p.FacetValues(tt.args.query, tt.args.kind)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("coursePersistence.FacetValues() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			// 集計結果の並び順は保証されない
+			sortFacets := cmpopts.SortSlices(func(a, b *domain.FacetValue) bool { return a.Value < b.Value })
+			if diff := cmp.Diff(got, tt.want, sortFacets); diff != "" {
+				t.Errorf("coursePersistence.FacetValues() mismatch: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_coursePersistence_FindByNumbers(t *testing.T) {
+	db, err := createDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		numbers []string
+		years   []string
+	}
+	tests := []struct {
+		name    string
+		fields  coursePersistence
+		args    args
+		want    []*domain.Course
+		wantErr bool
+	}{
+		{
+			name: "見つからない科目番号は含まれない",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				numbers: []string{"GB11956", "GA10101", "XX00000"},
+			},
+			want: []*domain.Course{
+				testdata1Courses["GA10101"],
+				testdata1Courses["GB11956"],
+			},
+			wantErr: false,
+		},
+		{
+			name: "指定した年度から探す",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				numbers: []string{"GA10101"},
+				years:   []string{"2020"},
+			},
+			want:    []*domain.Course{},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.fields
+			got, err := p.FindByNumbers(tt.args.numbers, tt.args.years)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("coursePersistence.FindByNumbers() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("coursePersistence.FindByNumbers() mismatch: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_coursePersistence_FindByCodes(t *testing.T) {
+	db, err := createDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := coursePersistence{
+		db: db,
+	}
+	got, err := p.FindByCodes([]string{"GB11956", "GA10101", "XX00000"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*domain.Course{
+		testdata1Courses["GA10101"],
+		testdata1Courses["GB11956"],
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("coursePersistence.FindByCodes() mismatch: (-got +want)\n%s", diff)
+	}
+}
+
+func Test_coursePersistence_FindByID(t *testing.T) {
+	db, err := createDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		fields  coursePersistence
+		id      int
+		want    *domain.Course
+		wantErr error
+	}{
+		{
+			name: "ID が一致する科目",
+			fields: coursePersistence{
+				db: db,
+			},
+			id:      18067,
+			want:    testdata1Courses["GB11956"],
+			wantErr: nil,
+		},
+		{
+			name: "見つからなければ ErrCourseNotFound",
+			fields: coursePersistence{
+				db: db,
+			},
+			id:      1,
+			want:    nil,
+			wantErr: domain.ErrCourseNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.fields
+			got, err := p.FindByID(tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("coursePersistence.FindByID() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("coursePersistence.FindByID() mismatch: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_coursePersistence_FindByNumber(t *testing.T) {
+	db, err := createDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		year   int
+		number string
+	}
+	tests := []struct {
+		name    string
+		fields  coursePersistence
+		args    args
+		want    *domain.Course
+		wantErr error
+	}{
+		{
+			name: "年度と科目番号が一致する科目",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				year:   2021,
+				number: "GA10101",
+			},
+			want:    testdata1Courses["GA10101"],
+			wantErr: nil,
+		},
+		{
+			name: "前方一致はしない",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				year:   2021,
+				number: "GA101",
+			},
+			want:    nil,
+			wantErr: domain.ErrCourseNotFound,
+		},
+		{
+			name: "年度が違えば見つからない",
+			fields: coursePersistence{
+				db: db,
+			},
+			args: args{
+				year:   2020,
+				number: "GA10101",
+			},
+			want:    nil,
+			wantErr: domain.ErrCourseNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.fields
+			got, err := p.FindByNumber(tt.args.year, tt.args.number)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("coursePersistence.FindByNumber() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("coursePersistence.FindByNumber() mismatch: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_coursePersistence_Years(t *testing.T) {
+	db, err := createDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		fields  coursePersistence
+		want    []*domain.AcademicYear
+		wantErr bool
+	}{
+		{
+			name: "登録されている年度と科目数を返す",
+			fields: coursePersistence{
+				db: db,
+			},
+			want: []*domain.AcademicYear{
+				{
+					Year:        2021,
+					CourseCount: 20,
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.fields
+			got, err := p.Years()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("coursePersistence.Years() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("coursePersistence.Years() mismatch: (-got +want)\n%s", diff)
 			}
 		})
 	}
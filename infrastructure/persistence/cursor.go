@@ -0,0 +1,113 @@
+package persistence
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 検索結果の並び順を決めるキー
+type sortKey struct {
+	// カーソルに埋め込んで、並び順が変わっていないかを確認するための名前
+	name string
+	// courses の行に対して評価する式
+	// キーセットページネーションで比較するため NULL にならないようにする
+	expr string
+	desc bool
+	// 比較に使う照合順序
+	// 空文字列ならデフォルトのもの
+	collation string
+}
+
+// 照合順序が指定されていれば付け加える
+// 比較する両辺の照合順序がずれないよう、並び替えと比較では常にこれを通す
+func (k sortKey) collate(expr string) string {
+	if k.collation == "" {
+		return expr
+	}
+	return expr + " collate " + k.collation
+}
+
+// 並び順を表す文字列
+// 例: "course_name:asc,id:asc"
+func sortKeysSignature(sortKeys []sortKey) string {
+	signatures := []string{}
+	for _, key := range sortKeys {
+		direction := "asc"
+		if key.desc {
+			direction = "desc"
+		}
+		signatures = append(signatures, key.name+":"+direction)
+	}
+	return strings.Join(signatures, ",")
+}
+
+// クライアントからは中身の見えない文字列として扱わせる
+type cursor struct {
+	// 並び順
+	Sort string `json:"s"`
+	// 直前のページの最後の行のソートキーの値
+	Keys []string `json:"k"`
+}
+
+func encodeCursor(sortKeys []sortKey, keys []string) (string, error) {
+	j, err := json.Marshal(cursor{
+		Sort: sortKeysSignature(sortKeys),
+		Keys: keys,
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(j), nil
+}
+
+// カーソルを解釈して、直前のページの最後の行のソートキーの値を返す
+// カーソルを発行したときと並び順が異なる場合はエラーとする
+func decodeCursor(cursorStr string, sortKeys []sortKey) ([]string, error) {
+	j, err := base64.RawURLEncoding.DecodeString(cursorStr)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	var c cursor
+	err = json.Unmarshal(j, &c)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	if c.Sort != sortKeysSignature(sortKeys) || len(c.Keys) != len(sortKeys) {
+		return nil, fmt.Errorf("cursor does not match the sort order: %s", c.Sort)
+	}
+	return c.Keys, nil
+}
+
+// 直前のページの最後の行より後ろにある行を取り出す条件を構築する
+// 昇順・降順が混ざっていても良いように、行値の比較ではなく
+// (k1 > v1) or (k1 = v1 and k2 > v2) or ... の形にする
+// columnNames はそれぞれのソートキーを select したときの列名
+func buildKeysetQuery(sortKeys []sortKey, columnNames []string, keys []string, selectArgs []interface{}, placeholderCount int) (string, int, []interface{}) {
+	// それぞれのソートキーの値に割り当てたプレースホルダー
+	placeholders := []string{}
+	for _, key := range keys {
+		placeholders = append(placeholders, fmt.Sprintf("$%d", placeholderCount))
+		placeholderCount++
+		selectArgs = append(selectArgs, key)
+	}
+
+	disjunctions := []string{}
+	for i, key := range sortKeys {
+		conjunctions := []string{}
+		for j := 0; j < i; j++ {
+			conjunctions = append(conjunctions, fmt.Sprintf("%s = %s", sortKeys[j].collate(columnNames[j]), placeholders[j]))
+		}
+		operator := ">"
+		if key.desc {
+			operator = "<"
+		}
+		conjunctions = append(conjunctions, fmt.Sprintf("%s %s %s", key.collate(columnNames[i]), operator, placeholders[i]))
+		disjunctions = append(disjunctions, "("+strings.Join(conjunctions, " and ")+")")
+	}
+	return "(" + strings.Join(disjunctions, " or ") + ")", placeholderCount, selectArgs
+}
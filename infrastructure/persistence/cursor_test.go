@@ -0,0 +1,121 @@
+package persistence
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_decodeCursor(t *testing.T) {
+	idAsc := []sortKey{
+		{
+			name: "id",
+			expr: "id",
+		},
+	}
+	nameDescIDAsc := []sortKey{
+		{
+			name: "course_name",
+			expr: "course_name",
+			desc: true,
+		},
+		{
+			name: "id",
+			expr: "id",
+		},
+	}
+
+	type args struct {
+		cursorStr string
+		sortKeys  []sortKey
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "encodeCursor で発行したカーソルを解釈できる",
+			args: args{
+				cursorStr: func() string {
+					c, _ := encodeCursor(nameDescIDAsc, []string{"情報社会と法制度", "18010"})
+					return c
+				}(),
+				sortKeys: nameDescIDAsc,
+			},
+			want: []string{"情報社会と法制度", "18010"},
+		},
+		{
+			name: "発行したときと並び順が異なる",
+			args: args{
+				cursorStr: func() string {
+					c, _ := encodeCursor(nameDescIDAsc, []string{"情報社会と法制度", "18010"})
+					return c
+				}(),
+				sortKeys: idAsc,
+			},
+			wantErr: true,
+		},
+		{
+			name: "base64 ではない",
+			args: args{
+				cursorStr: "!!!",
+				sortKeys:  idAsc,
+			},
+			wantErr: true,
+		},
+		{
+			name: "JSON ではない",
+			args: args{
+				cursorStr: "YWJj",
+				sortKeys:  idAsc,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(tt.args.cursorStr, tt.args.sortKeys)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decodeCursor() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCursor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_buildKeysetQuery(t *testing.T) {
+	sortKeys := []sortKey{
+		{
+			name: "credits",
+			expr: "credits",
+			desc: true,
+		},
+		{
+			name: "course_name",
+			expr: "course_name",
+		},
+		{
+			name: "id",
+			expr: "id",
+		},
+	}
+	columnNames := []string{"sort_key_1", "sort_key_2", "sort_key_3"}
+
+	gotQuery, gotPlaceholderCount, gotSelectArgs := buildKeysetQuery(sortKeys, columnNames, []string{"2", "線形代数A", "18029"}, []interface{}{"%情報%"}, 2)
+
+	wantQuery := `((sort_key_1 < $2) or (sort_key_1 = $2 and sort_key_2 > $3) or (sort_key_1 = $2 and sort_key_2 = $3 and sort_key_3 > $4))`
+	if gotQuery != wantQuery {
+		t.Errorf("buildKeysetQuery() query mismatch:\ngot: %s\nwant: %s", gotQuery, wantQuery)
+	}
+	if gotPlaceholderCount != 5 {
+		t.Errorf("buildKeysetQuery() placeholderCount = %d, want 5", gotPlaceholderCount)
+	}
+	wantSelectArgs := []interface{}{"%情報%", "2", "線形代数A", "18029"}
+	if !reflect.DeepEqual(gotSelectArgs, wantSelectArgs) {
+		t.Errorf("buildKeysetQuery() selectArgs = %v, want %v", gotSelectArgs, wantSelectArgs)
+	}
+}
@@ -0,0 +1,228 @@
+package persistence
+
+import (
+	"strings"
+
+	"github.com/sylms/azuki/domain"
+	"github.com/sylms/azuki/util"
+)
+
+// 文字列のカラムを指定する葉と、それを検索する course_search のカラム
+var filterTextColumns = map[string]string{
+	domain.FilterFieldCourseNumber:   "normalized_course_number",
+	domain.FilterFieldCourseName:     "normalized_course_name",
+	domain.FilterFieldAltCourseName:  "normalized_alt_course_name",
+	domain.FilterFieldClassroom:      "normalized_classroom",
+	domain.FilterFieldInstructor:     "normalized_instructor",
+	domain.FilterFieldCourseOverview: "normalized_course_overview",
+	domain.FilterFieldRemarks:        "normalized_remarks",
+}
+
+// 検索条件を 1 つの木にまとめる
+// カラムごとの条件は FilterType でつなぎ、それと除外する条件・検索式・検索条件の木は and でつなぐ
+// 条件が無ければ nil
+func buildCourseFilter(options domain.CourseQuery) (*domain.Filter, error) {
+	// それぞれのカラムに対してカラム内検索の AND/OR が指定されている場合はそれで構築を行なう
+	columnFilters := []*domain.Filter{}
+	appendColumnFilter := func(filter *domain.Filter) {
+		if filter != nil {
+			columnFilters = append(columnFilters, filter)
+		}
+	}
+
+	appendColumnFilter(splitToFilter(util.SplitSpace(options.CourseName), options.CourseNameFilterType, domain.FilterFieldCourseName))
+	appendColumnFilter(splitToFilter(util.SplitSpace(options.CourseOverview), options.CourseOverviewFilterType, domain.FilterFieldCourseOverview))
+	// CourseNumberFilterType が無かったころは CourseOverviewFilterType を使っていたので、省略時はそれに従う
+	courseNumberFilterType := options.CourseNumberFilterType
+	if courseNumberFilterType == "" {
+		courseNumberFilterType = options.CourseOverviewFilterType
+	}
+	appendColumnFilter(splitToFilter(util.SplitSpace(options.CourseNumber), courseNumberFilterType, domain.FilterFieldCourseNumber))
+	appendColumnFilter(splitToFilter(util.SplitSpace(options.Instructor), options.InstructorFilterType, domain.FilterFieldInstructor))
+	appendColumnFilter(splitToFilter(util.SplitSpace(options.Classroom), options.ClassroomFilterType, domain.FilterFieldClassroom))
+	appendColumnFilter(splitToFilter(util.SplitSpace(options.Remarks), options.RemarksFilterType, domain.FilterFieldRemarks))
+	if options.Period != "" {
+		appendColumnFilter(&domain.Filter{Field: domain.FilterFieldPeriod, Value: domain.MultiValue{options.Period}, MatchMode: options.PeriodMatchMode})
+	}
+	if options.Term != "" {
+		appendColumnFilter(&domain.Filter{Field: domain.FilterFieldTerm, Value: domain.MultiValue{options.Term}, MatchMode: options.TermMatchMode})
+	}
+	if len(options.InstructionalType) != 0 {
+		appendColumnFilter(&domain.Filter{Field: domain.FilterFieldInstructionalType, Value: options.InstructionalType})
+	}
+	if len(options.StandardRegistrationYear) != 0 {
+		appendColumnFilter(&domain.Filter{Field: domain.FilterFieldStandardRegistrationYear, Value: options.StandardRegistrationYear})
+	}
+	if options.Credits != "" {
+		appendColumnFilter(&domain.Filter{Field: domain.FilterFieldCredits, Value: domain.MultiValue{options.Credits}})
+	}
+	appendColumnFilter(splitToFilter(splitKeyword(options), "and", domain.FilterFieldKeyword))
+
+	filters := []*domain.Filter{}
+	if len(columnFilters) != 0 {
+		filters = append(filters, joinFilters(columnFilters, options.FilterType))
+	}
+	filters = append(filters, buildExcludeFilters(options)...)
+	if options.Q != "" {
+		filter, err := domain.ParseQuery(options.Q)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, withDefaultMatchMode(filter, options))
+	}
+	if options.Filter != nil {
+		filters = append(filters, withDefaultMatchMode(options.Filter, options))
+	}
+	return joinFilters(filters, "and"), nil
+}
+
+// ExcludeTerm の葉の一致のさせ方、利用者は指定できない
+// domain.TermsOverlapping で、春A なら通年・春学期の科目も重なるものとする
+const matchModeHeldIn = "held_in"
+
+// 除外する条件をそれぞれ not の節にする
+// 曜時限・開講時期は 1 つでも重なれば除く
+func buildExcludeFilters(options domain.CourseQuery) []*domain.Filter {
+	excludes := []*domain.Filter{}
+	if options.ExcludePeriod != "" {
+		excludes = append(excludes, &domain.Filter{Field: domain.FilterFieldPeriod, Value: domain.MultiValue{options.ExcludePeriod}, MatchMode: domain.MatchModeOverlaps})
+	}
+	if options.ExcludeTerm != "" {
+		excludes = append(excludes, &domain.Filter{Field: domain.FilterFieldTerm, Value: domain.MultiValue{options.ExcludeTerm}, MatchMode: matchModeHeldIn})
+	}
+	if len(options.ExcludeInstructionalType) != 0 {
+		excludes = append(excludes, &domain.Filter{Field: domain.FilterFieldInstructionalType, Value: options.ExcludeInstructionalType})
+	}
+	if remarks := splitToFilter(util.SplitSpace(options.ExcludeRemarks), "or", domain.FilterFieldRemarks); remarks != nil {
+		excludes = append(excludes, remarks)
+	}
+
+	filters := []*domain.Filter{}
+	for _, exclude := range excludes {
+		filters = append(filters, &domain.Filter{Not: exclude})
+	}
+	return filters
+}
+
+// 一致のさせ方を指定していない period, term の葉に CourseQuery で指定したものを補った木を返す
+// 与えられた木は書き換えない
+func withDefaultMatchMode(filter *domain.Filter, options domain.CourseQuery) *domain.Filter {
+	copied := *filter
+	if filter.IsLeaf() {
+		if copied.MatchMode == "" && copied.Field == domain.FilterFieldPeriod {
+			copied.MatchMode = options.PeriodMatchMode
+		}
+		if copied.MatchMode == "" && copied.Field == domain.FilterFieldTerm {
+			copied.MatchMode = options.TermMatchMode
+		}
+		return &copied
+	}
+
+	copyList := func(filters []*domain.Filter) []*domain.Filter {
+		if filters == nil {
+			return nil
+		}
+		copiedFilters := []*domain.Filter{}
+		for _, f := range filters {
+			copiedFilters = append(copiedFilters, withDefaultMatchMode(f, options))
+		}
+		return copiedFilters
+	}
+	copied.And = copyList(filter.And)
+	copied.Or = copyList(filter.Or)
+	if filter.Not != nil {
+		copied.Not = withDefaultMatchMode(filter.Not, options)
+	}
+	return &copied
+}
+
+// キーワードそれぞれを葉として filterType でつなぐ
+func splitToFilter(keywords []string, filterType string, field string) *domain.Filter {
+	leaves := []*domain.Filter{}
+	for _, keyword := range keywords {
+		leaves = append(leaves, &domain.Filter{Field: field, Value: domain.MultiValue{keyword}})
+	}
+	return joinFilters(leaves, filterType)
+}
+
+// filterType でつなぐ、1 つだけならそのまま返す
+func joinFilters(filters []*domain.Filter, filterType string) *domain.Filter {
+	switch len(filters) {
+	case 0:
+		return nil
+	case 1:
+		return filters[0]
+	}
+	if filterType == "or" {
+		return &domain.Filter{Or: filters}
+	}
+	return &domain.Filter{And: filters}
+}
+
+// 検索条件の木から条件を構築する
+// 葉の値は validateSearchCourseQuery で検証されている
+func buildFilterQuery(filter *domain.Filter, selectArgs []interface{}, placeholderCount int) (string, int, []interface{}) {
+	switch {
+	case len(filter.And) != 0:
+		return buildFilterListQuery(filter.And, "and", selectArgs, placeholderCount)
+	case len(filter.Or) != 0:
+		return buildFilterListQuery(filter.Or, "or", selectArgs, placeholderCount)
+	case filter.Not != nil:
+		query, placeholderCount, selectArgs := buildFilterQuery(filter.Not, selectArgs, placeholderCount)
+		return "not (" + query + ")", placeholderCount, selectArgs
+	}
+	return buildFilterLeafQuery(filter, selectArgs, placeholderCount)
+}
+
+// 子の条件を operator でつなぐ
+func buildFilterListQuery(filters []*domain.Filter, operator string, selectArgs []interface{}, placeholderCount int) (string, int, []interface{}) {
+	queries := []string{}
+	for _, filter := range filters {
+		var query string
+		query, placeholderCount, selectArgs = buildFilterQuery(filter, selectArgs, placeholderCount)
+		queries = append(queries, "("+query+")")
+	}
+	return strings.Join(queries, " "+operator+" "), placeholderCount, selectArgs
+}
+
+// 葉の条件
+// 値が複数あればいずれかに一致するもの
+func buildFilterLeafQuery(leaf *domain.Filter, selectArgs []interface{}, placeholderCount int) (string, int, []interface{}) {
+	query := ""
+	switch leaf.Field {
+	case domain.FilterFieldInstructionalType:
+		query, placeholderCount, selectArgs = buildEnumQuery(leaf.Value, "instructional_type", selectArgs, placeholderCount)
+	case domain.FilterFieldStandardRegistrationYear:
+		query, placeholderCount, selectArgs = buildEnumQuery(leaf.Value, "standard_registration_year", selectArgs, placeholderCount)
+	default:
+		queries := []string{}
+		for _, value := range leaf.Value {
+			var valueQuery string
+			switch leaf.Field {
+			case domain.FilterFieldKeyword:
+				valueQuery, placeholderCount, selectArgs = buildKeywordCondition(normalizeForSearch(value), selectArgs, placeholderCount)
+			case domain.FilterFieldPeriod:
+				valueQuery, placeholderCount, selectArgs = buildArrayQuery(value, "period_", leaf.MatchMode, selectArgs, placeholderCount)
+			case domain.FilterFieldTerm:
+				valueQuery, placeholderCount, selectArgs = buildArrayQuery(value, "term", leaf.MatchMode, selectArgs, placeholderCount)
+			case domain.FilterFieldCredits:
+				valueQuery, placeholderCount, selectArgs = buildCreditsQuery(value, selectArgs, placeholderCount)
+			default:
+				valueQuery, placeholderCount, selectArgs = buildTextCondition(normalizeForSearch(value), filterTextColumns[leaf.Field], selectArgs, placeholderCount)
+			}
+			if valueQuery != "" {
+				queries = append(queries, valueQuery)
+			}
+		}
+		if len(queries) == 1 {
+			query = queries[0]
+		} else if len(queries) > 1 {
+			query = "(" + strings.Join(queries, ") or (") + ")"
+		}
+	}
+
+	if query == "" {
+		return "true", placeholderCount, selectArgs
+	}
+	return query, placeholderCount, selectArgs
+}
@@ -0,0 +1,287 @@
+package persistence
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/lib/pq"
+	"github.com/sylms/azuki/domain"
+)
+
+func Test_buildFilterQuery(t *testing.T) {
+	filter, err := domain.ParseQuery(`"線形代数" -演習 instructor:山田 (period:月1 OR type:1,2) number:ＧＢ1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotQuery, gotPlaceholderCount, gotSelectArgs := buildFilterQuery(filter, []interface{}{"%情報%"}, 2)
+
+	wantQuery := `((normalized_bigrams @> $2::text[] and (normalized_course_name like $3 or normalized_alt_course_name like $3 or normalized_course_overview like $3 or normalized_remarks like $3))) and ` +
+		`(not ((normalized_bigrams @> $4::text[] and (normalized_course_name like $5 or normalized_alt_course_name like $5 or normalized_course_overview like $5 or normalized_remarks like $5)))) and ` +
+		`(normalized_instructor like $6) and ` +
+		`((array[$7]::varchar[] @> period_ and array[]::varchar[] <> period_) or (instructional_type::text = any($8::text[]))) and ` +
+		`(normalized_course_number like $9)`
+	if gotQuery != wantQuery {
+		t.Errorf("buildFilterQuery() query mismatch:\ngot: %s\nwant: %s", gotQuery, wantQuery)
+	}
+	if gotPlaceholderCount != 10 {
+		t.Errorf("buildFilterQuery() placeholderCount = %d, want 10", gotPlaceholderCount)
+	}
+	wantSelectArgs := []interface{}{"%情報%", pq.StringArray{"線形", "形代", "代数"}, "%線形代数%", pq.StringArray{"演習"}, "%演習%", "%山田%", "月1", pq.StringArray{"1", "2"}, "gb1%"}
+	if !reflect.DeepEqual(gotSelectArgs, wantSelectArgs) {
+		t.Errorf("buildFilterQuery() selectArgs = %v, want %v", gotSelectArgs, wantSelectArgs)
+	}
+}
+
+func Test_buildCourseFilter(t *testing.T) {
+	leaf := func(field string, values ...string) *domain.Filter {
+		return &domain.Filter{Field: field, Value: values}
+	}
+	jsonFilter := &domain.Filter{
+		Not: leaf(domain.FilterFieldPeriod, "月1"),
+	}
+
+	tests := []struct {
+		name  string
+		query domain.CourseQuery
+		want  *domain.Filter
+	}{
+		{
+			name: "条件が無い",
+			query: domain.CourseQuery{
+				FilterType: "and",
+			},
+			want: nil,
+		},
+		{
+			name: "カラム内は各カラムの FilterType、カラムの間は FilterType でつなぐ",
+			query: domain.CourseQuery{
+				CourseName:           "情報 科学",
+				CourseNameFilterType: "and",
+				Term:                 "秋A",
+				InstructionalType:    domain.MultiValue{"1", "2"},
+				FilterType:           "or",
+			},
+			want: &domain.Filter{
+				Or: []*domain.Filter{
+					{
+						And: []*domain.Filter{
+							leaf(domain.FilterFieldCourseName, "情報"),
+							leaf(domain.FilterFieldCourseName, "科学"),
+						},
+					},
+					leaf(domain.FilterFieldTerm, "秋A"),
+					leaf(domain.FilterFieldInstructionalType, "1", "2"),
+				},
+			},
+		},
+		{
+			name: "科目番号は CourseNumberFilterType でつなぐ",
+			query: domain.CourseQuery{
+				CourseNumber:             "GA1 GB1",
+				CourseNumberFilterType:   "or",
+				CourseOverviewFilterType: "and",
+				FilterType:               "and",
+			},
+			want: &domain.Filter{
+				Or: []*domain.Filter{
+					leaf(domain.FilterFieldCourseNumber, "GA1"),
+					leaf(domain.FilterFieldCourseNumber, "GB1"),
+				},
+			},
+		},
+		{
+			name: "科目番号は CourseNumberFilterType の省略時は CourseOverviewFilterType でつなぐ",
+			query: domain.CourseQuery{
+				CourseNumber:             "GA1 GB1",
+				CourseOverviewFilterType: "and",
+				FilterType:               "or",
+			},
+			want: &domain.Filter{
+				And: []*domain.Filter{
+					leaf(domain.FilterFieldCourseNumber, "GA1"),
+					leaf(domain.FilterFieldCourseNumber, "GB1"),
+				},
+			},
+		},
+		{
+			name: "全文検索では短いキーワードを取り除く",
+			query: domain.CourseQuery{
+				Keyword:    "線形代数 B",
+				SearchMode: domain.SearchModeFulltext,
+				FilterType: "and",
+			},
+			want: leaf(domain.FilterFieldKeyword, "線形代数"),
+		},
+		{
+			name: "カラムごとの条件、検索式、検索条件の木は and でつなぐ",
+			query: domain.CourseQuery{
+				Instructor:           "山田",
+				InstructorFilterType: "and",
+				Credits:              "2",
+				Q:                    "統計 OR 確率",
+				Filter:               jsonFilter,
+				FilterType:           "or",
+			},
+			want: &domain.Filter{
+				And: []*domain.Filter{
+					{
+						Or: []*domain.Filter{
+							leaf(domain.FilterFieldInstructor, "山田"),
+							leaf(domain.FilterFieldCredits, "2"),
+						},
+					},
+					{
+						Or: []*domain.Filter{
+							{Field: domain.FilterFieldKeyword, Value: domain.MultiValue{"統計"}, Position: 1},
+							{Field: domain.FilterFieldKeyword, Value: domain.MultiValue{"確率"}, Position: 7},
+						},
+						Position: 1,
+					},
+					jsonFilter,
+				},
+			},
+		},
+		{
+			name: "一致のさせ方を指定していない period, term に PeriodMatchMode, TermMatchMode を補う",
+			query: domain.CourseQuery{
+				Period:          "月1",
+				PeriodMatchMode: domain.MatchModeOverlaps,
+				TermMatchMode:   domain.MatchModeExact,
+				Q:               "term:秋A",
+				Filter: &domain.Filter{
+					Or: []*domain.Filter{
+						{Field: domain.FilterFieldPeriod, Value: domain.MultiValue{"月2"}},
+						{Field: domain.FilterFieldPeriod, Value: domain.MultiValue{"月3"}, MatchMode: domain.MatchModeContainsAll},
+					},
+				},
+				FilterType: "and",
+			},
+			want: &domain.Filter{
+				And: []*domain.Filter{
+					{Field: domain.FilterFieldPeriod, Value: domain.MultiValue{"月1"}, MatchMode: domain.MatchModeOverlaps},
+					{Field: domain.FilterFieldTerm, Value: domain.MultiValue{"秋A"}, MatchMode: domain.MatchModeExact, Position: 1},
+					{
+						Or: []*domain.Filter{
+							{Field: domain.FilterFieldPeriod, Value: domain.MultiValue{"月2"}, MatchMode: domain.MatchModeOverlaps},
+							{Field: domain.FilterFieldPeriod, Value: domain.MultiValue{"月3"}, MatchMode: domain.MatchModeContainsAll},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "除外する条件は FilterType に関わらず and でつなぐ",
+			query: domain.CourseQuery{
+				CourseName:               "情報",
+				CourseNameFilterType:     "and",
+				Instructor:               "山田",
+				InstructorFilterType:     "and",
+				ExcludePeriod:            "月1",
+				ExcludeTerm:              "通年",
+				ExcludeInstructionalType: domain.MultiValue{"2"},
+				ExcludeRemarks:           "対面 同時双方向",
+				FilterType:               "or",
+			},
+			want: &domain.Filter{
+				And: []*domain.Filter{
+					{
+						Or: []*domain.Filter{
+							leaf(domain.FilterFieldCourseName, "情報"),
+							leaf(domain.FilterFieldInstructor, "山田"),
+						},
+					},
+					{Not: &domain.Filter{Field: domain.FilterFieldPeriod, Value: domain.MultiValue{"月1"}, MatchMode: domain.MatchModeOverlaps}},
+					{Not: &domain.Filter{Field: domain.FilterFieldTerm, Value: domain.MultiValue{"通年"}, MatchMode: matchModeHeldIn}},
+					{Not: leaf(domain.FilterFieldInstructionalType, "2")},
+					{
+						Not: &domain.Filter{
+							Or: []*domain.Filter{
+								leaf(domain.FilterFieldRemarks, "対面"),
+								leaf(domain.FilterFieldRemarks, "同時双方向"),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildCourseFilter(tt.query)
+			if err != nil {
+				t.Fatalf("buildCourseFilter() error = %v", err)
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("buildCourseFilter() mismatch: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_buildArrayQuery(t *testing.T) {
+	tests := []struct {
+		name         string
+		rawStr       string
+		dbColumnName string
+		matchMode    string
+		wantQuery    string
+		wantArgs     []interface{}
+	}{
+		{
+			name:         "省略時は contained_in",
+			rawStr:       "月3月4",
+			dbColumnName: "period_",
+			matchMode:    "",
+			wantQuery:    `array[$1, $2]::varchar[] @> period_ and array[]::varchar[] <> period_`,
+			wantArgs:     []interface{}{"月3", "月4"},
+		},
+		{
+			name:         "overlaps",
+			rawStr:       "月3月4",
+			dbColumnName: "period_",
+			matchMode:    domain.MatchModeOverlaps,
+			wantQuery:    `array[$1, $2]::varchar[] && period_`,
+			wantArgs:     []interface{}{"月3", "月4"},
+		},
+		{
+			name:         "contains_all",
+			rawStr:       "秋AB",
+			dbColumnName: "term",
+			matchMode:    domain.MatchModeContainsAll,
+			wantQuery:    `term @> array[$1, $2]::int[]`,
+			wantArgs:     []interface{}{"4", "5"},
+		},
+		{
+			name:         "exact",
+			rawStr:       "秋AB",
+			dbColumnName: "term",
+			matchMode:    domain.MatchModeExact,
+			wantQuery:    `term @> array[$1, $2]::int[] and array[$1, $2]::int[] @> term`,
+			wantArgs:     []interface{}{"4", "5"},
+		},
+		{
+			name:         "ExcludeTerm は通年・春学期の科目とも重なる",
+			rawStr:       "春A",
+			dbColumnName: "term",
+			matchMode:    matchModeHeldIn,
+			wantQuery:    `array[$1, $2, $3]::int[] && term`,
+			wantArgs:     []interface{}{"1", "9", "10"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotQuery, gotPlaceholderCount, gotArgs := buildArrayQuery(tt.rawStr, tt.dbColumnName, tt.matchMode, []interface{}{}, 1)
+			if gotQuery != tt.wantQuery {
+				t.Errorf("buildArrayQuery() query = %s, want %s", gotQuery, tt.wantQuery)
+			}
+			if gotPlaceholderCount != len(tt.wantArgs)+1 {
+				t.Errorf("buildArrayQuery() placeholderCount = %d, want %d", gotPlaceholderCount, len(tt.wantArgs)+1)
+			}
+			if !reflect.DeepEqual(gotArgs, tt.wantArgs) {
+				t.Errorf("buildArrayQuery() args = %v, want %v", gotArgs, tt.wantArgs)
+			}
+		})
+	}
+}
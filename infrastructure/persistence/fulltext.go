@@ -0,0 +1,89 @@
+package persistence
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/sylms/azuki/domain"
+	"github.com/sylms/azuki/util"
+)
+
+// Keyword で検索するカラムと、全文検索の関連度の重み
+var keywordColumns = []struct {
+	name   string
+	weight int
+}{
+	{name: "normalized_course_name", weight: 4},
+	{name: "normalized_alt_course_name", weight: 3},
+	{name: "normalized_course_overview", weight: 2},
+	{name: "normalized_remarks", weight: 1},
+}
+
+// Keyword を検索に使うキーワードに区切る
+// 全文検索では短すぎるキーワードは無視する
+func splitKeyword(options domain.CourseQuery) []string {
+	keywords := util.SplitSpace(normalizeForSearch(options.Keyword))
+	if options.SearchMode == domain.SearchModeFulltext {
+		keywords = util.DropShortWords(keywords, domain.FulltextMinKeywordLength)
+	}
+	return keywords
+}
+
+// 正規化したキーワード 1 つを keywordColumns のいずれかに含む条件
+// 2 文字以上のキーワードは、normalized_bigrams のインデックスで先に絞り込んでから like で確かめる
+func buildKeywordCondition(keyword string, selectArgs []interface{}, placeholderCount int) (string, int, []interface{}) {
+	prefilter := ""
+	if bigrams := keywordBigrams(keyword); len(bigrams) != 0 {
+		prefilter = fmt.Sprintf(`normalized_bigrams @> $%d::text[] and `, placeholderCount)
+		placeholderCount++
+		selectArgs = append(selectArgs, bigrams)
+	}
+
+	columnConditions := []string{}
+	for _, column := range keywordColumns {
+		columnConditions = append(columnConditions, fmt.Sprintf(`%s like $%d`, column.name, placeholderCount))
+	}
+	placeholderCount++
+	selectArgs = append(selectArgs, "%"+keyword+"%")
+	return "(" + prefilter + "(" + strings.Join(columnConditions, " or ") + "))", placeholderCount, selectArgs
+}
+
+// キーワードの連続する 2 文字の組、現れた順で重複は除く
+// keywordColumns のいずれかがキーワードを含むなら、その組はすべて normalized_bigrams に含まれる
+func keywordBigrams(keyword string) pq.StringArray {
+	runes := []rune(keyword)
+	bigrams := pq.StringArray{}
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(runes); i++ {
+		bigram := string(runes[i : i+2])
+		if !seen[bigram] {
+			seen[bigram] = true
+			bigrams = append(bigrams, bigram)
+		}
+	}
+	return bigrams
+}
+
+// 全文検索の関連度
+// キーワードごとに、それを含むカラムの重みを足し合わせる
+// 同じ点数の科目の間では、科目名がキーワード全体に近いものほど上にくるよう、0 から 1 の類似度を加える
+func buildFulltextRelevanceExpr(options domain.CourseQuery, selectArgs []interface{}, placeholderCount int) (string, int, []interface{}) {
+	keywords := splitKeyword(options)
+	if len(keywords) == 0 {
+		return "0", placeholderCount, selectArgs
+	}
+
+	scores := []string{}
+	for _, keyword := range keywords {
+		for _, column := range keywordColumns {
+			scores = append(scores, fmt.Sprintf(`(case when %s like $%d then %d else 0 end)`, column.name, placeholderCount, column.weight))
+		}
+		placeholderCount++
+		selectArgs = append(selectArgs, "%"+keyword+"%")
+	}
+	scores = append(scores, fmt.Sprintf(`similarity(normalized_course_name, $%d)::float8`, placeholderCount))
+	placeholderCount++
+	selectArgs = append(selectArgs, strings.Join(keywords, " "))
+	return "(" + strings.Join(scores, " + ") + ")", placeholderCount, selectArgs
+}
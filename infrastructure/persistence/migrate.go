@@ -0,0 +1,49 @@
+package persistence
+
+import (
+	"github.com/jmoiron/sqlx"
+)
+
+// courses のスキーマは csv2sql が作るので、azuki が必要とするものだけをここで追加する
+// どれも何度実行しても良いようにする
+func migrations() []string {
+	migrations := []string{
+		courseSearchNormalizeFunction(),
+		courseSearchBigramsFunction,
+		courseSearchTable,
+	}
+	migrations = append(migrations, courseSearchAddedColumns...)
+	migrations = append(migrations, courseSearchTrigger()...)
+	migrations = append(migrations,
+		courseSearchBackfill(),
+		// キーワード (domain.CourseQuery.Keyword) の絞り込みに使うインデックス
+		// 2 文字以上のキーワードは normalized_bigrams の GIN インデックスを使う
+		// 3 文字以上のキーワードは trigram インデックスも使える、1 文字のキーワードはどちらも使えない
+		`create index if not exists course_search_bigrams_idx on course_search using gin (normalized_bigrams)`,
+		`create extension if not exists pg_trgm`,
+		`create index if not exists course_search_course_name_trgm_idx on course_search using gin (normalized_course_name gin_trgm_ops)`,
+		`create index if not exists course_search_alt_course_name_trgm_idx on course_search using gin (normalized_alt_course_name gin_trgm_ops)`,
+		`create index if not exists course_search_course_overview_trgm_idx on course_search using gin (normalized_course_overview gin_trgm_ops)`,
+		`create index if not exists course_search_remarks_trgm_idx on course_search using gin (normalized_remarks gin_trgm_ops)`,
+	)
+	return migrations
+}
+
+// 起動時に、WaitForCourses で courses が作られるのを待ってから呼び出す
+// トリガーを作ってから既存の科目を course_search に入れるまでを 1 つのトランザクションで行い、
+// その間に csv2sql が追加した科目も取りこぼさないようにする
+func Migrate(db *sqlx.DB) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, migration := range migrations() {
+		_, err := tx.Exec(migration)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,421 @@
+package persistence
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/sylms/azuki/domain"
+)
+
+// testdata1.sql に無い科目を 2022 年度に追加して id を返す
+// term は "{9}" のような配列のリテラル
+func insertTestCourse(db *sqlx.DB, courseNumber string, courseName string, term string) (int, error) {
+	var id int
+	err := db.Get(&id, `insert into courses (course_number, course_name, instructional_type, credits, standard_registration_year, term, period_, classroom, instructor, course_overview, remarks, credited_auditors, application_conditions, alt_course_name, course_code, course_code_name, csv_updated_at, year, created_at, updated_at)
+		values ($1, $2, '1', '1.0', '{1}', $3, '{月1}', '3A204', '{試験 太郎}', '', '対面', '0', '', 'Test Course', $1, $2, now(), 2022, now(), now())
+		returning id`, courseNumber, courseName, term)
+	return id, err
+}
+
+// testdata/testdata1.sql に含まれる科目を科目番号から引けるようにしたもの
+// CSVUpdatedAt, CreatedAt, UpdatedAt は比較対象外なので省略している
+var testdata1Courses = map[string]*domain.Course{
+	"GA10101": {
+		ID:                       18010,
+		CourseNumber:             "GA10101",
+		CourseName:               "情報社会と法制度",
+		InstructionalType:        1,
+		Credits:                  "2.0",
+		StandardRegistrationYear: []string{"2"},
+		Term:                     []int{4, 5},
+		Period:                   []string{"月5", "月6"},
+		Classroom:                "",
+		Instructor:               []string{"髙良 幸哉"},
+		CourseOverview:           "情報化社会における法制度や情報モラル向上に必要な基礎知識を習得することを目指すため、現行の我が国の法制度の基礎を学び、ネットワーク社会における法整備の現状について講義する。",
+		Remarks:                  "オンライン(オンデマンド型)",
+		CreditedAuditors:         0,
+		ApplicationConditions:    "正規生に対しても受講制限をしているため",
+		AltCourseName:            "Information Society Law",
+		CourseCode:               "GA10101",
+		CourseCodeName:           "情報社会と法制度",
+		Year:                     2021,
+	},
+	"GA10201": {
+		ID:                       18011,
+		CourseNumber:             "GA10201",
+		CourseName:               "知的財産概論",
+		InstructionalType:        1,
+		Credits:                  "2.0",
+		StandardRegistrationYear: []string{"2"},
+		Term:                     []int{4, 5},
+		Period:                   []string{"金5", "金6"},
+		Classroom:                "",
+		Instructor:               []string{"村井 麻衣子"},
+		CourseOverview:           "知的財産に関する法制度を主要な概念や法理に基づいて学ぶ。著作権法、特許法を中心に、不正競争防止法、商標法など、知的財産諸法についての基礎的な知識を身につけ、知的財産法の法技術的な特色を踏まえた上で、情報化社会における望ましい制度のあり方について考察し、情報の保護と利用についてのバランス感覚や、問題解決能力を身につけることを目的とする。",
+		Remarks:                  "オンライン(オンデマンド型)",
+		CreditedAuditors:         0,
+		ApplicationConditions:    "正規生に対しても受講制限をしているため",
+		AltCourseName:            "Introduction to Intellectual Property",
+		CourseCode:               "GA10201",
+		CourseCodeName:           "知的財産概論",
+		Year:                     2021,
+	},
+	"GA12301": {
+		ID:                       18014,
+		CourseNumber:             "GA12301",
+		CourseName:               "システムと情報科学",
+		InstructionalType:        1,
+		Credits:                  "1.0",
+		StandardRegistrationYear: []string{"1"},
+		Term:                     []int{5},
+		Period:                   []string{"火5", "火6"},
+		Classroom:                "",
+		Instructor:               []string{"山際 伸一", "山口 佳樹", "佐藤 聡", "西出 隆志", "大山 恵弘"},
+		CourseOverview:           "情報科学への導入となる基礎理論から応用までを概説し、専門的科目への導入としての基礎知識を習得する。本科目は特に、システムを中心に専門性を習得する上での事前知識となる原理や技術、理論について説明する。",
+		Remarks:                  "専門導入科目(事前登録対象) オンライン(オンデマンド型)",
+		CreditedAuditors:         0,
+		ApplicationConditions:    "正規生に対しても受講制限をしているため",
+		AltCourseName:            "Introduction to Information Science:Information Systems",
+		CourseCode:               "GA12301",
+		CourseCodeName:           "システムと情報科学",
+		Year:                     2021,
+	},
+	"GB10244": {
+		ID:                       18047,
+		CourseNumber:             "GB10244",
+		CourseName:               "線形代数B",
+		InstructionalType:        4,
+		Credits:                  "2.0",
+		StandardRegistrationYear: []string{"2"},
+		Term:                     []int{1, 2},
+		Period:                   []string{"月1", "月2"},
+		Classroom:                "3A207",
+		Instructor:               []string{"山田 武志"},
+		CourseOverview:           "線形代数の基礎。 内容:ベクトル空間,1次写像,核と像,内積空間,固有値・固有ベクトルと対角化",
+		Remarks:                  "情報科学類3・4クラス対象 オンライン(オンデマンド型) 対面",
+		CreditedAuditors:         2,
+		ApplicationConditions:    "",
+		AltCourseName:            "Linear Algebra B",
+		CourseCode:               "GB10244",
+		CourseCodeName:           "線形代数B",
+		Year:                     2021,
+	},
+	"GA14201": {
+		ID:                       18020,
+		CourseNumber:             "GA14201",
+		CourseName:               "知識情報システム概説",
+		InstructionalType:        1,
+		Credits:                  "1.0",
+		StandardRegistrationYear: []string{"1"},
+		Term:                     []int{2, 3},
+		Period:                   []string{"木4"},
+		Classroom:                "",
+		Instructor:               []string{"高久 雅生", "佐藤 哲司", "阪口 哲男", "鈴木 伸崇"},
+		CourseOverview:           "ネットワーク社会における知識の構造化、提供、共有のための枠組みについて講義する。",
+		Remarks:                  "専門導入科目(事前登録対象) オンライン(オンデマンド型)",
+		CreditedAuditors:         0,
+		ApplicationConditions:    "正規生に対しても受講制限をしているため",
+		AltCourseName:            "Foundations of Knowledge Information Systems",
+		CourseCode:               "GA14201",
+		CourseCodeName:           "知識情報システム概説",
+		Year:                     2021,
+	},
+	"GA14301": {
+		ID:                       18021,
+		CourseNumber:             "GA14301",
+		CourseName:               "図書館概論",
+		InstructionalType:        1,
+		Credits:                  "2.0",
+		StandardRegistrationYear: []string{"1"},
+		Term:                     []int{4, 5},
+		Period:                   []string{"木3", "木4"},
+		Classroom:                "",
+		Instructor:               []string{"吉田 右子"},
+		CourseOverview:           "図書館とは何かについて概説し、これからの図書館の在り方を考える。図書館の歴史と現状、機能と社会的意義、館種別図書館と利用者、図書館職員、類縁機関と関係団体、図書館の課題と展望等について幅広く学ぶ。",
+		Remarks:                  "専門導入科目(事前登録対象) オンライン(オンデマンド型) GE22001「図書館概論」を修得済みの者は履修不可。",
+		CreditedAuditors:         1,
+		ApplicationConditions:    "本学(学群・大学院)卒業・修了者又は本学の大学院在学者で司書・司書教諭資格希望者に限る",
+		AltCourseName:            "Introduction to Librarianship",
+		CourseCode:               "GA14301",
+		CourseCodeName:           "図書館概論",
+		Year:                     2021,
+	},
+	"GA15111": {
+		ID:                       18022,
+		CourseNumber:             "GA15111",
+		CourseName:               "情報数学A",
+		InstructionalType:        1,
+		Credits:                  "2.0",
+		StandardRegistrationYear: []string{"1"},
+		Term:                     []int{1, 2},
+		Period:                   []string{"木5", "木6"},
+		Classroom:                "3A203",
+		Instructor:               []string{"西出 隆志", "亀山 幸義"},
+		CourseOverview:           "本授業では,情報学の基礎となる数学的概念について学ぶ.その中でも特に重要な概念である集合,論理,写像,関係,グラフ等を取りあげ,その基礎的な事項について講義する.また,講義内容に対する理解を深めるため,演習も行う.",
+		Remarks:                  "平成31年度以降入学の者に限る。情報科学類生は1・2クラスを対象とする。 オンライン(オンデマンド型) 定員を超過した場合は履修調整をする場合がある（情報科学類生および総合学域群生(情報科学類への移行希望者・学籍番号の下一桁が奇数)優先）。 ",
+		CreditedAuditors:         0,
+		ApplicationConditions:    "正規生に対しても受講制限をしているため",
+		AltCourseName:            "Mathematics for Informatics A",
+		CourseCode:               "GA15101",
+		CourseCodeName:           "情報数学A",
+		Year:                     2021,
+	},
+	"GA15241": {
+		ID:                       18029,
+		CourseNumber:             "GA15241",
+		CourseName:               "線形代数A",
+		InstructionalType:        1,
+		Credits:                  "2.0",
+		StandardRegistrationYear: []string{"1"},
+		Term:                     []int{2, 3},
+		Period:                   []string{"金3", "金4"},
+		Classroom:                "",
+		Instructor:               []string{"長谷川 秀彦"},
+		CourseOverview:           "行列の基礎概念を学び、それを基に行列演算、連立1次方程式の解法、行列式の性質や展開について講義と演習を行なう。",
+		Remarks:                  "知識情報・図書館学類生および総合学域群生（知識情報・図書館学類への移行希望者）優先。 履修申請期限は5月11日(火)まで。 定員を超過した場合は履修調整をする場合がある 。 期末試験は対面で実施予定 オンライン(オンデマンド型)",
+		CreditedAuditors:         0,
+		ApplicationConditions:    "正規生に対しても受講制限をしているため",
+		AltCourseName:            "Linear Algebra A",
+		CourseCode:               "GA15201",
+		CourseCodeName:           "線形代数A",
+		Year:                     2021,
+	},
+	"GB10414": {
+		ID:                       18048,
+		CourseNumber:             "GB10414",
+		CourseName:               "解析学II",
+		InstructionalType:        4,
+		Credits:                  "2.0",
+		StandardRegistrationYear: []string{"3", "4"},
+		Term:                     []int{4, 5},
+		Period:                   []string{"水1", "水2"},
+		Classroom:                "3A308",
+		Instructor:               []string{"片岸 一起"},
+		CourseOverview:           "1変数関数の積分と多変数関数の微分を中心に講義を行う。 ",
+		Remarks:                  "平成30年度以前入学者対象 オンライン(オンデマンド型)",
+		CreditedAuditors:         2,
+		ApplicationConditions:    "",
+		AltCourseName:            "Analysis II",
+		CourseCode:               "GB10414",
+		CourseCodeName:           "解析学II",
+		Year:                     2021,
+	},
+	"GA15311": {
+		ID:                       18030,
+		CourseNumber:             "GA15311",
+		CourseName:               "微分積分A",
+		InstructionalType:        1,
+		Credits:                  "2.0",
+		StandardRegistrationYear: []string{"1"},
+		Term:                     []int{4, 5},
+		Period:                   []string{"金3", "金4"},
+		Classroom:                "3B302",
+		Instructor:               []string{"町田 文雄", "堀江 和正"},
+		CourseOverview:           "解析学の基礎として,実数,関数,数列ならびに連続性や極限などの基本概念と,1変数関数の微分法および積分法について講義を行う。",
+		Remarks:                  "情報科学類生は1・2クラスを対象とする。定員を超過した場合は履修調整をする場合がある（情報科学類生および総合学域 群生(情報科学類への移行希望者・学籍番号の下一桁が奇数)優先）。履修申請期 限は9月21日(火)まで。 オンライン(オンデマンド型) 平成30年度までに開設された「解析学I」(GB10314,GB10324)の単位を修得した者 の履修は認めない。",
+		CreditedAuditors:         0,
+		ApplicationConditions:    "正規生に対しても受講制限をしているため",
+		AltCourseName:            "Calculus A",
+		CourseCode:               "GA15301",
+		CourseCodeName:           "微分積分A",
+		Year:                     2021,
+	},
+	"GA15341": {
+		ID:                       18033,
+		CourseNumber:             "GA15341",
+		CourseName:               "微分積分A",
+		InstructionalType:        1,
+		Credits:                  "2.0",
+		StandardRegistrationYear: []string{"1"},
+		Term:                     []int{4, 5},
+		Period:                   []string{"金3", "金4"},
+		Classroom:                "",
+		Instructor:               []string{"加藤 誠"},
+		CourseOverview:           "解析学の基礎として,実数,関数,数列ならびに連続性や極限などの基本概念と,1変数関数の微分法および積分法について講義を行う。",
+		Remarks:                  "知識学類生および総合学域群生（知識学類への移行希望者）優先。 履修申請期限は9月21日(火)まで。 定員を超過した場合は履修調整をする場合がある 。 オンライン(オンデマンド型)",
+		CreditedAuditors:         0,
+		ApplicationConditions:    "正規生に対しても受講制限をしているため",
+		AltCourseName:            "Calculus A",
+		CourseCode:               "GA15301",
+		CourseCodeName:           "微分積分A",
+		Year:                     2021,
+	},
+	"GA18212": {
+		ID:                       18034,
+		CourseNumber:             "GA18212",
+		CourseName:               "プログラミング入門A",
+		InstructionalType:        2,
+		Credits:                  "2.0",
+		StandardRegistrationYear: []string{"1"},
+		Term:                     []int{4, 5},
+		Period:                   []string{"木5", "木6"},
+		Classroom:                "3A402",
+		Instructor:               []string{"アランニャ", " クラウス", "新城 靖"},
+		CourseOverview:           "プログラミングの有用性と必要性を理解し、単純な処理を行うプログラムを書けるようになることを目指す。",
+		Remarks:                  "情報科学類生および総合学域群生(情報科学類への移行希望者）優先。定員を超過した場合は履修調整をする場合がある。履修申請期限は9月14日(火) まで。原則的に「プログラミング入門B」（GA18312）と同一年度に履修すること。 その他の実施形態 令和2年度までに開設された「プログラミング入門」(GA18112)または平成30年度 までに開設された「プログラミング入門A・B」(GB10664,GB10684)の単位を修得 した者の履修は認めない。",
+		CreditedAuditors:         0,
+		ApplicationConditions:    "正規生に対しても受講制限をしているため",
+		AltCourseName:            "Introduction to Programming A",
+		CourseCode:               "GA18202",
+		CourseCodeName:           "プログラミング入門A",
+		Year:                     2021,
+	},
+	"GB10524": {
+		ID:                       18054,
+		CourseNumber:             "GB10524",
+		CourseName:               "微分方程式",
+		InstructionalType:        4,
+		Credits:                  "2.0",
+		StandardRegistrationYear: []string{"2"},
+		Term:                     []int{4, 5},
+		Period:                   []string{"水1", "水2"},
+		Classroom:                "3B405",
+		Instructor:               []string{"國廣 昇"},
+		CourseOverview:           "自然現象を数理モデル化する手段の一つとして微分方程式は有用である.この講義では,線形微分方程式の解法を中心に,微分方程式全般について講義する.",
+		Remarks:                  "「解析学III」(GB10504)の単位を修得した者の履修は認めない。 オンライン(オンデマンド型)",
+		CreditedAuditors:         2,
+		ApplicationConditions:    "",
+		AltCourseName:            "",
+		CourseCode:               "GB10524",
+		CourseCodeName:           "微分方程式",
+		Year:                     2021,
+	},
+	"GB11404": {
+		ID:                       18060,
+		CourseNumber:             "GB11404",
+		CourseName:               "電磁気学",
+		InstructionalType:        4,
+		Credits:                  "2.0",
+		StandardRegistrationYear: []string{"2"},
+		Term:                     []int{4, 5},
+		Period:                   []string{"木3", "木4"},
+		Classroom:                "3A306",
+		Instructor:               []string{"安永 守利"},
+		CourseOverview:           "集積回路(IC)やハードディスク,タッチパネルや無線LANなど,我々の身の回りの情報通信機器は,電磁現象を原理として動作している.本講義では,これらの電磁現象の基礎を解説する.講義の前半では,「電荷」からスタートして「電場」,「電位」という場の概念とポテンシャルの概念を解説する.また,これらの現象を利用した応用事例も紹介する.後半では,はじめに磁気現象の本質は電流であることを説明し,「磁場」の概念,および「電磁誘導」等の電流と磁気現象の関係を解説する.また,磁気現象を利用した応用事例も紹介する.最後に,「電場」と「磁場」がマクスウェル方程式としてまとめられることを示し,「電磁波」の導出とその応用事例について言及する.",
+		Remarks:                  "オンライン(オンデマンド型)",
+		CreditedAuditors:         2,
+		ApplicationConditions:    "",
+		AltCourseName:            "Electromagnetics",
+		CourseCode:               "GB11404",
+		CourseCodeName:           "電磁気学",
+		Year:                     2021,
+	},
+	"GB11514": {
+		ID:                       18061,
+		CourseNumber:             "GB11514",
+		CourseName:               "シミュレーション物理",
+		InstructionalType:        4,
+		Credits:                  "1.0",
+		StandardRegistrationYear: []string{"2"},
+		Term:                     []int{6},
+		Period:                   []string{"木1", "木2"},
+		Classroom:                "3A311",
+		Instructor:               []string{"狩野 均"},
+		CourseOverview:           "計算機を用いた物理実験について,実験方法から結果のまとめ方まで,演習を交えて系統的に学ぶ。",
+		Remarks:                  "オンライン(オンデマンド型) 対面",
+		CreditedAuditors:         0,
+		ApplicationConditions:    "計算機の台数制限のため",
+		AltCourseName:            "Computer Simulation Methods in Physics",
+		CourseCode:               "GB11514",
+		CourseCodeName:           "シミュレーション物理",
+		Year:                     2021,
+	},
+	"GB11601": {
+		ID:                       18062,
+		CourseNumber:             "GB11601",
+		CourseName:               "確率論",
+		InstructionalType:        1,
+		Credits:                  "2.0",
+		StandardRegistrationYear: []string{"2"},
+		Term:                     []int{4, 5},
+		Period:                   []string{"火5", "火6"},
+		Classroom:                "3A402",
+		Instructor:               []string{"馬場 雪乃"},
+		CourseOverview:           "確率論の基礎。 内容:確率の公理,確率空間,確率変数,分布関数,期待値,特性関数,極限定理など",
+		Remarks:                  "オンライン(オンデマンド型) 「確率・統計」(GB11611)の単位を修得した者の履修は認めない。",
+		CreditedAuditors:         2,
+		ApplicationConditions:    "",
+		AltCourseName:            "Probability Theory",
+		CourseCode:               "GB11601",
+		CourseCodeName:           "確率論",
+		Year:                     2021,
+	},
+	"GB11611": {
+		ID:                       18063,
+		CourseNumber:             "GB11611",
+		CourseName:               "確率・統計",
+		InstructionalType:        1,
+		Credits:                  "2.0",
+		StandardRegistrationYear: []string{"2"},
+		Term:                     []int{4, 5},
+		Period:                   []string{"火5", "火6"},
+		Classroom:                "3A402",
+		Instructor:               []string{"馬場 雪乃"},
+		CourseOverview:           "確率論の基礎。 内容:確率の公理,確率空間,確率変数,分布関数,期待値,特性関数,極限定理など",
+		Remarks:                  "教員免許取得希望者対象。 オンライン(オンデマンド型) 「確率論」(GB11601)の単位を修得した者の履修は認めない。",
+		CreditedAuditors:         2,
+		ApplicationConditions:    "",
+		AltCourseName:            "Probability Theory and Statistics",
+		CourseCode:               "GB11611",
+		CourseCodeName:           "確率・統計",
+		Year:                     2021,
+	},
+	"GB11621": {
+		ID:                       18064,
+		CourseNumber:             "GB11621",
+		CourseName:               "統計学",
+		InstructionalType:        1,
+		Credits:                  "2.0",
+		StandardRegistrationYear: []string{"2"},
+		Term:                     []int{4, 5},
+		Period:                   []string{"木5", "木6"},
+		Classroom:                "3A416",
+		Instructor:               []string{"秋本 洋平"},
+		CourseOverview:           "数理統計学(統計的推定,仮説検定)ならびに分散分析の基礎と応用(ヒューマンインタフェース評価実験の計画と解析)。理論構成の理解を深めるために,コンピュータを利用した演習を実施。",
+		Remarks:                  "「確率論」(または同等科目)の履修を前提とする。 オンライン(オンデマンド型) 情報科学類生は2019年度以前の入学生に限る。「統計学」(GB41204)の単位を修得した者の履修は認めない。",
+		CreditedAuditors:         2,
+		ApplicationConditions:    "",
+		AltCourseName:            "Statistics",
+		CourseCode:               "GB11621",
+		CourseCodeName:           "統計学",
+		Year:                     2021,
+	},
+	"GB11931": {
+		ID:                       18066,
+		CourseNumber:             "GB11931",
+		CourseName:               "データ構造とアルゴリズム",
+		InstructionalType:        1,
+		Credits:                  "3.0",
+		StandardRegistrationYear: []string{"2"},
+		Term:                     []int{4, 5, 6},
+		Period:                   []string{"月1", "月2"},
+		Classroom:                "3B402",
+		Instructor:               []string{"天笠 俊之", "長谷部 浩二", "藤田 典久"},
+		CourseOverview:           "ソフトウェアを書く上で基本となるデータ構造とアルゴリズムの考え方について学ぶ。線形構造,木構造,グラフ構造,データ整列,データ探索について学習する。",
+		Remarks:                  "平成25年度までに開設された「データ構造とアルゴリズム」(GB11911, GB11921)の単位を修得した者の履修は認めない。 オンライン(同時双方向型)",
+		CreditedAuditors:         2,
+		ApplicationConditions:    "",
+		AltCourseName:            "Data Structures and Algorithms",
+		CourseCode:               "GB11931",
+		CourseCodeName:           "データ構造とアルゴリズム",
+		Year:                     2021,
+	},
+	"GB11956": {
+		ID:                       18067,
+		CourseNumber:             "GB11956",
+		CourseName:               "データ構造とアルゴリズム実験",
+		InstructionalType:        6,
+		Credits:                  "2.0",
+		StandardRegistrationYear: []string{"2"},
+		Term:                     []int{4, 5, 6},
+		Period:                   []string{"月3", "月4", "月5", "月3", "月4"},
+		Classroom:                "3C113,3C205",
+		Instructor:               []string{"天笠 俊之"},
+		CourseOverview:           "データ構造とアルゴリズムに関して,実際にJava言語を用いてプログラムを作成し,そのプログラムが稼働することを確認する。プログラムは,毎週,あるいは隔週に一個の割合で作成する。",
+		Remarks:                  "1・2クラス オンライン(同時双方向型) 令和2年度までに開設された「データ構造とアルゴリズム実験」(GB11936,GB11946)または平成26年度までに開設された「データ構造とアルゴリズム実験」(GB11916, GB11926)の単位を修得した者の履修は認めない。",
+		CreditedAuditors:         0,
+		ApplicationConditions:    "施設設備の許容量上の制約と学類生に対する良質の少人数教育を行うため",
+		AltCourseName:            "Data Structures and Algorithms Laboratory",
+		CourseCode:               "GB11956",
+		CourseCodeName:           "データ構造とアルゴリズム実験",
+		Year:                     2021,
+	},
+}
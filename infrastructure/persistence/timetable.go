@@ -0,0 +1,37 @@
+package persistence
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/sylms/azuki/domain"
+)
+
+// 埋まっているコマのいずれとも重ならない科目に絞り込むクエリを構築する
+// 開講時期ごとに、その時期に授業がある (通年・春学期・秋学期を含む) 科目が埋まっている曜時限を含まないことを確かめる
+func buildOccupiedQuery(cells []domain.TimetableCell, selectArgs []interface{}, placeholderCount int) (string, int, []interface{}) {
+	periodsByTerm := map[int][]string{}
+	for _, cell := range cells {
+		periodsByTerm[cell.Term] = append(periodsByTerm[cell.Term], cell.Period)
+	}
+	terms := []int{}
+	for term := range periodsByTerm {
+		terms = append(terms, term)
+	}
+	sort.Ints(terms)
+
+	conditions := []string{}
+	for _, term := range terms {
+		coveringTerms := []string{}
+		for _, t := range domain.TermsCovering(term) {
+			coveringTerms = append(coveringTerms, strconv.Itoa(t))
+		}
+		conditions = append(conditions, fmt.Sprintf(`not (term && $%d::int[] and period_ && $%d::varchar[])`, placeholderCount, placeholderCount+1))
+		placeholderCount += 2
+		selectArgs = append(selectArgs, pq.StringArray(coveringTerms), pq.StringArray(periodsByTerm[term]))
+	}
+	return "(" + strings.Join(conditions, " and ") + ")", placeholderCount, selectArgs
+}
@@ -0,0 +1,39 @@
+package persistence
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/sylms/azuki/domain"
+)
+
+func Test_buildOccupiedQuery(t *testing.T) {
+	cells := []domain.TimetableCell{
+		{Term: 4, Period: "月1"},
+		{Term: 1, Period: "火2"},
+		{Term: 4, Period: "月2"},
+		{Term: 7, Period: "水3"},
+	}
+
+	gotQuery, gotPlaceholderCount, gotSelectArgs := buildOccupiedQuery(cells, []interface{}{"x"}, 2)
+
+	wantQuery := `(not (term && $2::int[] and period_ && $3::varchar[]) and ` +
+		`not (term && $4::int[] and period_ && $5::varchar[]) and ` +
+		`not (term && $6::int[] and period_ && $7::varchar[]))`
+	if gotQuery != wantQuery {
+		t.Errorf("buildOccupiedQuery() query mismatch:\ngot: %s\nwant: %s", gotQuery, wantQuery)
+	}
+	if gotPlaceholderCount != 8 {
+		t.Errorf("buildOccupiedQuery() placeholderCount = %d, want 8", gotPlaceholderCount)
+	}
+	wantSelectArgs := []interface{}{
+		"x",
+		pq.StringArray{"1", "9", "10"}, pq.StringArray{"火2"},
+		pq.StringArray{"4", "9", "11"}, pq.StringArray{"月1", "月2"},
+		pq.StringArray{"7"}, pq.StringArray{"水3"},
+	}
+	if !reflect.DeepEqual(gotSelectArgs, wantSelectArgs) {
+		t.Errorf("buildOccupiedQuery() selectArgs = %v, want %v", gotSelectArgs, wantSelectArgs)
+	}
+}
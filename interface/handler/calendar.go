@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sylms/azuki/domain"
+	"github.com/sylms/azuki/usecase"
+)
+
+type CalendarHandler interface {
+	FindByYear(http.ResponseWriter, *http.Request)
+}
+
+type calendarHandler struct {
+	uc usecase.CalendarUseCase
+}
+
+func NewCalendarHandler(uc usecase.CalendarUseCase) CalendarHandler {
+	return &calendarHandler{
+		uc: uc,
+	}
+}
+
+// 日付は "2021-04-12"、時刻は "08:40" の形式
+type CalendarJSON struct {
+	Year int `json:"year"`
+	// 開講時期の番号の順
+	Terms []CalendarTermJSON `json:"terms"`
+	// 時限の順
+	Periods     []CalendarPeriodJSON     `json:"periods"`
+	Holidays    []string                 `json:"holidays"`
+	Substitutes []CalendarSubstituteJSON `json:"substitutes"`
+	// 開講時期の番号の順
+	Exams []CalendarTermJSON `json:"exams"`
+}
+
+type CalendarTermJSON struct {
+	// 春A = 1, ..., 秋C = 6 など
+	Term  int    `json:"term"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+type CalendarPeriodJSON struct {
+	Period int    `json:"period"`
+	Start  string `json:"start"`
+	End    string `json:"end"`
+}
+
+type CalendarSubstituteJSON struct {
+	Date string `json:"date"`
+	// その日に授業を行う曜日
+	Day string `json:"day"`
+}
+
+// /calendar/{year}
+// 年度の学年暦を返す、登録されていなければ 404
+func (h *calendarHandler) FindByYear(w http.ResponseWriter, r *http.Request) {
+	year, err := strconv.Atoi(mux.Vars(r)["year"])
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	calendar, err := h.uc.FindByYear(year)
+	if errors.Is(err, domain.ErrCalendarNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	j, err := json.Marshal(toCalendarJSON(calendar))
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(j)
+	if err != nil {
+		log.Printf("%+v", err)
+	}
+}
+
+func toCalendarJSON(calendar *domain.AcademicCalendar) CalendarJSON {
+	res := CalendarJSON{
+		Year:        calendar.Year,
+		Terms:       toCalendarTermsJSON(calendar.Terms),
+		Periods:     []CalendarPeriodJSON{},
+		Holidays:    []string{},
+		Substitutes: []CalendarSubstituteJSON{},
+		Exams:       toCalendarTermsJSON(calendar.Exams),
+	}
+
+	periods := []int{}
+	for period := range calendar.Periods {
+		periods = append(periods, period)
+	}
+	sort.Ints(periods)
+	for _, period := range periods {
+		clocks := calendar.Periods[period]
+		res.Periods = append(res.Periods, CalendarPeriodJSON{
+			Period: period,
+			Start:  calendarClock(clocks.Start),
+			End:    calendarClock(clocks.End),
+		})
+	}
+
+	for _, holiday := range calendar.Holidays {
+		res.Holidays = append(res.Holidays, holiday.Format(domain.CalendarDateLayout))
+	}
+	for _, substitute := range calendar.Substitutes {
+		res.Substitutes = append(res.Substitutes, CalendarSubstituteJSON{
+			Date: substitute.Date.Format(domain.CalendarDateLayout),
+			Day:  substitute.Day,
+		})
+	}
+	return res
+}
+
+func toCalendarTermsJSON(ranges map[int]*domain.DateRange) []CalendarTermJSON {
+	terms := []int{}
+	for term := range ranges {
+		terms = append(terms, term)
+	}
+	sort.Ints(terms)
+
+	res := []CalendarTermJSON{}
+	for _, term := range terms {
+		res = append(res, CalendarTermJSON{
+			Term:  term,
+			Start: ranges[term].Start.Format(domain.CalendarDateLayout),
+			End:   ranges[term].End.Format(domain.CalendarDateLayout),
+		})
+	}
+	return res
+}
+
+// 0 時からの経過時間を "08:40" にする
+func calendarClock(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
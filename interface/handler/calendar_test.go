@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sylms/azuki/domain"
+)
+
+type calendarUseCaseMock struct {
+	FakeFindByYear func(int) (*domain.AcademicCalendar, error)
+}
+
+func (uc *calendarUseCaseMock) FindByYear(year int) (*domain.AcademicCalendar, error) {
+	return uc.FakeFindByYear(year)
+}
+
+func Test_calendarHandler_FindByYear(t *testing.T) {
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(2021, month, day, 0, 0, 0, 0, domain.CalendarLocation)
+	}
+
+	tests := []struct {
+		name              string
+		vars              map[string]string
+		fakeFindByYear    func(int) (*domain.AcademicCalendar, error)
+		wantResStatusCode int
+		wantResBody       string
+	}{
+		{
+			name: "開講時期と時限の順に並べる",
+			vars: map[string]string{"year": "2021"},
+			fakeFindByYear: func(year int) (*domain.AcademicCalendar, error) {
+				if year != 2021 {
+					t.Errorf("year = %d, want 2021", year)
+				}
+				return &domain.AcademicCalendar{
+					Year: year,
+					Terms: map[int]*domain.DateRange{
+						2: {Start: date(time.May, 20), End: date(time.June, 30)},
+						1: {Start: date(time.April, 12), End: date(time.May, 19)},
+					},
+					Periods: map[int]*domain.ClockRange{
+						2: {Start: 10*time.Hour + 10*time.Minute, End: 11*time.Hour + 25*time.Minute},
+						1: {Start: 8*time.Hour + 40*time.Minute, End: 9*time.Hour + 55*time.Minute},
+					},
+					Holidays:    []time.Time{date(time.April, 29)},
+					Substitutes: []*domain.SubstituteDay{{Date: date(time.May, 19), Day: "月"}},
+					Exams: map[int]*domain.DateRange{
+						1: {Start: date(time.May, 17), End: date(time.May, 18)},
+					},
+				}, nil
+			},
+			wantResStatusCode: http.StatusOK,
+			wantResBody: `{"year":2021,` +
+				`"terms":[{"term":1,"start":"2021-04-12","end":"2021-05-19"},{"term":2,"start":"2021-05-20","end":"2021-06-30"}],` +
+				`"periods":[{"period":1,"start":"08:40","end":"09:55"},{"period":2,"start":"10:10","end":"11:25"}],` +
+				`"holidays":["2021-04-29"],` +
+				`"substitutes":[{"date":"2021-05-19","day":"月"}],` +
+				`"exams":[{"term":1,"start":"2021-05-17","end":"2021-05-18"}]}`,
+		},
+		{
+			name: "空の学年暦",
+			vars: map[string]string{"year": "2022"},
+			fakeFindByYear: func(year int) (*domain.AcademicCalendar, error) {
+				return &domain.AcademicCalendar{Year: year}, nil
+			},
+			wantResStatusCode: http.StatusOK,
+			wantResBody:       `{"year":2022,"terms":[],"periods":[],"holidays":[],"substitutes":[],"exams":[]}`,
+		},
+		{
+			name: "登録されていなければ 404",
+			vars: map[string]string{"year": "2019"},
+			fakeFindByYear: func(year int) (*domain.AcademicCalendar, error) {
+				return nil, domain.ErrCalendarNotFound
+			},
+			wantResStatusCode: http.StatusNotFound,
+			wantResBody:       ``,
+		},
+		{
+			name: "ファイルが不正なら 500",
+			vars: map[string]string{"year": "2020"},
+			fakeFindByYear: func(year int) (*domain.AcademicCalendar, error) {
+				return nil, errors.New("calendar 2020: 'terms' error: 春D")
+			},
+			wantResStatusCode: http.StatusInternalServerError,
+			wantResBody:       ``,
+		},
+		{
+			name: "年度が数値でない",
+			vars: map[string]string{"year": "latest"},
+			fakeFindByYear: func(year int) (*domain.AcademicCalendar, error) {
+				t.Fatal("FindByYear must not be called")
+				return nil, nil
+			},
+			wantResStatusCode: http.StatusBadRequest,
+			wantResBody:       ``,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/calendar/"+tt.vars["year"], nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = mux.SetURLVars(req, tt.vars)
+
+			res := httptest.NewRecorder()
+
+			h := &calendarHandler{
+				uc: &calendarUseCaseMock{
+					FakeFindByYear: tt.fakeFindByYear,
+				},
+			}
+
+			h.FindByYear(res, req)
+
+			resBodyGot := res.Body.String()
+			if resBodyGot != tt.wantResBody {
+				t.Errorf("response mismatch:\ngot: %s\nwant: %s", resBodyGot, tt.wantResBody)
+			}
+
+			statusCodeGot := res.Code
+			if statusCodeGot != tt.wantResStatusCode {
+				t.Errorf("response status code mismatch:\ngot: %d\nwant: %d", statusCodeGot, tt.wantResStatusCode)
+			}
+		})
+	}
+}
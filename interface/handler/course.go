@@ -4,12 +4,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gocarina/gocsv"
+	"github.com/gorilla/mux"
 	"github.com/sylms/azuki/domain"
 	"github.com/sylms/azuki/usecase"
 	"github.com/sylms/azuki/util"
@@ -20,6 +23,10 @@ type CourseHandler interface {
 	Search(http.ResponseWriter, *http.Request)
 	Csv(http.ResponseWriter, *http.Request)
 	Facet(http.ResponseWriter, *http.Request)
+	Years(http.ResponseWriter, *http.Request)
+	Fits(http.ResponseWriter, *http.Request)
+	FindByID(http.ResponseWriter, *http.Request)
+	FindByNumber(http.ResponseWriter, *http.Request)
 }
 
 type courseHandler struct {
@@ -56,28 +63,46 @@ type CourseJSON struct {
 	UpdatedAt                time.Time `json:"updated_at"`
 }
 
-type CourseCSV struct {
-	CourseNumber             string    `csv:"科目番号"`
-	CourseName               string    `csv:"科目名"`
-	InstructionalType        int       `csv:"授業方法"`
-	Credits                  string    `csv:"単位数"`
-	StandardRegistrationYear string    `csv:"標準履修年次"`
-	Term                     string    `csv:"実施学期"`
-	Period                   string    `csv:"曜時限"`
-	Classroom                string    `csv:"教室"`
-	Instructor               string    `csv:"担当教員"`
-	CourseOverview           string    `csv:"授業概要"`
-	Remarks                  string    `csv:"備考"`
-	CreditedAuditors         int       `csv:"科目等履修生申請可否"`
-	ApplicationConditions    string    `csv:"申請条件"`
-	AltCourseName            string    `csv:"英語(日本語)科目名"`
-	CourseCode               string    `csv:"科目コード"`
-	CourseCodeName           string    `csv:"要件科目名"`
-	UpdatedAt                time.Time `csv:"データ更新日"`
+// /course のレスポンス
+type CourseSearchResultJSON struct {
+	Total      int          `json:"total"`
+	Limit      int          `json:"limit"`
+	Offset     int          `json:"offset"`
+	HasNext    bool         `json:"has_next"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+	Items      []CourseJSON `json:"items"`
+	// facets を指定した場合のみ、/facet の facets と同じ形式
+	Facets map[string][]FacetValueJSON `json:"facets,omitempty"`
 }
 
+// /course?format=array を指定すると、以前のように科目の配列のみを返す
+const searchResponseFormatArray = "array"
+
+// /facet のレスポンス
+// 集計の種類ごとにキーを分ける
 type FacetJSON struct {
 	TermFacet map[int]int `json:"term_facet"`
+	// 曜時限ごとの科目数、domain.PeriodFacetPeriods は該当する科目が無くても 0 として含める
+	PeriodFacet map[string]int `json:"period_facet,omitempty"`
+	// facets で要求された集計の種類ごとの、該当する科目の多い順の値と科目数
+	Facets map[string][]FacetValueJSON `json:"facets,omitempty"`
+}
+
+type FacetValueJSON struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// 検索式 (q) が不正なときのレスポンス
+type QueryErrorJSON struct {
+	Error string `json:"error"`
+	// 検索式の中での位置 (1 文字目が 1)
+	Position int `json:"position"`
+}
+
+type AcademicYearJSON struct {
+	Year        int `json:"year"`
+	CourseCount int `json:"course_count"`
 }
 
 func (h *courseHandler) Search(w http.ResponseWriter, r *http.Request) {
@@ -96,26 +121,144 @@ func (h *courseHandler) Search(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err = validateSearchCourseQuery(query)
+	if err != nil {
+		log.Printf("%+v", err)
+		writeValidationError(w, err)
+		return
+	}
+
+	result, err := h.uc.Search(query)
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	writeSearchResult(w, r, query, result)
+}
+
+// /course/fits
+// 検索条件に加えて時間割を受け取り、空いているコマに収まる科目を /course と同じ形式で返す
+func (h *courseHandler) Fits(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var query domain.CourseFitsQuery
+	err := json.NewDecoder(r.Body).Decode(&query)
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err = validateSearchCourseQuery(query.CourseQuery)
+	if err != nil {
+		log.Printf("%+v", err)
+		writeValidationError(w, err)
+		return
+	}
+	err = validateFitsQuery(query)
 	if err != nil {
 		log.Printf("%+v", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	courses, err := h.uc.Search(query)
+	result, err := h.uc.Fits(query)
 	if err != nil {
 		log.Printf("%+v", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
+	writeSearchResult(w, r, query.CourseQuery, result)
+}
+
+// /course/{id}
+// 科目 1 つを返す、見つからなければ 404
+func (h *courseHandler) FindByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	course, err := h.uc.FindByID(id)
+	writeCourse(w, course, err)
+}
+
+// /courses/{year}/{course_number}
+// 年度と科目番号が完全に一致する科目 1 つを返す、見つからなければ 404
+func (h *courseHandler) FindByNumber(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	year, err := strconv.Atoi(vars["year"])
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	course, err := h.uc.FindByNumber(year, vars["course_number"])
+	writeCourse(w, course, err)
+}
+
+// 科目 1 つを返すエンドポイントのレスポンスを書き込む
+func writeCourse(w http.ResponseWriter, course *domain.Course, err error) {
+	if errors.Is(err, domain.ErrCourseNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	j, err := json.Marshal(CourseJSON(*course))
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(j)
+	if err != nil {
+		log.Printf("%+v", err)
+	}
+}
+
+// 検索結果を /course のレスポンスとして書き込む
+func writeSearchResult(w http.ResponseWriter, r *http.Request, query domain.CourseQuery, result *domain.CourseSearchResult) {
 	coursesJson := []CourseJSON{}
-	for _, course := range courses {
+	for _, course := range result.Courses {
 		courseJson := CourseJSON(*course)
 		coursesJson = append(coursesJson, courseJson)
 	}
 
-	resJson, err := json.Marshal(coursesJson)
+	resultJson := CourseSearchResultJSON{
+		Total:      result.Total,
+		Limit:      query.Limit,
+		Offset:     query.Offset,
+		HasNext:    result.HasNext,
+		NextCursor: result.NextCursor,
+		Items:      coursesJson,
+	}
+	if len(query.Facets) != 0 {
+		resultJson.Facets = toFacetValuesJSON(query.Facets, result.Facets)
+	}
+
+	var res interface{} = resultJson
+	// 以前の形式 (科目の配列のみ) を要求された場合
+	if r.URL.Query().Get("format") == searchResponseFormatArray {
+		res = coursesJson
+	}
+
+	resJson, err := json.Marshal(res)
 	if err != nil {
 		log.Printf("%+v", err)
 		w.WriteHeader(http.StatusBadRequest)
@@ -146,20 +289,162 @@ func validateSearchCourseQuery(query domain.CourseQuery) error {
 			return fmt.Errorf("CourseOverviewFilterType error: %s, %+v", query.CourseOverviewFilterType, allowedFilterType)
 		}
 	}
+	if query.Instructor != "" {
+		if !util.Contains(allowedFilterType, query.InstructorFilterType) {
+			return fmt.Errorf("InstructorFilterType error: %s, %+v", query.InstructorFilterType, allowedFilterType)
+		}
+	}
+	if query.Classroom != "" {
+		if !util.Contains(allowedFilterType, query.ClassroomFilterType) {
+			return fmt.Errorf("ClassroomFilterType error: %s, %+v", query.ClassroomFilterType, allowedFilterType)
+		}
+	}
+	if query.Remarks != "" {
+		if !util.Contains(allowedFilterType, query.RemarksFilterType) {
+			return fmt.Errorf("RemarksFilterType error: %s, %+v", query.RemarksFilterType, allowedFilterType)
+		}
+	}
+	if query.CourseNumberFilterType != "" {
+		if !util.Contains(allowedFilterType, query.CourseNumberFilterType) {
+			return fmt.Errorf("CourseNumberFilterType error: %s, %+v", query.CourseNumberFilterType, allowedFilterType)
+		}
+	}
 
 	if query.Period != "" {
-		_, err := kdb.PeriodParser(query.Period)
+		err := validatePeriod(query.Period)
 		if err != nil {
-			return fmt.Errorf("'period' parse error: %+v", err)
+			return err
 		}
 	}
 
 	if query.Term != "" {
-		terms := kdb.TermParser(query.Term)
-		if len(terms) == 0 {
-			// Term に何か与えられているもののパースした結果どの開講時期でも無いので与えられた文字列がおかしい
-			// "春Aははは" みたいな、きちんとした開講時期とおかしな文字列の両方が含まれる場合については、とりあえず考えないこととする
-			return fmt.Errorf("'term' parse error")
+		err := validateTerm(query.Term)
+		if err != nil {
+			return err
+		}
+	}
+
+	if query.PeriodMatchMode != "" {
+		err := validateMatchMode(query.PeriodMatchMode)
+		if err != nil {
+			return fmt.Errorf("'period_match_mode' error: %+v", err)
+		}
+	}
+
+	if query.TermMatchMode != "" {
+		err := validateMatchMode(query.TermMatchMode)
+		if err != nil {
+			return fmt.Errorf("'term_match_mode' error: %+v", err)
+		}
+	}
+
+	err := validateInstructionalType(query.InstructionalType)
+	if err != nil {
+		return err
+	}
+
+	if query.Credits != "" {
+		err := validateCredits(query.Credits)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = validateStandardRegistrationYear(query.StandardRegistrationYear)
+	if err != nil {
+		return err
+	}
+
+	if query.ExcludePeriod != "" {
+		err := validatePeriod(query.ExcludePeriod)
+		if err != nil {
+			return fmt.Errorf("'exclude_period' error: %+v", err)
+		}
+	}
+
+	if query.ExcludeTerm != "" {
+		err := validateTerm(query.ExcludeTerm)
+		if err != nil {
+			return fmt.Errorf("'exclude_term' error: %+v", err)
+		}
+	}
+
+	err = validateInstructionalType(query.ExcludeInstructionalType)
+	if err != nil {
+		return fmt.Errorf("'exclude_instructional_type' error: %+v", err)
+	}
+
+	for _, year := range query.Year {
+		if year == domain.YearLatest {
+			continue
+		}
+		y, err := strconv.Atoi(year)
+		if err != nil || y <= 0 {
+			return fmt.Errorf("'year' error: %s", year)
+		}
+	}
+
+	allowedSearchMode := []string{"", domain.SearchModeLike, domain.SearchModeFulltext}
+	if !util.Contains(allowedSearchMode, query.SearchMode) {
+		return fmt.Errorf("'search_mode' error: %s, %+v", query.SearchMode, allowedSearchMode)
+	}
+	if query.SearchMode == domain.SearchModeFulltext && query.Keyword != "" {
+		// 短いキーワードは無視されるので、すべて無視されると何も絞り込まれなくなってしまう
+		// 文字数は正規化した後のもので数える
+		keywords := util.SplitSpace(util.Normalize(query.Keyword, util.NormalizeOptions{}))
+		if len(util.DropShortWords(keywords, domain.FulltextMinKeywordLength)) == 0 {
+			return fmt.Errorf("'keyword' requires at least one word of %d or more characters in fulltext mode", domain.FulltextMinKeywordLength)
+		}
+	}
+
+	if len(query.Sort) > 2 {
+		return errors.New("'sort' accepts up to 2 keys")
+	}
+	allowedSortKey := []string{domain.SortKeyCourseNumber, domain.SortKeyCourseName, domain.SortKeyCredits, domain.SortKeyStandardRegistrationYear, domain.SortKeyPeriod, domain.SortKeyRelevance}
+	allowedSortOrder := []string{"", domain.SortOrderAsc, domain.SortOrderDesc}
+	sortKeys := []string{}
+	for _, sort := range query.Sort {
+		if !util.Contains(allowedSortKey, sort.Key) {
+			return fmt.Errorf("'sort' key error: %s, %+v", sort.Key, allowedSortKey)
+		}
+		if !util.Contains(allowedSortOrder, sort.Order) {
+			return fmt.Errorf("'sort' order error: %s, %+v", sort.Order, allowedSortOrder)
+		}
+		if util.Contains(sortKeys, sort.Key) {
+			return fmt.Errorf("'sort' key is duplicated: %s", sort.Key)
+		}
+		if sort.Key == domain.SortKeyRelevance {
+			if query.SearchMode == domain.SearchModeFulltext && query.Keyword == "" {
+				return errors.New("'sort' by relevance requires keyword in fulltext mode")
+			}
+			if query.CourseName == "" && query.CourseOverview == "" && query.Keyword == "" {
+				return errors.New("'sort' by relevance requires course_name, course_overview or keyword")
+			}
+		}
+		sortKeys = append(sortKeys, sort.Key)
+	}
+
+	if query.Q != "" {
+		filter, err := domain.ParseQuery(query.Q)
+		if err != nil {
+			return err
+		}
+		err = validateFilter(filter)
+		if err != nil {
+			return err
+		}
+	}
+
+	if query.Filter != nil {
+		err := validateFilter(query.Filter)
+		if err != nil {
+			return fmt.Errorf("'filter' error: %+v", err)
+		}
+	}
+
+	for _, kind := range query.Facets {
+		if !util.Contains(domain.FacetKinds, kind) {
+			return fmt.Errorf("'facets' error: %s, %+v", kind, domain.FacetKinds)
 		}
 	}
 
@@ -174,88 +459,330 @@ func validateSearchCourseQuery(query domain.CourseQuery) error {
 	return nil
 }
 
+func validatePeriod(period string) error {
+	_, err := kdb.PeriodParser(period)
+	if err != nil {
+		return fmt.Errorf("'period' parse error: %+v", err)
+	}
+	return nil
+}
+
+func validateFitsQuery(query domain.CourseFitsQuery) error {
+	if len(query.Occupied) == 0 && len(query.CourseNumbers) == 0 {
+		return errors.New("'occupied' or 'course_numbers' is required")
+	}
+	for _, slot := range query.Occupied {
+		if slot.Term == "" || slot.Period == "" {
+			return fmt.Errorf("'occupied' requires both term and period: %+v", slot)
+		}
+		err := validateTerm(slot.Term)
+		if err != nil {
+			return fmt.Errorf("'occupied' error: %+v", err)
+		}
+		err = validatePeriod(slot.Period)
+		if err != nil {
+			return fmt.Errorf("'occupied' error: %+v", err)
+		}
+	}
+	for _, number := range query.CourseNumbers {
+		if number == "" {
+			return errors.New("'course_numbers' must not contain an empty string")
+		}
+	}
+	return nil
+}
+
+func validateMatchMode(matchMode string) error {
+	if !util.Contains(domain.MatchModes, matchMode) {
+		return fmt.Errorf("match mode error: %s, %+v", matchMode, domain.MatchModes)
+	}
+	return nil
+}
+
+func validateTerm(term string) error {
+	terms := kdb.TermParser(term)
+	if len(terms) == 0 {
+		// Term に何か与えられているもののパースした結果どの開講時期でも無いので与えられた文字列がおかしい
+		// "春Aははは" みたいな、きちんとした開講時期とおかしな文字列の両方が含まれる場合については、とりあえず考えないこととする
+		return fmt.Errorf("'term' parse error")
+	}
+	return nil
+}
+
+func validateInstructionalType(instructionalTypes []string) error {
+	for _, instructionalType := range instructionalTypes {
+		i, err := strconv.Atoi(instructionalType)
+		if err != nil || i < 0 || 8 < i {
+			return fmt.Errorf("'instructional_type' range error: %s, 0 - 8", instructionalType)
+		}
+	}
+	return nil
+}
+
+func validateCredits(credits string) error {
+	_, _, err := util.ParseCreditsRange(credits)
+	if err != nil {
+		return fmt.Errorf("'credits' parse error: %+v", err)
+	}
+	return nil
+}
+
+func validateStandardRegistrationYear(years []string) error {
+	allowedStandardRegistrationYear := []string{"?", "1", "2", "3", "4", "5", "6"}
+	for _, year := range years {
+		if !util.Contains(allowedStandardRegistrationYear, year) {
+			return fmt.Errorf("'standard_registration_year' range error: %s, %+v", year, allowedStandardRegistrationYear)
+		}
+	}
+	return nil
+}
+
+// 検索条件の木を検証する
+// 検索式から作った木であれば、どこが不正かわかるよう、エラーは位置を持つ domain.QueryParseError とする
+func validateFilter(filter *domain.Filter) error {
+	withPosition := func(position int, err error) error {
+		if err == nil || position == 0 {
+			return err
+		}
+		return &domain.QueryParseError{Position: position, Message: err.Error()}
+	}
+
+	// JSON の filter は UnmarshalJSON で確かめてあるが、検索式から作った木はここで確かめる
+	if filter.Depth() > domain.FilterMaxDepth {
+		return withPosition(filter.Position, fmt.Errorf("filter is nested too deeply, up to %d", domain.FilterMaxDepth))
+	}
+
+	return filter.Walk(func(leaf *domain.Filter) error {
+		return withPosition(leaf.Position, validateFilterLeaf(leaf))
+	})
+}
+
+func validateFilterLeaf(leaf *domain.Filter) error {
+	if leaf.MatchMode != "" {
+		if leaf.Field != domain.FilterFieldPeriod && leaf.Field != domain.FilterFieldTerm {
+			return fmt.Errorf("'match_mode' is only allowed with 'period' or 'term': %s", leaf.Field)
+		}
+		err := validateMatchMode(leaf.MatchMode)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch leaf.Field {
+	case domain.FilterFieldInstructionalType:
+		return validateInstructionalType(leaf.Value)
+	case domain.FilterFieldStandardRegistrationYear:
+		return validateStandardRegistrationYear(leaf.Value)
+	}
+
+	for _, value := range leaf.Value {
+		var err error
+		switch leaf.Field {
+		case domain.FilterFieldPeriod:
+			err = validatePeriod(value)
+		case domain.FilterFieldTerm:
+			err = validateTerm(value)
+		case domain.FilterFieldCredits:
+			err = validateCredits(value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateSearchCourseQuery のエラーを返す
+// 検索式のエラーであれば、クライアントが位置を示せるように内容も返す
+func writeValidationError(w http.ResponseWriter, err error) {
+	var parseErr *domain.QueryParseError
+	if !errors.As(err, &parseErr) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resJson, err := json.Marshal(QueryErrorJSON{
+		Error:    parseErr.Message,
+		Position: parseErr.Position,
+	})
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusBadRequest)
+	_, err = w.Write(resJson)
+	if err != nil {
+		log.Printf("%+v", err)
+	}
+}
+
+// /csv
+// 検索条件に該当するすべての科目を CSV で返す、limit, offset は無視する
+// 文字コード・区切り文字・改行コードはリクエストの本文か Accept ヘッダーで、列と見出しはリクエストの本文で指定する
 func (h *courseHandler) Csv(w http.ResponseWriter, r *http.Request) {
 	if r.Header.Get("Content-Type") != "application/json" {
 		w.WriteHeader(http.StatusUnsupportedMediaType)
 		return
 	}
 
-	// TODO: domain が依存しているが良いか？
-	var query domain.CourseQuery
-	err := json.NewDecoder(r.Body).Decode(&query)
+	var csvQuery CsvQuery
+	err := json.NewDecoder(r.Body).Decode(&csvQuery)
 	if err != nil {
 		log.Printf("%+v", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	query := csvQuery.CourseQuery
 
 	err = validateSearchCourseQuery(query)
+	if err != nil {
+		log.Printf("%+v", err)
+		writeValidationError(w, err)
+		return
+	}
+	dialect, err := newCsvDialect(csvQuery, r.Header.Get("Accept"))
 	if err != nil {
 		log.Printf("%+v", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	// TODO: 無理矢理書き換えないようにする
-	query.Offset = 0
-	query.Limit = 10000000
-
-	courses, err := h.uc.Search(query)
+	filename, err := h.csvFilename(query, dialect.extension())
 	if err != nil {
 		log.Printf("%+v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	var coursesCsv []CourseCSV
-	for _, course := range courses {
-		// TODO: Term をカンマ区切りで結合する
-		term := ""
-		for _, termIndex := range course.Term {
-			termStr, err := decodeTerm(termIndex)
+	// データベースから 1 件ずつ取り出しながら書き込み、結果全体をメモリに載せない
+	// レスポンスのヘッダーは最初の科目を取り出せてから書き込み、それまでのエラーはステータスコードで返す
+	ctx := r.Context()
+	var csvWriter *gocsv.SafeCSVWriter
+	var csvCloser io.Closer
+	started := false
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", dialect.contentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		w.WriteHeader(http.StatusOK)
+
+		var err error
+		csvWriter, csvCloser, err = dialect.newWriter(w)
+		if err != nil {
+			return err
+		}
+		return csvWriter.Write(dialect.headerRecord())
+	}
+
+	rowCount := 0
+	err = h.uc.Stream(ctx, query, func(course *domain.Course) error {
+		// クライアントが切断していればデータベースからの取り出しも止める
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+		if !started {
+			err = start()
 			if err != nil {
-				log.Printf("%+v", err)
-				w.WriteHeader(http.StatusInternalServerError)
-				return
+				return err
 			}
+		}
 
-			term += termStr
+		record, err := dialect.record(course)
+		if err != nil {
+			return err
+		}
+		err = csvWriter.Write(record)
+		if err != nil {
+			return err
 		}
 
-		courseCsv := CourseCSV{
-			CourseNumber:             course.CourseNumber,
-			CourseName:               course.CourseName,
-			InstructionalType:        course.InstructionalType,
-			Credits:                  course.Credits,
-			StandardRegistrationYear: strings.Join(course.StandardRegistrationYear, ","),
-			Term:                     term,
-			Period:                   strings.Join(course.Period, ","),
-			Classroom:                course.Classroom,
-			Instructor:               strings.Join(course.Instructor, ","),
-			CourseOverview:           course.CourseOverview,
-			Remarks:                  course.Remarks,
-			CreditedAuditors:         course.CreditedAuditors,
-			ApplicationConditions:    course.ApplicationConditions,
-			AltCourseName:            course.AltCourseName,
-			CourseCode:               course.CourseCode,
-			CourseCodeName:           course.CourseCodeName,
-			UpdatedAt:                course.UpdatedAt,
+		rowCount++
+		if rowCount%csvFlushRows == 0 {
+			csvWriter.Flush()
+			flush(w)
+			return csvWriter.Error()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("%+v", err)
+		// 書き込み始めていればステータスコードは変えられないので、書き込んだ分を送り出して打ち切る
+		if !started {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// BOM を書き込めなかったときなど、書き込み先を作る前に失敗していれば送り出すものは無い
+		if csvWriter != nil {
+			csvWriter.Flush()
+			csvCloser.Close()
+			flush(w)
 		}
-		coursesCsv = append(coursesCsv, courseCsv)
+		return
 	}
 
-	csvStr, err := gocsv.MarshalString(coursesCsv)
+	if !started {
+		err = start()
+		if err != nil {
+			log.Printf("%+v", err)
+			return
+		}
+	}
+	csvWriter.Flush()
+	err = csvWriter.Error()
 	if err != nil {
 		log.Printf("%+v", err)
-		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-
-	w.Header().Set("Content-Type", "text/csv; charset=UTF-8")
-	w.WriteHeader(http.StatusOK)
-	_, err = w.Write([]byte(csvStr))
+	err = csvCloser.Close()
 	if err != nil {
 		log.Printf("%+v", err)
+		return
+	}
+	flush(w)
+}
+
+// /csv でレスポンスを送り出す間隔の行数
+const csvFlushRows = 100
+
+// テストで日時を固定できるように変数にしておく
+var now = time.Now
+
+// /csv のファイル名、courses_{年度}_{日時}.{拡張子}
+// 年度を指定していなければ登録されている最新の年度、複数の年度は - でつなぐ
+func (h *courseHandler) csvFilename(query domain.CourseQuery, extension string) (string, error) {
+	queryYears := query.Year
+	if len(queryYears) == 0 {
+		queryYears = []string{domain.YearLatest}
+	}
+
+	years := []string{}
+	for _, year := range queryYears {
+		if year == domain.YearLatest {
+			academicYears, err := h.uc.Years()
+			if err != nil {
+				return "", err
+			}
+			// 登録されている年度が無ければそのまま
+			if len(academicYears) != 0 {
+				year = strconv.Itoa(academicYears[0].Year)
+			}
+		}
+		if !util.Contains(years, year) {
+			years = append(years, year)
+		}
+	}
+
+	return fmt.Sprintf("courses_%s_%s.%s", strings.Join(years, "-"), now().Format("20060102150405"), extension), nil
+}
+
+// 書き込んだ分をクライアントへ送り出す
+func flush(w http.ResponseWriter) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
 	}
 }
 
@@ -301,7 +828,7 @@ func (h *courseHandler) Facet(w http.ResponseWriter, r *http.Request) {
 	err = validateSearchCourseQuery(query)
 	if err != nil {
 		log.Printf("%+v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		writeValidationError(w, err)
 		return
 	}
 
@@ -312,12 +839,39 @@ func (h *courseHandler) Facet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	periodFacets, err := h.uc.PeriodFacet(query)
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
 	termFacet := map[int]int{}
 	for _, facet := range facets {
 		termFacet[facet.Term] = facet.TermCount
 	}
+	periodFacet := map[string]int{}
+	for _, period := range domain.PeriodFacetPeriods {
+		periodFacet[period] = 0
+	}
+	for _, facet := range periodFacets {
+		periodFacet[facet.Period] = facet.Count
+	}
 	facetJson := FacetJSON{
-		TermFacet: termFacet,
+		TermFacet:   termFacet,
+		PeriodFacet: periodFacet,
+	}
+
+	// term_facet, period_facet はこれまで通りすべての条件を適用して数え、
+	// それに加えて要求された集計を、その集計の対象のカラムに対する条件を取り除いて数える
+	if len(query.Facets) != 0 {
+		facetValues, err := h.uc.FacetValues(query)
+		if err != nil {
+			log.Printf("%+v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		facetJson.Facets = toFacetValuesJSON(query.Facets, facetValues)
 	}
 
 	j, err := json.Marshal(facetJson)
@@ -334,3 +888,44 @@ func (h *courseHandler) Facet(w http.ResponseWriter, r *http.Request) {
 		log.Printf("%+v", err)
 	}
 }
+
+// 要求された集計の種類ごとの値と科目数、該当する値が無い種類も空の配列として含める
+func toFacetValuesJSON(kinds []string, facets map[string][]*domain.FacetValue) map[string][]FacetValueJSON {
+	facetsJson := map[string][]FacetValueJSON{}
+	for _, kind := range kinds {
+		values := []FacetValueJSON{}
+		for _, facet := range facets[kind] {
+			values = append(values, FacetValueJSON(*facet))
+		}
+		facetsJson[kind] = values
+	}
+	return facetsJson
+}
+
+func (h *courseHandler) Years(w http.ResponseWriter, r *http.Request) {
+	years, err := h.uc.Years()
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	yearsJson := []AcademicYearJSON{}
+	for _, year := range years {
+		yearsJson = append(yearsJson, AcademicYearJSON(*year))
+	}
+
+	j, err := json.Marshal(yearsJson)
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(j)
+	if err != nil {
+		log.Printf("%+v", err)
+	}
+}
@@ -2,34 +2,84 @@ package handler
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/sylms/azuki/domain"
+	"github.com/sylms/csv2sql/kdb"
 )
 
 type courseUseCaseMock struct {
 	domain.Course
-	FakeSearch func(domain.CourseQuery) ([]*domain.Course, error)
-	FakeFacet  func(domain.CourseQuery) ([]*domain.Facet, error)
+	FakeSearch      func(domain.CourseQuery) (*domain.CourseSearchResult, error)
+	FakeStream      func(context.Context, domain.CourseQuery, func(*domain.Course) error) error
+	FakeFacet       func(domain.CourseQuery) ([]*domain.Facet, error)
+	FakePeriodFacet func(domain.CourseQuery) ([]*domain.PeriodFacet, error)
+	FakeFacetValues func(domain.CourseQuery) (map[string][]*domain.FacetValue, error)
+	FakeYears       func() ([]*domain.AcademicYear, error)
+	FakeFits        func(domain.CourseFitsQuery) (*domain.CourseSearchResult, error)
+
+	FakeFindByID     func(int) (*domain.Course, error)
+	FakeFindByNumber func(int, string) (*domain.Course, error)
 }
 
-func (uc *courseUseCaseMock) Search(query domain.CourseQuery) ([]*domain.Course, error) {
+func (uc *courseUseCaseMock) Search(query domain.CourseQuery) (*domain.CourseSearchResult, error) {
 	return uc.FakeSearch(query)
 }
 
+func (uc *courseUseCaseMock) Stream(ctx context.Context, query domain.CourseQuery, fn func(*domain.Course) error) error {
+	return uc.FakeStream(ctx, query, fn)
+}
+
 func (uc *courseUseCaseMock) Facet(query domain.CourseQuery) ([]*domain.Facet, error) {
 	return uc.FakeFacet(query)
 }
 
+func (uc *courseUseCaseMock) PeriodFacet(query domain.CourseQuery) ([]*domain.PeriodFacet, error) {
+	return uc.FakePeriodFacet(query)
+}
+
+func (uc *courseUseCaseMock) FacetValues(query domain.CourseQuery) (map[string][]*domain.FacetValue, error) {
+	return uc.FakeFacetValues(query)
+}
+
+func (uc *courseUseCaseMock) Years() ([]*domain.AcademicYear, error) {
+	return uc.FakeYears()
+}
+
+func (uc *courseUseCaseMock) Fits(query domain.CourseFitsQuery) (*domain.CourseSearchResult, error) {
+	return uc.FakeFits(query)
+}
+
+func (uc *courseUseCaseMock) FindByID(id int) (*domain.Course, error) {
+	return uc.FakeFindByID(id)
+}
+
+func (uc *courseUseCaseMock) FindByNumber(year int, number string) (*domain.Course, error) {
+	return uc.FakeFindByNumber(year, number)
+}
+
+// ID 以外がゼロ値の科目の JSON
+func emptyCourseJSON(id int) string {
+	return fmt.Sprintf(`{"id":%d,"course_number":"","course_name":"","instructional_type":0,"credits":"","standard_registration_year":null,"term":null,"period":null,"classroom":"","instructor":null,"course_overview":"","remarks":"","credited_auditors":0,"application_conditions":"","alt_course_name":"","course_code":"","course_code_name":"","csv_updated_at":"0001-01-01T00:00:00Z","year":0,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}`, id)
+}
+
 func Test_courseHandler_Search(t *testing.T) {
 	type fakeSearch struct {
-		Search func(domain.CourseQuery) ([]*domain.Course, error)
+		Search func(domain.CourseQuery) (*domain.CourseSearchResult, error)
 	}
 	tests := []struct {
 		name                 string
 		fakeSearch           fakeSearch
+		reqQuery             string
 		reqContentTypeHeader string
 		reqBody              string
 		wantResStatusCode    int
@@ -38,7 +88,7 @@ func Test_courseHandler_Search(t *testing.T) {
 		{
 			name: "normal",
 			fakeSearch: fakeSearch{
-				Search: func(cq domain.CourseQuery) ([]*domain.Course, error) {
+				Search: func(cq domain.CourseQuery) (*domain.CourseSearchResult, error) {
 					courses := []*domain.Course{
 						{
 							ID:                       18010,
@@ -61,9 +111,61 @@ func Test_courseHandler_Search(t *testing.T) {
 							Year:                     2021,
 						},
 					}
-					return courses, nil
+					return &domain.CourseSearchResult{Courses: courses, Total: len(courses)}, nil
+				},
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody: `{
+		    "course_number": "GA10101",
+		    "course_name": "情報社会と法制度",
+		    "instructional_type": -1,
+		    "credits": "",
+		    "standard_registration_year": -1,
+		    "term": "",
+		    "period": "",
+		    "classroom": "",
+		    "instructor": "",
+		    "course_overview": "",
+		    "remarks": "",
+		    "course_name_filter_type": "and",
+		    "course_overview_filter_type": "and",
+		    "filter_type": "and",
+		    "limit": 20,
+		    "offset": 0
+		}`,
+			wantResStatusCode: http.StatusOK,
+			wantResBody:       `{"total":1,"limit":20,"offset":0,"has_next":false,"items":[{"id":18010,"course_number":"GA10101","course_name":"情報社会と法制度","instructional_type":1,"credits":"2.0","standard_registration_year":["2"],"term":[4,5],"period":["月5","月6"],"classroom":"","instructor":["髙良 幸哉"],"course_overview":"情報化社会における法制度や情報モラル向上に必要な基礎知識を習得することを目指すため、現行の我が国の法制度の基礎を学び、ネットワーク社会における法整備の現状について講義する。","remarks":"オンライン(オンデマンド型)","credited_auditors":0,"application_conditions":"正規生に対しても受講制限をしているため","alt_course_name":"Information Society Law","course_code":"GA10101","course_code_name":"情報社会と法制度","csv_updated_at":"0001-01-01T00:00:00Z","year":2021,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}]}`,
+		},
+		{
+			name: "format=array を指定すると以前の形式 (科目の配列) で返す",
+			fakeSearch: fakeSearch{
+				Search: func(cq domain.CourseQuery) (*domain.CourseSearchResult, error) {
+					courses := []*domain.Course{
+						{
+							ID:                       18010,
+							CourseNumber:             "GA10101",
+							CourseName:               "情報社会と法制度",
+							InstructionalType:        1,
+							Credits:                  "2.0",
+							StandardRegistrationYear: []string{"2"},
+							Term:                     []int{4, 5},
+							Period:                   []string{"月5", "月6"},
+							Classroom:                "",
+							Instructor:               []string{"髙良 幸哉"},
+							CourseOverview:           "情報化社会における法制度や情報モラル向上に必要な基礎知識を習得することを目指すため、現行の我が国の法制度の基礎を学び、ネットワーク社会における法整備の現状について講義する。",
+							Remarks:                  "オンライン(オンデマンド型)",
+							CreditedAuditors:         0,
+							ApplicationConditions:    "正規生に対しても受講制限をしているため",
+							AltCourseName:            "Information Society Law",
+							CourseCode:               "GA10101",
+							CourseCodeName:           "情報社会と法制度",
+							Year:                     2021,
+						},
+					}
+					return &domain.CourseSearchResult{Courses: courses, Total: len(courses)}, nil
 				},
 			},
+			reqQuery:             "?format=array",
 			reqContentTypeHeader: "application/json",
 			reqBody: `{
 		    "course_number": "GA10101",
@@ -86,12 +188,54 @@ func Test_courseHandler_Search(t *testing.T) {
 			wantResStatusCode: http.StatusOK,
 			wantResBody:       `[{"id":18010,"course_number":"GA10101","course_name":"情報社会と法制度","instructional_type":1,"credits":"2.0","standard_registration_year":["2"],"term":[4,5],"period":["月5","月6"],"classroom":"","instructor":["髙良 幸哉"],"course_overview":"情報化社会における法制度や情報モラル向上に必要な基礎知識を習得することを目指すため、現行の我が国の法制度の基礎を学び、ネットワーク社会における法整備の現状について講義する。","remarks":"オンライン(オンデマンド型)","credited_auditors":0,"application_conditions":"正規生に対しても受講制限をしているため","alt_course_name":"Information Society Law","course_code":"GA10101","course_code_name":"情報社会と法制度","csv_updated_at":"0001-01-01T00:00:00Z","year":2021,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}]`,
 		},
+		{
+			name: "授業方法・標準履修年次を配列で指定できる",
+			fakeSearch: fakeSearch{
+				Search: func(cq domain.CourseQuery) (*domain.CourseSearchResult, error) {
+					if !reflect.DeepEqual(cq.InstructionalType, domain.MultiValue{"1", "4"}) {
+						return nil, fmt.Errorf("unexpected InstructionalType: %+v", cq.InstructionalType)
+					}
+					if !reflect.DeepEqual(cq.StandardRegistrationYear, domain.MultiValue{"?", "2"}) {
+						return nil, fmt.Errorf("unexpected StandardRegistrationYear: %+v", cq.StandardRegistrationYear)
+					}
+					return &domain.CourseSearchResult{Courses: []*domain.Course{}}, nil
+				},
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody: `{
+		    "instructional_type": [1, 4],
+		    "credits": "1.0-2.0",
+		    "standard_registration_year": ["?", 2],
+		    "filter_type": "and",
+		    "limit": 20,
+		    "offset": 0
+		}`,
+			wantResStatusCode: http.StatusOK,
+			wantResBody:       `{"total":0,"limit":20,"offset":0,"has_next":false,"items":[]}`,
+		},
+		{
+			name: "範囲外の授業方法を指定するとエラー",
+			fakeSearch: fakeSearch{
+				Search: func(cq domain.CourseQuery) (*domain.CourseSearchResult, error) {
+					return &domain.CourseSearchResult{Courses: []*domain.Course{}}, nil
+				},
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody: `{
+		    "instructional_type": 9,
+		    "filter_type": "and",
+		    "limit": 20,
+		    "offset": 0
+		}`,
+			wantResStatusCode: http.StatusBadRequest,
+			wantResBody:       ``,
+		},
 		{
 			name: "検索条件に該当する科目が存在しないときに空配列を表す JSON 文字列を返す",
 			fakeSearch: fakeSearch{
-				Search: func(cq domain.CourseQuery) ([]*domain.Course, error) {
+				Search: func(cq domain.CourseQuery) (*domain.CourseSearchResult, error) {
 					courses := []*domain.Course{}
-					return courses, nil
+					return &domain.CourseSearchResult{Courses: courses, Total: len(courses)}, nil
 				},
 			},
 			reqContentTypeHeader: "application/json",
@@ -114,13 +258,170 @@ func Test_courseHandler_Search(t *testing.T) {
 		    "offset": 0
 		}`,
 			wantResStatusCode: http.StatusOK,
-			wantResBody:       `[]`,
+			wantResBody:       `{"total":0,"limit":20,"offset":0,"has_next":false,"items":[]}`,
+		},
+		{
+			name: "続きのページがあるときは has_next が true になる",
+			fakeSearch: fakeSearch{
+				Search: func(cq domain.CourseQuery) (*domain.CourseSearchResult, error) {
+					courses := []*domain.Course{}
+					for i := 0; i < cq.Limit; i++ {
+						courses = append(courses, &domain.Course{ID: cq.Offset + i})
+					}
+					return &domain.CourseSearchResult{Courses: courses, Total: 45, HasNext: true, NextCursor: "fake-cursor"}, nil
+				},
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody: `{
+		    "filter_type": "and",
+		    "limit": 2,
+		    "offset": 20
+		}`,
+			wantResStatusCode: http.StatusOK,
+			wantResBody:       `{"total":45,"limit":2,"offset":20,"has_next":true,"next_cursor":"fake-cursor","items":[` + emptyCourseJSON(20) + `,` + emptyCourseJSON(21) + `]}`,
+		},
+		{
+			name: "cursor をそのまま渡す",
+			fakeSearch: fakeSearch{
+				Search: func(cq domain.CourseQuery) (*domain.CourseSearchResult, error) {
+					if cq.Cursor != "fake-cursor" {
+						return nil, fmt.Errorf("unexpected Cursor: %s", cq.Cursor)
+					}
+					return &domain.CourseSearchResult{Courses: []*domain.Course{}, Total: 45}, nil
+				},
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody: `{
+		    "filter_type": "and",
+		    "limit": 2,
+		    "offset": 0,
+		    "cursor": "fake-cursor"
+		}`,
+			wantResStatusCode: http.StatusOK,
+			wantResBody:       `{"total":45,"limit":2,"offset":0,"has_next":false,"items":[]}`,
+		},
+		{
+			name: "最後のページでは has_next が false になる",
+			fakeSearch: fakeSearch{
+				Search: func(cq domain.CourseQuery) (*domain.CourseSearchResult, error) {
+					courses := []*domain.Course{{ID: 44}}
+					return &domain.CourseSearchResult{Courses: courses, Total: 45}, nil
+				},
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody: `{
+		    "filter_type": "and",
+		    "limit": 2,
+		    "offset": 44
+		}`,
+			wantResStatusCode: http.StatusOK,
+			wantResBody:       `{"total":45,"limit":2,"offset":44,"has_next":false,"items":[` + emptyCourseJSON(44) + `]}`,
+		},
+		{
+			name: "facets を指定すると検索結果と合わせて集計を返す",
+			fakeSearch: fakeSearch{
+				Search: func(cq domain.CourseQuery) (*domain.CourseSearchResult, error) {
+					return &domain.CourseSearchResult{
+						Courses: []*domain.Course{},
+						Total:   0,
+						Facets: map[string][]*domain.FacetValue{
+							domain.FacetKindCredits: {
+								{Value: "2.0", Count: 1},
+								{Value: "3.0", Count: 1},
+							},
+						},
+					}, nil
+				},
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody: `{
+		    "instructor": "天笠",
+		    "instructor_filter_type": "and",
+		    "credits": "9.0",
+		    "filter_type": "and",
+		    "facets": ["credits", "department"],
+		    "limit": 20,
+		    "offset": 0
+		}`,
+			wantResStatusCode: http.StatusOK,
+			wantResBody:       `{"total":0,"limit":20,"offset":0,"has_next":false,"items":[],"facets":{"credits":[{"value":"2.0","count":1},{"value":"3.0","count":1}],"department":[]}}`,
+		},
+		{
+			name: "facets の種類が不正",
+			fakeSearch: fakeSearch{
+				Search: func(cq domain.CourseQuery) (*domain.CourseSearchResult, error) {
+					t.Fatal("Search must not be called")
+					return nil, nil
+				},
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody: `{
+		    "filter_type": "and",
+		    "facets": ["classroom"],
+		    "limit": 20,
+		    "offset": 0
+		}`,
+			wantResStatusCode: http.StatusBadRequest,
+			wantResBody:       ``,
+		},
+		{
+			name: "検索式の構文エラーは位置とともに返す",
+			fakeSearch: fakeSearch{
+				Search: func(cq domain.CourseQuery) (*domain.CourseSearchResult, error) {
+					t.Fatal("Search must not be called")
+					return nil, nil
+				},
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody: `{
+		    "q": "線形代数 (演習 OR 実験",
+		    "filter_type": "and",
+		    "limit": 20,
+		    "offset": 0
+		}`,
+			wantResStatusCode: http.StatusBadRequest,
+			wantResBody:       `{"error":"unclosed parenthesis","position":6}`,
+		},
+		{
+			name: "検索式の値が不正な場合もその位置を返す",
+			fakeSearch: fakeSearch{
+				Search: func(cq domain.CourseQuery) (*domain.CourseSearchResult, error) {
+					t.Fatal("Search must not be called")
+					return nil, nil
+				},
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody: `{
+		    "q": "線形代数 type:9",
+		    "filter_type": "and",
+		    "limit": 20,
+		    "offset": 0
+		}`,
+			wantResStatusCode: http.StatusBadRequest,
+			wantResBody:       `{"error":"'instructional_type' range error: 9, 0 - 8","position":6}`,
+		},
+		{
+			name: "検索式以外の検証エラーは本文を返さない",
+			fakeSearch: fakeSearch{
+				Search: func(cq domain.CourseQuery) (*domain.CourseSearchResult, error) {
+					t.Fatal("Search must not be called")
+					return nil, nil
+				},
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody: `{
+		    "filter_type": "xor",
+		    "limit": 20,
+		    "offset": 0
+		}`,
+			wantResStatusCode: http.StatusBadRequest,
+			wantResBody:       ``,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			req, err := http.NewRequest(http.MethodPost, "/course", bytes.NewBufferString(tt.reqBody))
+			req, err := http.NewRequest(http.MethodPost, "/course"+tt.reqQuery, bytes.NewBufferString(tt.reqBody))
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -149,90 +450,107 @@ func Test_courseHandler_Search(t *testing.T) {
 	}
 }
 
-func Test_courseHandler_Csv(t *testing.T) {
-	type fakeSearch struct {
-		Search func(domain.CourseQuery) ([]*domain.Course, error)
-	}
+func Test_courseHandler_Fits(t *testing.T) {
 	tests := []struct {
-		name                 string
-		fakeSearch           fakeSearch
-		reqContentTypeHeader string
-		reqBody              string
-		wantResStatusCode    int
-		wantResBody          string
+		name              string
+		fakeFits          func(domain.CourseFitsQuery) (*domain.CourseSearchResult, error)
+		reqBody           string
+		wantResStatusCode int
+		wantResBody       string
 	}{
 		{
-			name: "temp",
-			fakeSearch: fakeSearch{
-				Search: func(cq domain.CourseQuery) ([]*domain.Course, error) {
-					courses := []*domain.Course{
-						{
-							ID:                       18010,
-							CourseNumber:             "GA10101",
-							CourseName:               "情報社会と法制度",
-							InstructionalType:        1,
-							Credits:                  "2.0",
-							StandardRegistrationYear: []string{"2"},
-							Term:                     []int{4, 5},
-							Period:                   []string{"月5", "月6"},
-							Classroom:                "",
-							Instructor:               []string{"髙良 幸哉"},
-							CourseOverview:           "情報化社会における法制度や情報モラル向上に必要な基礎知識を習得することを目指すため、現行の我が国の法制度の基礎を学び、ネットワーク社会における法整備の現状について講義する。",
-							Remarks:                  "オンライン(オンデマンド型)",
-							CreditedAuditors:         0,
-							ApplicationConditions:    "正規生に対しても受講制限をしているため",
-							AltCourseName:            "Information Society Law",
-							CourseCode:               "GA10101",
-							CourseCodeName:           "情報社会と法制度",
-							Year:                     2021,
-						},
-					}
-					return courses, nil
-				},
+			name: "検索条件と時間割を受け取る",
+			fakeFits: func(query domain.CourseFitsQuery) (*domain.CourseSearchResult, error) {
+				want := domain.CourseFitsQuery{
+					CourseQuery: domain.CourseQuery{
+						CourseName:           "情報",
+						CourseNameFilterType: "and",
+						FilterType:           "and",
+						Limit:                20,
+					},
+					Occupied: []domain.TimetableSlot{
+						{Term: "春AB", Period: "月1,2"},
+					},
+					CourseNumbers: []string{"GB11931"},
+				}
+				if !reflect.DeepEqual(query, want) {
+					t.Errorf("query mismatch:\ngot: %+v\nwant: %+v", query, want)
+				}
+				return &domain.CourseSearchResult{
+					Courses: []*domain.Course{{ID: 1}},
+					Total:   1,
+				}, nil
 			},
-			reqContentTypeHeader: "application/json",
 			reqBody: `{
-		    "course_number": "GA10101",
-		    "course_name": "情報社会と法制度",
-		    "instructional_type": -1,
-		    "credits": "",
-		    "standard_registration_year": -1,
-		    "term": "",
-		    "period": "",
-		    "classroom": "",
-		    "instructor": "",
-		    "course_overview": "",
-		    "remarks": "",
+		    "course_name": "情報",
 		    "course_name_filter_type": "and",
-		    "course_overview_filter_type": "and",
 		    "filter_type": "and",
 		    "limit": 20,
-		    "offset": 0
+		    "occupied": [{"term": "春AB", "period": "月1,2"}],
+		    "course_numbers": ["GB11931"]
 		}`,
 			wantResStatusCode: http.StatusOK,
-			wantResBody: `科目番号,科目名,授業方法,単位数,標準履修年次,実施学期,曜時限,教室,担当教員,授業概要,備考,科目等履修生申請可否,申請条件,英語(日本語)科目名,科目コード,要件科目名,データ更新日
-GA10101,情報社会と法制度,1,2.0,2,秋A秋B,"月5,月6",,髙良 幸哉,情報化社会における法制度や情報モラル向上に必要な基礎知識を習得することを目指すため、現行の我が国の法制度の基礎を学び、ネットワーク社会における法整備の現状について講義する。,オンライン(オンデマンド型),0,正規生に対しても受講制限をしているため,Information Society Law,GA10101,情報社会と法制度,0001-01-01T00:00:00Z
-`,
+			wantResBody:       `{"total":1,"limit":20,"offset":0,"has_next":false,"items":[` + emptyCourseJSON(1) + `]}`,
+		},
+		{
+			name: "時間割が無い",
+			fakeFits: func(query domain.CourseFitsQuery) (*domain.CourseSearchResult, error) {
+				t.Fatal("Fits must not be called")
+				return nil, nil
+			},
+			reqBody: `{
+		    "filter_type": "and",
+		    "limit": 20
+		}`,
+			wantResStatusCode: http.StatusBadRequest,
+			wantResBody:       ``,
+		},
+		{
+			name: "埋まっているコマの曜時限が不正",
+			fakeFits: func(query domain.CourseFitsQuery) (*domain.CourseSearchResult, error) {
+				t.Fatal("Fits must not be called")
+				return nil, nil
+			},
+			reqBody: `{
+		    "filter_type": "and",
+		    "limit": 20,
+		    "occupied": [{"term": "春A", "period": "月9"}]
+		}`,
+			wantResStatusCode: http.StatusBadRequest,
+			wantResBody:       ``,
+		},
+		{
+			name: "履修している科目が見つからない",
+			fakeFits: func(query domain.CourseFitsQuery) (*domain.CourseSearchResult, error) {
+				return nil, errors.New("course not found: XX00000")
+			},
+			reqBody: `{
+		    "filter_type": "and",
+		    "limit": 20,
+		    "course_numbers": ["XX00000"]
+		}`,
+			wantResStatusCode: http.StatusBadRequest,
+			wantResBody:       ``,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			req, err := http.NewRequest(http.MethodPost, "/csv", bytes.NewBufferString(tt.reqBody))
+			req, err := http.NewRequest(http.MethodPost, "/course/fits", bytes.NewBufferString(tt.reqBody))
 			if err != nil {
 				t.Fatal(err)
 			}
-			req.Header.Set("Content-Type", tt.reqContentTypeHeader)
+			req.Header.Set("Content-Type", "application/json")
 
 			res := httptest.NewRecorder()
 
 			h := &courseHandler{
 				uc: &courseUseCaseMock{
-					FakeSearch: tt.fakeSearch.Search,
+					FakeFits: tt.fakeFits,
 				},
 			}
 
-			h.Csv(res, req)
+			h.Fits(res, req)
 
 			resBodyGot := res.Body.String()
 			if resBodyGot != tt.wantResBody {
@@ -247,10 +565,211 @@ GA10101,情報社会と法制度,1,2.0,2,秋A秋B,"月5,月6",,髙良 幸哉,情
 	}
 }
 
-func Test_courseHandler_Facet(t *testing.T) {
-	t.Run("temp", func(t *testing.T) {
-		want := `{"term_facet":{"1":111,"2":222}}`
-		reqBody := `{
+func Test_courseHandler_FindByID(t *testing.T) {
+	tests := []struct {
+		name              string
+		vars              map[string]string
+		fakeFindByID      func(int) (*domain.Course, error)
+		wantResStatusCode int
+		wantResBody       string
+	}{
+		{
+			name: "normal",
+			vars: map[string]string{"id": "18010"},
+			fakeFindByID: func(id int) (*domain.Course, error) {
+				return &domain.Course{ID: id}, nil
+			},
+			wantResStatusCode: http.StatusOK,
+			wantResBody:       emptyCourseJSON(18010),
+		},
+		{
+			name: "見つからなければ 404",
+			vars: map[string]string{"id": "1"},
+			fakeFindByID: func(id int) (*domain.Course, error) {
+				return nil, domain.ErrCourseNotFound
+			},
+			wantResStatusCode: http.StatusNotFound,
+			wantResBody:       ``,
+		},
+		{
+			name: "その他のエラーは 500",
+			vars: map[string]string{"id": "1"},
+			fakeFindByID: func(id int) (*domain.Course, error) {
+				return nil, errors.New("connection refused")
+			},
+			wantResStatusCode: http.StatusInternalServerError,
+			wantResBody:       ``,
+		},
+		{
+			name: "ID が数値でない",
+			vars: map[string]string{"id": "GA10101"},
+			fakeFindByID: func(id int) (*domain.Course, error) {
+				t.Fatal("FindByID must not be called")
+				return nil, nil
+			},
+			wantResStatusCode: http.StatusBadRequest,
+			wantResBody:       ``,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/course/"+tt.vars["id"], nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = mux.SetURLVars(req, tt.vars)
+
+			res := httptest.NewRecorder()
+
+			h := &courseHandler{
+				uc: &courseUseCaseMock{
+					FakeFindByID: tt.fakeFindByID,
+				},
+			}
+
+			h.FindByID(res, req)
+
+			resBodyGot := res.Body.String()
+			if resBodyGot != tt.wantResBody {
+				t.Errorf("response mismatch:\ngot: %s\nwant: %s", resBodyGot, tt.wantResBody)
+			}
+
+			statusCodeGot := res.Code
+			if statusCodeGot != tt.wantResStatusCode {
+				t.Errorf("response status code mismatch:\ngot: %d\nwant: %d", statusCodeGot, tt.wantResStatusCode)
+			}
+		})
+	}
+}
+
+func Test_courseHandler_FindByNumber(t *testing.T) {
+	tests := []struct {
+		name              string
+		vars              map[string]string
+		fakeFindByNumber  func(int, string) (*domain.Course, error)
+		wantResStatusCode int
+		wantResBody       string
+	}{
+		{
+			name: "normal",
+			vars: map[string]string{"year": "2021", "course_number": "GA10101"},
+			fakeFindByNumber: func(year int, number string) (*domain.Course, error) {
+				if year != 2021 || number != "GA10101" {
+					t.Errorf("unexpected args: %d, %s", year, number)
+				}
+				return &domain.Course{ID: 18010}, nil
+			},
+			wantResStatusCode: http.StatusOK,
+			wantResBody:       emptyCourseJSON(18010),
+		},
+		{
+			name: "見つからなければ 404",
+			vars: map[string]string{"year": "2020", "course_number": "GA10101"},
+			fakeFindByNumber: func(year int, number string) (*domain.Course, error) {
+				return nil, domain.ErrCourseNotFound
+			},
+			wantResStatusCode: http.StatusNotFound,
+			wantResBody:       ``,
+		},
+		{
+			name: "年度が数値でない",
+			vars: map[string]string{"year": "latest", "course_number": "GA10101"},
+			fakeFindByNumber: func(year int, number string) (*domain.Course, error) {
+				t.Fatal("FindByNumber must not be called")
+				return nil, nil
+			},
+			wantResStatusCode: http.StatusBadRequest,
+			wantResBody:       ``,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/courses/"+tt.vars["year"]+"/"+tt.vars["course_number"], nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = mux.SetURLVars(req, tt.vars)
+
+			res := httptest.NewRecorder()
+
+			h := &courseHandler{
+				uc: &courseUseCaseMock{
+					FakeFindByNumber: tt.fakeFindByNumber,
+				},
+			}
+
+			h.FindByNumber(res, req)
+
+			resBodyGot := res.Body.String()
+			if resBodyGot != tt.wantResBody {
+				t.Errorf("response mismatch:\ngot: %s\nwant: %s", resBodyGot, tt.wantResBody)
+			}
+
+			statusCodeGot := res.Code
+			if statusCodeGot != tt.wantResStatusCode {
+				t.Errorf("response status code mismatch:\ngot: %d\nwant: %d", statusCodeGot, tt.wantResStatusCode)
+			}
+		})
+	}
+}
+
+func Test_courseHandler_Csv(t *testing.T) {
+	now = func() time.Time {
+		return time.Date(2021, 4, 1, 9, 30, 0, 0, time.UTC)
+	}
+	defer func() {
+		now = time.Now
+	}()
+
+	course := &domain.Course{
+		ID:                       18010,
+		CourseNumber:             "GA10101",
+		CourseName:               "情報社会と法制度",
+		InstructionalType:        1,
+		Credits:                  "2.0",
+		StandardRegistrationYear: []string{"2"},
+		Term:                     []int{4, 5},
+		Period:                   []string{"月5", "月6"},
+		Classroom:                "",
+		Instructor:               []string{"髙良 幸哉"},
+		CourseOverview:           "情報化社会における法制度や情報モラル向上に必要な基礎知識を習得することを目指すため、現行の我が国の法制度の基礎を学び、ネットワーク社会における法整備の現状について講義する。",
+		Remarks:                  "オンライン(オンデマンド型)",
+		CreditedAuditors:         0,
+		ApplicationConditions:    "正規生に対しても受講制限をしているため",
+		AltCourseName:            "Information Society Law",
+		CourseCode:               "GA10101",
+		CourseCodeName:           "情報社会と法制度",
+		Year:                     2021,
+	}
+	const csvHeader = "科目番号,科目名,授業方法,単位数,標準履修年次,実施学期,曜時限,教室,担当教員,授業概要,備考,科目等履修生申請可否,申請条件,英語(日本語)科目名,科目コード,要件科目名,データ更新日\n"
+
+	tests := []struct {
+		name                   string
+		fakeStream             func(context.Context, domain.CourseQuery, func(*domain.Course) error) error
+		fakeYears              func() ([]*domain.AcademicYear, error)
+		reqContentTypeHeader   string
+		reqAcceptHeader        string
+		reqBody                string
+		wantResStatusCode      int
+		wantContentType        string
+		wantContentDisposition string
+		wantResBody            string
+	}{
+		{
+			name: "temp",
+			fakeStream: func(ctx context.Context, cq domain.CourseQuery, fn func(*domain.Course) error) error {
+				return fn(course)
+			},
+			fakeYears: func() ([]*domain.AcademicYear, error) {
+				return []*domain.AcademicYear{
+					{Year: 2021, CourseCount: 1},
+					{Year: 2020, CourseCount: 1},
+				}, nil
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody: `{
 		    "course_number": "GA10101",
 		    "course_name": "情報社会と法制度",
 		    "instructional_type": -1,
@@ -267,9 +786,244 @@ func Test_courseHandler_Facet(t *testing.T) {
 		    "filter_type": "and",
 		    "limit": 20,
 		    "offset": 0
-		}`
+		}`,
+			wantResStatusCode:      http.StatusOK,
+			wantContentType:        "text/csv; charset=UTF-8",
+			wantContentDisposition: `attachment; filename="courses_2021_20210401093000.csv"`,
+			wantResBody: csvHeader + `GA10101,情報社会と法制度,1,2.0,2,"秋A,秋B","月5,月6",,髙良 幸哉,情報化社会における法制度や情報モラル向上に必要な基礎知識を習得することを目指すため、現行の我が国の法制度の基礎を学び、ネットワーク社会における法整備の現状について講義する。,オンライン(オンデマンド型),0,正規生に対しても受講制限をしているため,Information Society Law,GA10101,情報社会と法制度,0001-01-01T00:00:00Z
+`,
+		},
+		{
+			name: "limit より多くの科目も書き込む",
+			fakeStream: func(ctx context.Context, cq domain.CourseQuery, fn func(*domain.Course) error) error {
+				for i := 0; i < 3; i++ {
+					err := fn(&domain.Course{CourseNumber: fmt.Sprintf("GA1010%d", i)})
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody: `{
+		    "filter_type": "and",
+		    "year": [2020, 2021],
+		    "limit": 1,
+		    "offset": 0
+		}`,
+			wantResStatusCode:      http.StatusOK,
+			wantContentType:        "text/csv; charset=UTF-8",
+			wantContentDisposition: `attachment; filename="courses_2020-2021_20210401093000.csv"`,
+			wantResBody: csvHeader + `GA10100,,0,,,,,,,,,0,,,,,0001-01-01T00:00:00Z
+GA10101,,0,,,,,,,,,0,,,,,0001-01-01T00:00:00Z
+GA10102,,0,,,,,,,,,0,,,,,0001-01-01T00:00:00Z
+`,
+		},
+		{
+			name: "該当する科目が無ければヘッダーのみ",
+			fakeStream: func(ctx context.Context, cq domain.CourseQuery, fn func(*domain.Course) error) error {
+				return nil
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody: `{
+		    "filter_type": "and",
+		    "year": 2020,
+		    "limit": 20,
+		    "offset": 0
+		}`,
+			wantResStatusCode:      http.StatusOK,
+			wantContentType:        "text/csv; charset=UTF-8",
+			wantContentDisposition: `attachment; filename="courses_2020_20210401093000.csv"`,
+			wantResBody:            csvHeader,
+		},
+		{
+			name: "書き込み始める前のエラーはステータスコードで返す",
+			fakeStream: func(ctx context.Context, cq domain.CourseQuery, fn func(*domain.Course) error) error {
+				return errors.New("invalid cursor")
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody: `{
+		    "filter_type": "and",
+		    "year": 2020,
+		    "limit": 20,
+		    "offset": 0
+		}`,
+			wantResStatusCode:      http.StatusBadRequest,
+			wantContentType:        "",
+			wantContentDisposition: "",
+			wantResBody:            "",
+		},
+		{
+			name: "書き込み始めた後のエラーはそこで打ち切る",
+			fakeStream: func(ctx context.Context, cq domain.CourseQuery, fn func(*domain.Course) error) error {
+				err := fn(&domain.Course{CourseNumber: "GA10101"})
+				if err != nil {
+					return err
+				}
+				return errors.New("connection reset")
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody: `{
+		    "filter_type": "and",
+		    "year": 2020,
+		    "limit": 20,
+		    "offset": 0
+		}`,
+			wantResStatusCode:      http.StatusOK,
+			wantContentType:        "text/csv; charset=UTF-8",
+			wantContentDisposition: `attachment; filename="courses_2020_20210401093000.csv"`,
+			wantResBody: csvHeader + `GA10101,,0,,,,,,,,,0,,,,,0001-01-01T00:00:00Z
+`,
+		},
+		{
+			name: "Accept ヘッダーで TSV、改行は CRLF を指定する",
+			fakeStream: func(ctx context.Context, cq domain.CourseQuery, fn func(*domain.Course) error) error {
+				return fn(&domain.Course{CourseNumber: "GA10101", Period: []string{"月5", "月6"}})
+			},
+			reqContentTypeHeader: "application/json",
+			reqAcceptHeader:      "text/tab-separated-values; line_ending=crlf",
+			reqBody: `{
+		    "filter_type": "and",
+		    "year": 2020,
+		    "limit": 20,
+		    "offset": 0
+		}`,
+			wantResStatusCode:      http.StatusOK,
+			wantContentType:        "text/tab-separated-values; charset=UTF-8",
+			wantContentDisposition: `attachment; filename="courses_2020_20210401093000.tsv"`,
+			wantResBody:            strings.NewReplacer(",", "\t", "\n", "\r\n").Replace(csvHeader) + "GA10101\t\t0\t\t\t\t月5,月6\t\t\t\t\t0\t\t\t\t\t0001-01-01T00:00:00Z\r\n",
+		},
+		{
+			name: "リクエストの本文で BOM 付きの UTF-8 を指定する",
+			fakeStream: func(ctx context.Context, cq domain.CourseQuery, fn func(*domain.Course) error) error {
+				return nil
+			},
+			reqContentTypeHeader: "application/json",
+			reqAcceptHeader:      "text/csv; charset=shift_jis",
+			reqBody: `{
+		    "filter_type": "and",
+		    "year": 2020,
+		    "encoding": "utf-8-bom",
+		    "limit": 20,
+		    "offset": 0
+		}`,
+			wantResStatusCode:      http.StatusOK,
+			wantContentType:        "text/csv; charset=UTF-8",
+			wantContentDisposition: `attachment; filename="courses_2020_20210401093000.csv"`,
+			wantResBody:            "\xEF\xBB\xBF" + csvHeader,
+		},
+		{
+			name: "列と英語の見出しを指定する",
+			fakeStream: func(ctx context.Context, cq domain.CourseQuery, fn func(*domain.Course) error) error {
+				return fn(&domain.Course{ID: 1, CourseNumber: "GA10101", Term: []int{kdb.TermFallACode, kdb.TermFallBCode}, Year: 2020})
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody: `{
+		    "filter_type": "and",
+		    "year": 2020,
+		    "columns": ["id", "course_number", "term", "year"],
+		    "header": "en",
+		    "limit": 20,
+		    "offset": 0
+		}`,
+			wantResStatusCode:      http.StatusOK,
+			wantContentType:        "text/csv; charset=UTF-8",
+			wantContentDisposition: `attachment; filename="courses_2020_20210401093000.csv"`,
+			wantResBody: `id,course_number,term,year
+1,GA10101,"秋A,秋B",2020
+`,
+		},
+		{
+			name: "存在しない列",
+			fakeStream: func(ctx context.Context, cq domain.CourseQuery, fn func(*domain.Course) error) error {
+				t.Fatal("Stream must not be called")
+				return nil
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody: `{
+		    "filter_type": "and",
+		    "columns": ["id", "syllabus"],
+		    "limit": 20,
+		    "offset": 0
+		}`,
+			wantResStatusCode:      http.StatusBadRequest,
+			wantContentType:        "",
+			wantContentDisposition: "",
+			wantResBody:            "",
+		},
+		{
+			name: "出力形式が不正",
+			fakeStream: func(ctx context.Context, cq domain.CourseQuery, fn func(*domain.Course) error) error {
+				t.Fatal("Stream must not be called")
+				return nil
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody: `{
+		    "filter_type": "and",
+		    "encoding": "euc-jp",
+		    "limit": 20,
+		    "offset": 0
+		}`,
+			wantResStatusCode:      http.StatusBadRequest,
+			wantContentType:        "",
+			wantContentDisposition: "",
+			wantResBody:            "",
+		},
+	}
 
-		req, err := http.NewRequest(http.MethodPost, "/facet", bytes.NewBufferString(reqBody))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/csv", bytes.NewBufferString(tt.reqBody))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", tt.reqContentTypeHeader)
+			if tt.reqAcceptHeader != "" {
+				req.Header.Set("Accept", tt.reqAcceptHeader)
+			}
+
+			res := httptest.NewRecorder()
+
+			h := &courseHandler{
+				uc: &courseUseCaseMock{
+					FakeStream: tt.fakeStream,
+					FakeYears:  tt.fakeYears,
+				},
+			}
+
+			h.Csv(res, req)
+
+			resBodyGot := res.Body.String()
+			if resBodyGot != tt.wantResBody {
+				t.Errorf("response mismatch:\ngot: %s\nwant: %s", resBodyGot, tt.wantResBody)
+			}
+
+			statusCodeGot := res.Code
+			if statusCodeGot != tt.wantResStatusCode {
+				t.Errorf("response status code mismatch:\ngot: %d\nwant: %d", statusCodeGot, tt.wantResStatusCode)
+			}
+
+			contentTypeGot := res.Header().Get("Content-Type")
+			if contentTypeGot != tt.wantContentType {
+				t.Errorf("Content-Type mismatch:\ngot: %s\nwant: %s", contentTypeGot, tt.wantContentType)
+			}
+
+			contentDispositionGot := res.Header().Get("Content-Disposition")
+			if contentDispositionGot != tt.wantContentDisposition {
+				t.Errorf("Content-Disposition mismatch:\ngot: %s\nwant: %s", contentDispositionGot, tt.wantContentDisposition)
+			}
+		})
+	}
+
+	t.Run("クライアントが切断したら取り出しを止める", func(t *testing.T) {
+		reqBody := `{
+		    "filter_type": "and",
+		    "year": 2020,
+		    "limit": 20,
+		    "offset": 0
+		}`
+		ctx, cancel := context.WithCancel(context.Background())
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/csv", bytes.NewBufferString(reqBody))
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -277,217 +1031,981 @@ func Test_courseHandler_Facet(t *testing.T) {
 
 		res := httptest.NewRecorder()
 
+		fetched := 0
 		h := &courseHandler{
 			uc: &courseUseCaseMock{
-				FakeFacet: func(cq domain.CourseQuery) ([]*domain.Facet, error) {
-					courses := []*domain.Facet{
+				FakeStream: func(ctx context.Context, cq domain.CourseQuery, fn func(*domain.Course) error) error {
+					for i := 0; i < 1000; i++ {
+						fetched++
+						if i == csvFlushRows {
+							cancel()
+						}
+						err := fn(&domain.Course{CourseNumber: "GA10101"})
+						if err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+			},
+		}
+
+		h.Csv(res, req)
+
+		if fetched != csvFlushRows+1 {
+			t.Errorf("fetched = %d, want %d", fetched, csvFlushRows+1)
+		}
+		if !res.Flushed {
+			t.Errorf("response must be flushed while streaming")
+		}
+	})
+
+	t.Run("BOM を書き込めなくても panic しない", func(t *testing.T) {
+		reqBody := `{
+		    "filter_type": "and",
+		    "year": 2020,
+		    "encoding": "utf-8-bom",
+		    "limit": 20
+		}`
+		req, err := http.NewRequest(http.MethodPost, "/csv", bytes.NewBufferString(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		res := &failingResponseWriter{ResponseRecorder: httptest.NewRecorder()}
+
+		h := &courseHandler{
+			uc: &courseUseCaseMock{
+				FakeStream: func(ctx context.Context, cq domain.CourseQuery, fn func(*domain.Course) error) error {
+					return fn(course)
+				},
+			},
+		}
+
+		h.Csv(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Errorf("status code = %d, want %d", res.Code, http.StatusOK)
+		}
+	})
+}
+
+// 本文の書き込みが常に失敗する ResponseWriter
+type failingResponseWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w *failingResponseWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func Test_courseHandler_Facet(t *testing.T) {
+	t.Run("temp", func(t *testing.T) {
+		want := `{"term_facet":{"1":111,"2":222},"period_facet":{` +
+			`"土1":1,"応談":0,"月1":0,"月2":0,"月3":0,"月4":0,"月5":3,"月6":3,"木1":0,"木2":0,"木3":0,"木4":0,"木5":0,"木6":0,` +
+			`"水1":0,"水2":0,"水3":0,"水4":0,"水5":0,"水6":0,"火1":0,"火2":0,"火3":0,"火4":0,"火5":0,"火6":0,` +
+			`"金1":0,"金2":0,"金3":0,"金4":0,"金5":0,"金6":0,"随時":0,"集中":2}}`
+		reqBody := `{
+		    "course_number": "GA10101",
+		    "course_name": "情報社会と法制度",
+		    "instructional_type": -1,
+		    "credits": "",
+		    "standard_registration_year": -1,
+		    "term": "",
+		    "period": "",
+		    "classroom": "",
+		    "instructor": "",
+		    "course_overview": "",
+		    "remarks": "",
+		    "course_name_filter_type": "and",
+		    "course_overview_filter_type": "and",
+		    "filter_type": "and",
+		    "limit": 20,
+		    "offset": 0
+		}`
+
+		req, err := http.NewRequest(http.MethodPost, "/facet", bytes.NewBufferString(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		res := httptest.NewRecorder()
+
+		h := &courseHandler{
+			uc: &courseUseCaseMock{
+				FakeFacet: func(cq domain.CourseQuery) ([]*domain.Facet, error) {
+					courses := []*domain.Facet{
+						{
+							Term:      1,
+							TermCount: 111,
+						},
+						{
+							Term:      2,
+							TermCount: 222,
+						},
+					}
+					return courses, nil
+				},
+				FakePeriodFacet: func(cq domain.CourseQuery) ([]*domain.PeriodFacet, error) {
+					return []*domain.PeriodFacet{
+						{Period: "月5", Count: 3},
+						{Period: "月6", Count: 3},
+						{Period: "集中", Count: 2},
+						{Period: "土1", Count: 1},
+					}, nil
+				},
+			},
+		}
+		h.Facet(res, req)
+
+		got := res.Body.String()
+		if got != want {
+			t.Errorf("response mismatch:\ngot:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("facets で要求された集計を term_facet, period_facet に加えて返す", func(t *testing.T) {
+		want := `{"term_facet":{"4":2},"period_facet":{` +
+			`"応談":0,"月1":2,"月2":0,"月3":0,"月4":0,"月5":0,"月6":0,"木1":0,"木2":0,"木3":0,"木4":0,"木5":0,"木6":0,` +
+			`"水1":0,"水2":0,"水3":0,"水4":0,"水5":0,"水6":0,"火1":0,"火2":0,"火3":0,"火4":0,"火5":0,"火6":0,` +
+			`"金1":0,"金2":0,"金3":0,"金4":0,"金5":0,"金6":0,"随時":0,"集中":0},` +
+			`"facets":{"credits":[{"value":"2.0","count":5},{"value":"1.0","count":2}],"instructor":[{"value":"天笠 俊之","count":2}]}}`
+		reqBody := `{
+		    "credits": "2.0",
+		    "filter_type": "and",
+		    "facets": ["credits", "instructor"],
+		    "limit": 20
+		}`
+
+		req, err := http.NewRequest(http.MethodPost, "/facet", bytes.NewBufferString(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		res := httptest.NewRecorder()
+
+		h := &courseHandler{
+			uc: &courseUseCaseMock{
+				FakeFacet: func(cq domain.CourseQuery) ([]*domain.Facet, error) {
+					return []*domain.Facet{{Term: 4, TermCount: 2}}, nil
+				},
+				FakePeriodFacet: func(cq domain.CourseQuery) ([]*domain.PeriodFacet, error) {
+					return []*domain.PeriodFacet{{Period: "月1", Count: 2}}, nil
+				},
+				FakeFacetValues: func(cq domain.CourseQuery) (map[string][]*domain.FacetValue, error) {
+					if !reflect.DeepEqual(cq.Facets, []string{domain.FacetKindCredits, domain.FacetKindInstructor}) {
+						t.Errorf("facets mismatch: %v", cq.Facets)
+					}
+					return map[string][]*domain.FacetValue{
+						domain.FacetKindCredits: {
+							{Value: "2.0", Count: 5},
+							{Value: "1.0", Count: 2},
+						},
+						domain.FacetKindInstructor: {
+							{Value: "天笠 俊之", Count: 2},
+						},
+					}, nil
+				},
+			},
+		}
+		h.Facet(res, req)
+
+		got := res.Body.String()
+		if got != want {
+			t.Errorf("response mismatch:\ngot:\n%s\nwant:\n%s", got, want)
+		}
+	})
+}
+
+func Test_courseHandler_Years(t *testing.T) {
+	tests := []struct {
+		name              string
+		fakeYears         func() ([]*domain.AcademicYear, error)
+		wantResStatusCode int
+		wantResBody       string
+	}{
+		{
+			name: "normal",
+			fakeYears: func() ([]*domain.AcademicYear, error) {
+				years := []*domain.AcademicYear{
+					{
+						Year:        2022,
+						CourseCount: 12345,
+					},
+					{
+						Year:        2021,
+						CourseCount: 20,
+					},
+				}
+				return years, nil
+			},
+			wantResStatusCode: http.StatusOK,
+			wantResBody:       `[{"year":2022,"course_count":12345},{"year":2021,"course_count":20}]`,
+		},
+		{
+			name: "年度が 1 つも登録されていないときは空配列を返す",
+			fakeYears: func() ([]*domain.AcademicYear, error) {
+				return []*domain.AcademicYear{}, nil
+			},
+			wantResStatusCode: http.StatusOK,
+			wantResBody:       `[]`,
+		},
+		{
+			name: "取得に失敗",
+			fakeYears: func() ([]*domain.AcademicYear, error) {
+				return nil, errors.New("fake error")
+			},
+			wantResStatusCode: http.StatusInternalServerError,
+			wantResBody:       ``,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/years", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res := httptest.NewRecorder()
+
+			h := &courseHandler{
+				uc: &courseUseCaseMock{
+					FakeYears: tt.fakeYears,
+				},
+			}
+
+			h.Years(res, req)
+
+			resBodyGot := res.Body.String()
+			if resBodyGot != tt.wantResBody {
+				t.Errorf("response mismatch:\ngot: %s\nwant: %s", resBodyGot, tt.wantResBody)
+			}
+
+			statusCodeGot := res.Code
+			if statusCodeGot != tt.wantResStatusCode {
+				t.Errorf("response status code mismatch:\ngot: %d\nwant: %d", statusCodeGot, tt.wantResStatusCode)
+			}
+		})
+	}
+}
+
+func Test_validateSearchCourseQuery(t *testing.T) {
+	type args struct {
+		query domain.CourseQuery
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "exist all parameter",
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:               "情報",
+					CourseNameFilterType:     "and",
+					CourseOverview:           "科学",
+					CourseOverviewFilterType: "and",
+					FilterType:               "and",
+					Limit:                    100,
+					Offset:                   50,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "一部パラメーターのみ存在する(つまり (全て存在する または 全て存在しない)ではない)",
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:               "",
+					CourseNameFilterType:     "and",
+					CourseOverview:           "科学",
+					CourseOverviewFilterType: "and",
+					FilterType:               "and",
+					Limit:                    100,
+					Offset:                   50,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "一部パラメーターのみ存在する(つまり (全て存在する または 全て存在しない)ではない)",
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:               "情報",
+					CourseNameFilterType:     "and",
+					CourseOverview:           "",
+					CourseOverviewFilterType: "and",
+					FilterType:               "and",
+					Limit:                    100,
+					Offset:                   50,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "パラメーターが存在していないもののフィルタータイプが異常(エラーは発生しない)",
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:               "",
+					CourseNameFilterType:     "fake",
+					CourseOverview:           "科学",
+					CourseOverviewFilterType: "and",
+					FilterType:               "and",
+					Limit:                    100,
+					Offset:                   50,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "パラメーターが存在していないもののフィルタータイプが異常(エラーは発生しない)",
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:               "情報",
+					CourseNameFilterType:     "and",
+					CourseOverview:           "",
+					CourseOverviewFilterType: "fake",
+					FilterType:               "and",
+					Limit:                    100,
+					Offset:                   50,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "all str is empty",
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:               "",
+					CourseNameFilterType:     "and",
+					CourseOverview:           "",
+					CourseOverviewFilterType: "and",
+					FilterType:               "and",
+					Limit:                    100,
+					Offset:                   50,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "cause FilterType error",
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:               "情報",
+					CourseNameFilterType:     "and",
+					CourseOverview:           "科学",
+					CourseOverviewFilterType: "and",
+					FilterType:               "andor",
+					Limit:                    100,
+					Offset:                   50,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cause FilterType is empty error",
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:               "情報",
+					CourseNameFilterType:     "and",
+					CourseOverview:           "科学",
+					CourseOverviewFilterType: "and",
+					FilterType:               "",
+					Limit:                    100,
+					Offset:                   50,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cause CourseNameFilterType error",
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:               "情報",
+					CourseNameFilterType:     "andor",
+					CourseOverview:           "科学",
+					CourseOverviewFilterType: "and",
+					FilterType:               "and",
+					Limit:                    100,
+					Offset:                   50,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cause CourseNameFilterType is empty error",
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:               "情報",
+					CourseNameFilterType:     "",
+					CourseOverview:           "科学",
+					CourseOverviewFilterType: "and",
+					FilterType:               "and",
+					Limit:                    100,
+					Offset:                   50,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cause CourseOverviewFilterType error",
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:               "情報",
+					CourseNameFilterType:     "and",
+					CourseOverview:           "科学",
+					CourseOverviewFilterType: "andor",
+					FilterType:               "and",
+					Limit:                    100,
+					Offset:                   50,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cause CourseOverviewFilterType is empty error",
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:               "情報",
+					CourseNameFilterType:     "and",
+					CourseOverview:           "科学",
+					CourseOverviewFilterType: "",
+					FilterType:               "and",
+					Limit:                    100,
+					Offset:                   50,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cause InstructorFilterType error",
+			args: args{
+				query: domain.CourseQuery{
+					Instructor:           "天笠",
+					InstructorFilterType: "andor",
+					FilterType:           "and",
+					Limit:                100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Instructor が空なら InstructorFilterType は不要",
+			args: args{
+				query: domain.CourseQuery{
+					Instructor: "",
+					FilterType: "and",
+					Limit:      100,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "複数の授業方法・単位数の範囲・標準履修年次を指定",
+			args: args{
+				query: domain.CourseQuery{
+					InstructionalType:        domain.MultiValue{"1", "4"},
+					Credits:                  "1.0-2.0",
+					StandardRegistrationYear: domain.MultiValue{"?", "2"},
+					FilterType:               "and",
+					Limit:                    100,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "cause InstructionalType range error",
+			args: args{
+				query: domain.CourseQuery{
+					InstructionalType: domain.MultiValue{"1", "9"},
+					FilterType:        "and",
+					Limit:             100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cause InstructionalType is not a number error",
+			args: args{
+				query: domain.CourseQuery{
+					InstructionalType: domain.MultiValue{"講義"},
+					FilterType:        "and",
+					Limit:             100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cause Credits parse error",
+			args: args{
+				query: domain.CourseQuery{
+					Credits:    "2.0-1.0",
+					FilterType: "and",
+					Limit:      100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cause StandardRegistrationYear range error",
+			args: args{
+				query: domain.CourseQuery{
+					StandardRegistrationYear: domain.MultiValue{"7"},
+					FilterType:               "and",
+					Limit:                    100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cause ClassroomFilterType is empty error",
+			args: args{
+				query: domain.CourseQuery{
+					Classroom:  "3A",
+					FilterType: "and",
+					Limit:      100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cause RemarksFilterType error",
+			args: args{
+				query: domain.CourseQuery{
+					Remarks:           "対面",
+					RemarksFilterType: "andor",
+					FilterType:        "and",
+					Limit:             100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "CourseNumberFilterType は省略できる",
+			args: args{
+				query: domain.CourseQuery{
+					CourseNumber: "GA101 GB115",
+					FilterType:   "and",
+					Limit:        100,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "cause CourseNumberFilterType error",
+			args: args{
+				query: domain.CourseQuery{
+					CourseNumber:           "GA101 GB115",
+					CourseNumberFilterType: "andor",
+					FilterType:             "and",
+					Limit:                  100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "年度を複数指定",
+			args: args{
+				query: domain.CourseQuery{
+					FilterType: "and",
+					Year:       domain.MultiValue{"2020", domain.YearLatest},
+					Limit:      100,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "cause Year error",
+			args: args{
+				query: domain.CourseQuery{
+					FilterType: "and",
+					Year:       domain.MultiValue{"newest"},
+					Limit:      100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "並び順を 2 つ指定",
+			args: args{
+				query: domain.CourseQuery{
+					FilterType: "and",
+					Sort: []domain.CourseSort{
 						{
-							Term:      1,
-							TermCount: 111,
+							Key:   domain.SortKeyCredits,
+							Order: domain.SortOrderDesc,
 						},
 						{
-							Term:      2,
-							TermCount: 222,
+							Key: domain.SortKeyCourseName,
 						},
-					}
-					return courses, nil
+					},
+					Limit: 100,
 				},
 			},
-		}
-		h.Facet(res, req)
-
-		got := res.Body.String()
-		if got != want {
-			t.Errorf("response mismatch:\ngot:\n%s\nwant:\n%s", got, want)
-		}
-	})
-}
-
-func Test_validateSearchCourseQuery(t *testing.T) {
-	type args struct {
-		query domain.CourseQuery
-	}
-	tests := []struct {
-		name    string
-		args    args
-		wantErr bool
-	}{
+			wantErr: false,
+		},
 		{
-			name: "exist all parameter",
+			name: "cause Sort key error",
 			args: args{
 				query: domain.CourseQuery{
-					CourseName:               "情報",
-					CourseNameFilterType:     "and",
-					CourseOverview:           "科学",
-					CourseOverviewFilterType: "and",
-					FilterType:               "and",
-					Limit:                    100,
-					Offset:                   50,
+					FilterType: "and",
+					Sort: []domain.CourseSort{
+						{
+							Key: "id; drop table courses",
+						},
+					},
+					Limit: 100,
 				},
 			},
-			wantErr: false,
+			wantErr: true,
 		},
 		{
-			name: "一部パラメーターのみ存在する(つまり (全て存在する または 全て存在しない)ではない)",
+			name: "cause Sort order error",
 			args: args{
 				query: domain.CourseQuery{
-					CourseName:               "",
-					CourseNameFilterType:     "and",
-					CourseOverview:           "科学",
-					CourseOverviewFilterType: "and",
-					FilterType:               "and",
-					Limit:                    100,
-					Offset:                   50,
+					FilterType: "and",
+					Sort: []domain.CourseSort{
+						{
+							Key:   domain.SortKeyCredits,
+							Order: "up",
+						},
+					},
+					Limit: 100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cause Sort key is duplicated error",
+			args: args{
+				query: domain.CourseQuery{
+					FilterType: "and",
+					Sort: []domain.CourseSort{
+						{
+							Key: domain.SortKeyCredits,
+						},
+						{
+							Key:   domain.SortKeyCredits,
+							Order: domain.SortOrderDesc,
+						},
+					},
+					Limit: 100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cause too many Sort keys error",
+			args: args{
+				query: domain.CourseQuery{
+					FilterType: "and",
+					Sort: []domain.CourseSort{
+						{
+							Key: domain.SortKeyCredits,
+						},
+						{
+							Key: domain.SortKeyCourseName,
+						},
+						{
+							Key: domain.SortKeyCourseNumber,
+						},
+					},
+					Limit: 100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "キーワードが無いのに関連度で並べようとするとエラー",
+			args: args{
+				query: domain.CourseQuery{
+					FilterType: "and",
+					Sort: []domain.CourseSort{
+						{
+							Key: domain.SortKeyRelevance,
+						},
+					},
+					Limit: 100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "キーワードがあれば関連度で並べられる",
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:           "線形代数",
+					CourseNameFilterType: "and",
+					FilterType:           "and",
+					Sort: []domain.CourseSort{
+						{
+							Key: domain.SortKeyRelevance,
+						},
+					},
+					Limit: 100,
 				},
 			},
 			wantErr: false,
 		},
 		{
-			name: "一部パラメーターのみ存在する(つまり (全て存在する または 全て存在しない)ではない)",
+			name: "全文検索",
 			args: args{
 				query: domain.CourseQuery{
-					CourseName:               "情報",
-					CourseNameFilterType:     "and",
-					CourseOverview:           "",
-					CourseOverviewFilterType: "and",
-					FilterType:               "and",
-					Limit:                    100,
-					Offset:                   50,
+					Keyword:    "線形代数 A",
+					SearchMode: domain.SearchModeFulltext,
+					FilterType: "and",
+					Limit:      100,
 				},
 			},
 			wantErr: false,
 		},
 		{
-			name: "パラメーターが存在していないもののフィルタータイプが異常(エラーは発生しない)",
+			name: "cause SearchMode error",
 			args: args{
 				query: domain.CourseQuery{
-					CourseName:               "",
-					CourseNameFilterType:     "fake",
-					CourseOverview:           "科学",
-					CourseOverviewFilterType: "and",
-					FilterType:               "and",
-					Limit:                    100,
-					Offset:                   50,
+					Keyword:    "線形代数",
+					SearchMode: "regexp",
+					FilterType: "and",
+					Limit:      100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "全文検索で 1 文字のキーワードしか無いとエラー",
+			args: args{
+				query: domain.CourseQuery{
+					Keyword:    "情 A",
+					SearchMode: domain.SearchModeFulltext,
+					FilterType: "and",
+					Limit:      100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "全文検索で半角カナのキーワードは正規化した後の文字数で数える",
+			args: args{
+				query: domain.CourseQuery{
+					Keyword:    "ﾌﾟ",
+					SearchMode: domain.SearchModeFulltext,
+					FilterType: "and",
+					Limit:      100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "全文検索でキーワードが無いのに関連度で並べようとするとエラー",
+			args: args{
+				query: domain.CourseQuery{
+					CourseName:           "線形代数",
+					CourseNameFilterType: "and",
+					SearchMode:           domain.SearchModeFulltext,
+					FilterType:           "and",
+					Sort: []domain.CourseSort{
+						{
+							Key: domain.SortKeyRelevance,
+						},
+					},
+					Limit: 100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "検索式",
+			args: args{
+				query: domain.CourseQuery{
+					Q:          `"線形代数" -演習 instructor:山田 (period:月1 OR term:春A) credits:1-2 grade:1,2`,
+					FilterType: "and",
+					Limit:      100,
 				},
 			},
 			wantErr: false,
 		},
 		{
-			name: "パラメーターが存在していないもののフィルタータイプが異常(エラーは発生しない)",
+			name: "cause Q parse error",
 			args: args{
 				query: domain.CourseQuery{
-					CourseName:               "情報",
-					CourseNameFilterType:     "and",
-					CourseOverview:           "",
-					CourseOverviewFilterType: "fake",
-					FilterType:               "and",
-					Limit:                    100,
-					Offset:                   50,
+					Q:          "線形代数 OR",
+					FilterType: "and",
+					Limit:      100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cause Q period error",
+			args: args{
+				query: domain.CourseQuery{
+					Q:          "period:月9",
+					FilterType: "and",
+					Limit:      100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cause Q term error",
+			args: args{
+				query: domain.CourseQuery{
+					Q:          "-term:冬",
+					FilterType: "and",
+					Limit:      100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "検索条件の木",
+			args: args{
+				query: domain.CourseQuery{
+					Filter: &domain.Filter{
+						And: []*domain.Filter{
+							{Field: domain.FilterFieldCourseName, Value: domain.MultiValue{"情報"}},
+							{Not: &domain.Filter{Field: domain.FilterFieldPeriod, Value: domain.MultiValue{"月1"}}},
+						},
+					},
+					FilterType: "and",
+					Limit:      100,
 				},
 			},
 			wantErr: false,
 		},
 		{
-			name: "all str is empty",
+			name: "cause Filter leaf error",
 			args: args{
 				query: domain.CourseQuery{
-					CourseName:               "",
-					CourseNameFilterType:     "and",
-					CourseOverview:           "",
-					CourseOverviewFilterType: "and",
-					FilterType:               "and",
-					Limit:                    100,
-					Offset:                   50,
+					Filter: &domain.Filter{
+						Or: []*domain.Filter{
+							{Field: domain.FilterFieldCourseName, Value: domain.MultiValue{"情報"}},
+							{Field: domain.FilterFieldStandardRegistrationYear, Value: domain.MultiValue{"1", "7"}},
+						},
+					},
+					FilterType: "and",
+					Limit:      100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cause Filter depth error",
+			args: args{
+				query: domain.CourseQuery{
+					Filter: func() *domain.Filter {
+						filter := &domain.Filter{Field: domain.FilterFieldCourseName, Value: domain.MultiValue{"情報"}}
+						for i := 0; i < domain.FilterMaxDepth; i++ {
+							filter = &domain.Filter{Not: filter}
+						}
+						return filter
+					}(),
+					FilterType: "and",
+					Limit:      100,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "PeriodMatchMode, TermMatchMode",
+			args: args{
+				query: domain.CourseQuery{
+					Period:          "月3月4",
+					PeriodMatchMode: domain.MatchModeOverlaps,
+					Term:            "秋AB",
+					TermMatchMode:   domain.MatchModeExact,
+					FilterType:      "and",
+					Limit:           100,
 				},
 			},
 			wantErr: false,
 		},
 		{
-			name: "cause FilterType error",
+			name: "cause PeriodMatchMode error",
 			args: args{
 				query: domain.CourseQuery{
-					CourseName:               "情報",
-					CourseNameFilterType:     "and",
-					CourseOverview:           "科学",
-					CourseOverviewFilterType: "and",
-					FilterType:               "andor",
-					Limit:                    100,
-					Offset:                   50,
+					Period:          "月3月4",
+					PeriodMatchMode: "subset",
+					FilterType:      "and",
+					Limit:           100,
 				},
 			},
 			wantErr: true,
 		},
 		{
-			name: "cause FilterType is empty error",
+			name: "cause Filter MatchMode error",
 			args: args{
 				query: domain.CourseQuery{
-					CourseName:               "情報",
-					CourseNameFilterType:     "and",
-					CourseOverview:           "科学",
-					CourseOverviewFilterType: "and",
-					FilterType:               "",
-					Limit:                    100,
-					Offset:                   50,
+					Filter: &domain.Filter{
+						Field:     domain.FilterFieldCourseName,
+						Value:     domain.MultiValue{"情報"},
+						MatchMode: domain.MatchModeOverlaps,
+					},
+					FilterType: "and",
+					Limit:      100,
 				},
 			},
 			wantErr: true,
 		},
 		{
-			name: "cause CourseNameFilterType error",
+			name: "除外する条件",
 			args: args{
 				query: domain.CourseQuery{
-					CourseName:               "情報",
-					CourseNameFilterType:     "andor",
-					CourseOverview:           "科学",
-					CourseOverviewFilterType: "and",
+					ExcludePeriod:            "月1",
+					ExcludeTerm:              "通年",
+					ExcludeInstructionalType: domain.MultiValue{"2", "3"},
+					ExcludeRemarks:           "対面",
 					FilterType:               "and",
 					Limit:                    100,
-					Offset:                   50,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "cause ExcludePeriod error",
+			args: args{
+				query: domain.CourseQuery{
+					ExcludePeriod: "月9",
+					FilterType:    "and",
+					Limit:         100,
 				},
 			},
 			wantErr: true,
 		},
 		{
-			name: "cause CourseNameFilterType is empty error",
+			name: "cause ExcludeTerm error",
 			args: args{
 				query: domain.CourseQuery{
-					CourseName:               "情報",
-					CourseNameFilterType:     "",
-					CourseOverview:           "科学",
-					CourseOverviewFilterType: "and",
-					FilterType:               "and",
-					Limit:                    100,
-					Offset:                   50,
+					ExcludeTerm: "冬",
+					FilterType:  "and",
+					Limit:       100,
 				},
 			},
 			wantErr: true,
 		},
 		{
-			name: "cause CourseOverviewFilterType error",
+			name: "cause ExcludeInstructionalType error",
 			args: args{
 				query: domain.CourseQuery{
-					CourseName:               "情報",
-					CourseNameFilterType:     "and",
-					CourseOverview:           "科学",
-					CourseOverviewFilterType: "andor",
+					ExcludeInstructionalType: domain.MultiValue{"9"},
 					FilterType:               "and",
 					Limit:                    100,
-					Offset:                   50,
 				},
 			},
 			wantErr: true,
 		},
 		{
-			name: "cause CourseOverviewFilterType is empty error",
+			name: "cause Facets error",
 			args: args{
 				query: domain.CourseQuery{
-					CourseName:               "情報",
-					CourseNameFilterType:     "and",
-					CourseOverview:           "科学",
-					CourseOverviewFilterType: "",
-					FilterType:               "and",
-					Limit:                    100,
-					Offset:                   50,
+					Facets:     []string{domain.FacetKindCredits, "classroom"},
+					FilterType: "and",
+					Limit:      100,
 				},
 			},
 			wantErr: true,
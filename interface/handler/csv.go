@@ -0,0 +1,397 @@
+package handler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocarina/gocsv"
+	"github.com/sylms/azuki/domain"
+	"github.com/sylms/azuki/util"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+)
+
+// /csv のリクエスト
+// 検索条件に加えて出力形式を指定できる、省略したものは Accept ヘッダーの指定に従う
+type CsvQuery struct {
+	domain.CourseQuery
+	// CsvEncoding から始まる定数のいずれか
+	Encoding string `json:"encoding"`
+	// CsvFormat から始まる定数のいずれか
+	Format string `json:"format"`
+	// CsvLineEnding から始まる定数のいずれか
+	LineEnding string `json:"line_ending"`
+	// 出力する列とその順、CourseJSON のフィールド名
+	// 省略時は csvDefaultColumns
+	Columns []string `json:"columns"`
+	// 見出しの言語、CsvHeader から始まる定数のいずれか
+	// 省略時は CsvHeaderJapanese
+	Header string `json:"header"`
+}
+
+// 見出しの言語
+const (
+	CsvHeaderJapanese = "ja"
+	// CourseJSON のフィールド名を見出しにする
+	CsvHeaderEnglish = "en"
+)
+
+// CSV の列
+type csvColumn struct {
+	// CourseJSON のフィールド名、英語の見出しにもする
+	name string
+	// 日本語の見出し
+	header string
+	value  func(*domain.Course) (string, error)
+}
+
+// 出力できる列
+var csvColumns = []csvColumn{
+	{name: "id", header: "ID", value: func(c *domain.Course) (string, error) { return strconv.Itoa(c.ID), nil }},
+	{name: "course_number", header: "科目番号", value: func(c *domain.Course) (string, error) { return c.CourseNumber, nil }},
+	{name: "course_name", header: "科目名", value: func(c *domain.Course) (string, error) { return c.CourseName, nil }},
+	{name: "instructional_type", header: "授業方法", value: func(c *domain.Course) (string, error) { return strconv.Itoa(c.InstructionalType), nil }},
+	{name: "credits", header: "単位数", value: func(c *domain.Course) (string, error) { return c.Credits, nil }},
+	{name: "standard_registration_year", header: "標準履修年次", value: func(c *domain.Course) (string, error) { return strings.Join(c.StandardRegistrationYear, ","), nil }},
+	{name: "term", header: "実施学期", value: csvTerm},
+	{name: "period", header: "曜時限", value: func(c *domain.Course) (string, error) { return strings.Join(c.Period, ","), nil }},
+	{name: "classroom", header: "教室", value: func(c *domain.Course) (string, error) { return c.Classroom, nil }},
+	{name: "instructor", header: "担当教員", value: func(c *domain.Course) (string, error) { return strings.Join(c.Instructor, ","), nil }},
+	{name: "course_overview", header: "授業概要", value: func(c *domain.Course) (string, error) { return c.CourseOverview, nil }},
+	{name: "remarks", header: "備考", value: func(c *domain.Course) (string, error) { return c.Remarks, nil }},
+	{name: "credited_auditors", header: "科目等履修生申請可否", value: func(c *domain.Course) (string, error) { return strconv.Itoa(c.CreditedAuditors), nil }},
+	{name: "application_conditions", header: "申請条件", value: func(c *domain.Course) (string, error) { return c.ApplicationConditions, nil }},
+	{name: "alt_course_name", header: "英語(日本語)科目名", value: func(c *domain.Course) (string, error) { return c.AltCourseName, nil }},
+	{name: "course_code", header: "科目コード", value: func(c *domain.Course) (string, error) { return c.CourseCode, nil }},
+	{name: "course_code_name", header: "要件科目名", value: func(c *domain.Course) (string, error) { return c.CourseCodeName, nil }},
+	{name: "csv_updated_at", header: "KdB更新日", value: func(c *domain.Course) (string, error) { return csvTime(c.CSVUpdatedAt), nil }},
+	{name: "year", header: "年度", value: func(c *domain.Course) (string, error) { return strconv.Itoa(c.Year), nil }},
+	{name: "created_at", header: "データ作成日", value: func(c *domain.Course) (string, error) { return csvTime(c.CreatedAt), nil }},
+	{name: "updated_at", header: "データ更新日", value: func(c *domain.Course) (string, error) { return csvTime(c.UpdatedAt), nil }},
+}
+
+// 列を指定しなかったときの列、以前から出力しているもの
+var csvDefaultColumns = []string{
+	"course_number",
+	"course_name",
+	"instructional_type",
+	"credits",
+	"standard_registration_year",
+	"term",
+	"period",
+	"classroom",
+	"instructor",
+	"course_overview",
+	"remarks",
+	"credited_auditors",
+	"application_conditions",
+	"alt_course_name",
+	"course_code",
+	"course_code_name",
+	"updated_at",
+}
+
+// 開講時期は曜時限などと同じくカンマ区切りにする
+func csvTerm(c *domain.Course) (string, error) {
+	terms := []string{}
+	for _, termIndex := range c.Term {
+		term, err := decodeTerm(termIndex)
+		if err != nil {
+			return "", err
+		}
+		terms = append(terms, term)
+	}
+	return strings.Join(terms, ","), nil
+}
+
+// JSON と同じ形式
+func csvTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+func findCsvColumn(name string) (csvColumn, bool) {
+	for _, column := range csvColumns {
+		if column.name == name {
+			return column, true
+		}
+	}
+	return csvColumn{}, false
+}
+
+// 文字コード
+const (
+	CsvEncodingUTF8 = "utf-8"
+	// 先頭に BOM を付ける、Windows の Excel で文字化けしないようにするため
+	CsvEncodingUTF8BOM = "utf-8-bom"
+	// Windows の Shift_JIS (CP932)
+	// JIS と CP932 で対応する Unicode の文字が異なるもの (〜 など) は shiftJISCompatible で CP932 のものにしてから変換し、
+	// それでも変換できない文字 (絵文字、JIS 第 3・第 4 水準の漢字など) は csvEncodingFallback に置き換える
+	CsvEncodingShiftJIS = "shift_jis"
+)
+
+// Shift_JIS に変換できない文字の代わりに出力する文字
+const csvEncodingFallback = '?'
+
+// JIS X 0208 の対応表に従った文字から、同じ符号に対応する CP932 の文字への置き換え
+// Mac などで入力された文章に含まれていることがあるため
+var shiftJISCompatible = map[rune]rune{
+	'\u301C': '\uFF5E', // 〜 WAVE DASH
+	'\u2016': '\u2225', // ‖ DOUBLE VERTICAL LINE
+	'\u2212': '\uFF0D', // − MINUS SIGN
+	'\u2014': '\u2015', // — EM DASH
+	'\u00A2': '\uFFE0', // ¢
+	'\u00A3': '\uFFE1', // £
+	'\u00AC': '\uFFE2', // ¬
+	'\u00A6': '\uFFE4', // ¦
+}
+
+// Accept ヘッダーの charset で CsvEncodingShiftJIS として受け付ける名前
+var csvShiftJISAliases = []string{CsvEncodingShiftJIS, "shift-jis", "sjis", "cp932", "windows-31j"}
+
+// 区切り文字
+const (
+	CsvFormatCSV = "csv"
+	CsvFormatTSV = "tsv"
+)
+
+// 改行コード
+const (
+	CsvLineEndingLF   = "lf"
+	CsvLineEndingCRLF = "crlf"
+)
+
+const (
+	csvMediaType = "text/csv"
+	tsvMediaType = "text/tab-separated-values"
+)
+
+// 出力形式
+type csvDialect struct {
+	encoding   string
+	format     string
+	lineEnding string
+	columns    []csvColumn
+	header     string
+}
+
+// リクエストの本文、Accept ヘッダー、既定値 (BOM なしの UTF-8 の CSV、改行は LF) の順に出力形式を決める
+func newCsvDialect(query CsvQuery, accept string) (csvDialect, error) {
+	dialect, err := parseCsvAccept(accept)
+	if err != nil {
+		return csvDialect{}, err
+	}
+	if query.Encoding != "" {
+		dialect.encoding = query.Encoding
+	}
+	if query.Format != "" {
+		dialect.format = query.Format
+	}
+	if query.LineEnding != "" {
+		dialect.lineEnding = query.LineEnding
+	}
+
+	if dialect.encoding == "" {
+		dialect.encoding = CsvEncodingUTF8
+	}
+	if dialect.format == "" {
+		dialect.format = CsvFormatCSV
+	}
+	if dialect.lineEnding == "" {
+		dialect.lineEnding = CsvLineEndingLF
+	}
+
+	switch dialect.encoding {
+	case CsvEncodingUTF8, CsvEncodingUTF8BOM, CsvEncodingShiftJIS:
+	default:
+		return csvDialect{}, fmt.Errorf("'encoding' error: %s, %+v", dialect.encoding, []string{CsvEncodingUTF8, CsvEncodingUTF8BOM, CsvEncodingShiftJIS})
+	}
+	switch dialect.format {
+	case CsvFormatCSV, CsvFormatTSV:
+	default:
+		return csvDialect{}, fmt.Errorf("'format' error: %s, %+v", dialect.format, []string{CsvFormatCSV, CsvFormatTSV})
+	}
+	switch dialect.lineEnding {
+	case CsvLineEndingLF, CsvLineEndingCRLF:
+	default:
+		return csvDialect{}, fmt.Errorf("'line_ending' error: %s, %+v", dialect.lineEnding, []string{CsvLineEndingLF, CsvLineEndingCRLF})
+	}
+
+	dialect.header = query.Header
+	if dialect.header == "" {
+		dialect.header = CsvHeaderJapanese
+	}
+	if dialect.header != CsvHeaderJapanese && dialect.header != CsvHeaderEnglish {
+		return csvDialect{}, fmt.Errorf("'header' error: %s, %+v", dialect.header, []string{CsvHeaderJapanese, CsvHeaderEnglish})
+	}
+
+	names := query.Columns
+	if len(names) == 0 {
+		names = csvDefaultColumns
+	}
+	seen := map[string]bool{}
+	for _, name := range names {
+		column, ok := findCsvColumn(name)
+		if !ok {
+			return csvDialect{}, fmt.Errorf("'columns' error: unknown column %s", name)
+		}
+		if seen[name] {
+			return csvDialect{}, fmt.Errorf("'columns' error: duplicate column %s", name)
+		}
+		seen[name] = true
+		dialect.columns = append(dialect.columns, column)
+	}
+	return dialect, nil
+}
+
+// 見出しの行
+func (d csvDialect) headerRecord() []string {
+	record := []string{}
+	for _, column := range d.columns {
+		if d.header == CsvHeaderEnglish {
+			record = append(record, column.name)
+		} else {
+			record = append(record, column.header)
+		}
+	}
+	return record
+}
+
+// 科目 1 つの行
+func (d csvDialect) record(course *domain.Course) ([]string, error) {
+	record := []string{}
+	for _, column := range d.columns {
+		value, err := column.value(course)
+		if err != nil {
+			return nil, err
+		}
+		record = append(record, value)
+	}
+	return record, nil
+}
+
+// Accept ヘッダーから出力形式を読み取る、指定されていないものは空文字列
+// text/csv または text/tab-separated-values のうち最初のものを使い、それ以外の種類は無視する
+// 例: text/csv; charset=shift_jis; line_ending=crlf
+// charset=utf-8 には bom=present を付けると BOM を付ける
+func parseCsvAccept(accept string) (csvDialect, error) {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		if strings.TrimSpace(mediaRange) == "" {
+			continue
+		}
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			return csvDialect{}, err
+		}
+
+		dialect := csvDialect{}
+		switch mediaType {
+		case csvMediaType:
+			dialect.format = CsvFormatCSV
+		case tsvMediaType:
+			dialect.format = CsvFormatTSV
+		default:
+			continue
+		}
+
+		charset := strings.ToLower(params["charset"])
+		switch {
+		case charset == "":
+		case charset == CsvEncodingUTF8 || charset == "utf8":
+			dialect.encoding = CsvEncodingUTF8
+			if params["bom"] == "present" {
+				dialect.encoding = CsvEncodingUTF8BOM
+			}
+		case charset == CsvEncodingUTF8BOM:
+			dialect.encoding = CsvEncodingUTF8BOM
+		case util.Contains(csvShiftJISAliases, charset):
+			dialect.encoding = CsvEncodingShiftJIS
+		default:
+			dialect.encoding = charset
+		}
+		dialect.lineEnding = strings.ToLower(params["line_ending"])
+		return dialect, nil
+	}
+	return csvDialect{}, nil
+}
+
+// レスポンスの Content-Type
+func (d csvDialect) contentType() string {
+	mediaType := csvMediaType
+	if d.format == CsvFormatTSV {
+		mediaType = tsvMediaType
+	}
+	charset := "UTF-8"
+	if d.encoding == CsvEncodingShiftJIS {
+		charset = "Shift_JIS"
+	}
+	return mediaType + "; charset=" + charset
+}
+
+// ファイル名の拡張子
+func (d csvDialect) extension() string {
+	if d.format == CsvFormatTSV {
+		return "tsv"
+	}
+	return "csv"
+}
+
+// out へ出力形式に従って書き込む CSVWriter
+// 書き込み終えたら返り値の io.Closer を閉じる
+func (d csvDialect) newWriter(out io.Writer) (*gocsv.SafeCSVWriter, io.Closer, error) {
+	var closer io.Closer = nopCloser{}
+	switch d.encoding {
+	case CsvEncodingUTF8BOM:
+		_, err := out.Write([]byte("\xEF\xBB\xBF"))
+		if err != nil {
+			return nil, nil, err
+		}
+	case CsvEncodingShiftJIS:
+		encoder := transform.NewWriter(out, transform.Chain(runes.Map(newShiftJISFallback()), japanese.ShiftJIS.NewEncoder()))
+		out = encoder
+		closer = encoder
+	}
+
+	writer := csv.NewWriter(out)
+	if d.format == CsvFormatTSV {
+		writer.Comma = '\t'
+	}
+	writer.UseCRLF = d.lineEnding == CsvLineEndingCRLF
+	return gocsv.NewSafeCSVWriter(writer), closer, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// Shift_JIS に変換できない文字を shiftJISCompatible の文字か csvEncodingFallback に置き換える
+// 同じ文字を何度も調べないように結果を覚えておく
+func newShiftJISFallback() func(rune) rune {
+	encoder := japanese.ShiftJIS.NewEncoder()
+	encodable := map[rune]bool{}
+	return func(r rune) rune {
+		if r < 0x80 {
+			return r
+		}
+		if compatible, ok := shiftJISCompatible[r]; ok {
+			return compatible
+		}
+		ok, found := encodable[r]
+		if !found {
+			_, err := encoder.String(string(r))
+			ok = err == nil
+			encodable[r] = ok
+		}
+		if !ok {
+			return csvEncodingFallback
+		}
+		return r
+	}
+}
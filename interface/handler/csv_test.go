@@ -0,0 +1,280 @@
+package handler
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/gocarina/gocsv"
+	"golang.org/x/text/encoding/japanese"
+)
+
+func Test_newCsvDialect(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   CsvQuery
+		accept  string
+		want    csvDialect
+		wantErr bool
+	}{
+		{
+			name:  "省略時は BOM なしの UTF-8 の CSV、改行は LF",
+			query: CsvQuery{},
+			want: csvDialect{
+				encoding:   CsvEncodingUTF8,
+				format:     CsvFormatCSV,
+				lineEnding: CsvLineEndingLF,
+				header:     CsvHeaderJapanese,
+			},
+		},
+		{
+			name: "リクエストの本文で指定する",
+			query: CsvQuery{
+				Encoding:   CsvEncodingShiftJIS,
+				Format:     CsvFormatTSV,
+				LineEnding: CsvLineEndingCRLF,
+			},
+			want: csvDialect{
+				encoding:   CsvEncodingShiftJIS,
+				format:     CsvFormatTSV,
+				lineEnding: CsvLineEndingCRLF,
+				header:     CsvHeaderJapanese,
+			},
+		},
+		{
+			name:   "Accept ヘッダーで指定する",
+			query:  CsvQuery{},
+			accept: "application/json, text/tab-separated-values; charset=Windows-31J; line_ending=CRLF",
+			want: csvDialect{
+				encoding:   CsvEncodingShiftJIS,
+				format:     CsvFormatTSV,
+				lineEnding: CsvLineEndingCRLF,
+				header:     CsvHeaderJapanese,
+			},
+		},
+		{
+			name:   "Accept ヘッダーの bom=present で BOM を付ける",
+			query:  CsvQuery{},
+			accept: "text/csv; charset=utf-8; bom=present",
+			want: csvDialect{
+				encoding:   CsvEncodingUTF8BOM,
+				format:     CsvFormatCSV,
+				lineEnding: CsvLineEndingLF,
+				header:     CsvHeaderJapanese,
+			},
+		},
+		{
+			name: "リクエストの本文を Accept ヘッダーより優先する",
+			query: CsvQuery{
+				Encoding: CsvEncodingUTF8BOM,
+			},
+			accept: "text/csv; charset=shift_jis; line_ending=crlf",
+			want: csvDialect{
+				encoding:   CsvEncodingUTF8BOM,
+				format:     CsvFormatCSV,
+				lineEnding: CsvLineEndingCRLF,
+				header:     CsvHeaderJapanese,
+			},
+		},
+		{
+			name:   "CSV 以外の Accept ヘッダーは無視する",
+			query:  CsvQuery{},
+			accept: "*/*",
+			want: csvDialect{
+				encoding:   CsvEncodingUTF8,
+				format:     CsvFormatCSV,
+				lineEnding: CsvLineEndingLF,
+				header:     CsvHeaderJapanese,
+			},
+		},
+		{
+			name: "文字コードが不正",
+			query: CsvQuery{
+				Encoding: "euc-jp",
+			},
+			wantErr: true,
+		},
+		{
+			name:    "Accept ヘッダーの文字コードが不正",
+			query:   CsvQuery{},
+			accept:  "text/csv; charset=iso-2022-jp",
+			wantErr: true,
+		},
+		{
+			name: "区切り文字が不正",
+			query: CsvQuery{
+				Format: "psv",
+			},
+			wantErr: true,
+		},
+		{
+			name: "改行コードが不正",
+			query: CsvQuery{
+				LineEnding: "cr",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newCsvDialect(tt.query, tt.accept)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newCsvDialect() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			// 列は Test_csvDialect_headerRecord で確かめる
+			got.columns = nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newCsvDialect() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_csvDialect_newWriter(t *testing.T) {
+	type row struct {
+		CourseNumber string `csv:"科目番号"`
+		CourseName   string `csv:"科目名"`
+	}
+	rows := []row{
+		{CourseNumber: "GA10101", CourseName: "髙良〜①😀"},
+	}
+
+	tests := []struct {
+		name    string
+		dialect csvDialect
+		want    string
+	}{
+		{
+			name: "UTF-8",
+			dialect: csvDialect{
+				encoding:   CsvEncodingUTF8,
+				format:     CsvFormatCSV,
+				lineEnding: CsvLineEndingLF,
+			},
+			want: "科目番号,科目名\nGA10101,髙良〜①😀\n",
+		},
+		{
+			name: "UTF-8 の先頭に BOM を付ける",
+			dialect: csvDialect{
+				encoding:   CsvEncodingUTF8BOM,
+				format:     CsvFormatCSV,
+				lineEnding: CsvLineEndingLF,
+			},
+			want: "\xEF\xBB\xBF科目番号,科目名\nGA10101,髙良〜①😀\n",
+		},
+		{
+			name: "Shift_JIS では 〜 を CP932 のものにして、変換できない文字は ? にする",
+			dialect: csvDialect{
+				encoding:   CsvEncodingShiftJIS,
+				format:     CsvFormatCSV,
+				lineEnding: CsvLineEndingLF,
+			},
+			want: "科目番号,科目名\nGA10101,髙良～①?\n",
+		},
+		{
+			name: "TSV で改行は CRLF",
+			dialect: csvDialect{
+				encoding:   CsvEncodingUTF8,
+				format:     CsvFormatTSV,
+				lineEnding: CsvLineEndingCRLF,
+			},
+			want: "科目番号\t科目名\r\nGA10101\t髙良〜①😀\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			writer, closer, err := tt.dialect.newWriter(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = gocsv.MarshalCSV(rows, writer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = closer.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := buf.String()
+			if tt.dialect.encoding == CsvEncodingShiftJIS {
+				got, err = japanese.ShiftJIS.NewDecoder().String(got)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got != tt.want {
+				t.Errorf("csvDialect.newWriter() wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_csvDialect_headerRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   CsvQuery
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "省略時は以前から出力している列を日本語の見出しで",
+			query: CsvQuery{},
+			want:  []string{"科目番号", "科目名", "授業方法", "単位数", "標準履修年次", "実施学期", "曜時限", "教室", "担当教員", "授業概要", "備考", "科目等履修生申請可否", "申請条件", "英語(日本語)科目名", "科目コード", "要件科目名", "データ更新日"},
+		},
+		{
+			name: "指定した列を指定した順に",
+			query: CsvQuery{
+				Columns: []string{"year", "id", "csv_updated_at"},
+			},
+			want: []string{"年度", "ID", "KdB更新日"},
+		},
+		{
+			name: "英語の見出しは CourseJSON のフィールド名",
+			query: CsvQuery{
+				Columns: []string{"year", "id", "csv_updated_at"},
+				Header:  CsvHeaderEnglish,
+			},
+			want: []string{"year", "id", "csv_updated_at"},
+		},
+		{
+			name: "存在しない列",
+			query: CsvQuery{
+				Columns: []string{"id", "syllabus"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "列が重複している",
+			query: CsvQuery{
+				Columns: []string{"id", "year", "id"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "見出しの言語が不正",
+			query: CsvQuery{
+				Header: "fr",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialect, err := newCsvDialect(tt.query, "")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newCsvDialect() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			got := dialect.headerRecord()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("csvDialect.headerRecord() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
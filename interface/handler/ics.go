@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sylms/azuki/domain"
+)
+
+// iCalendar の TZID、domain.CalendarLocation に合わせた VTIMEZONE を付ける
+const icsTimezone = "Asia/Tokyo"
+
+// 1 行の長さの上限 (改行を除く)、RFC 5545 3.1
+const icsLineOctets = 75
+
+// RFC 5545 の iCalendar を組み立てる
+type icsBuilder struct {
+	buf bytes.Buffer
+}
+
+// 長い行は折り返す、UTF-8 の文字の途中では折り返さない
+func (b *icsBuilder) line(name string, value string) {
+	content := name + ":" + value
+	limit := icsLineOctets
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		b.buf.WriteString(content[:cut] + "\r\n ")
+		content = content[cut:]
+		// 折り返した行は先頭の空白の分だけ短くする
+		limit = icsLineOctets - 1
+	}
+	b.buf.WriteString(content + "\r\n")
+}
+
+func (b *icsBuilder) bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// TEXT の値のエスケープ、RFC 5545 3.3.11
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// TZID を付けて使う現地時刻
+func icsLocalTime(t time.Time) string {
+	return t.In(domain.CalendarLocation).Format("20060102T150405")
+}
+
+// EXDATE, RDATE の値
+func icsLocalTimes(times []time.Time) string {
+	values := []string{}
+	for _, t := range times {
+		values = append(values, icsLocalTime(t))
+	}
+	return strings.Join(values, ",")
+}
+
+func icsUTCTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// 時間割のカレンダーを iCalendar にする
+// 授業の予定は毎週繰り返す VEVENT とし、授業を行わない日は EXDATE で除いて、振替授業日は RDATE で加える
+func buildTimetableICS(calendar *domain.TimetableCalendar, stamp time.Time) ([]byte, error) {
+	b := &icsBuilder{}
+	b.line("BEGIN", "VCALENDAR")
+	b.line("VERSION", "2.0")
+	b.line("PRODID", "-//sylms//azuki//JA")
+	b.line("CALSCALE", "GREGORIAN")
+	b.line("METHOD", "PUBLISH")
+	b.line("X-WR-CALNAME", icsEscape(fmt.Sprintf("%d年度 時間割", calendar.Calendar.Year)))
+	b.line("X-WR-TIMEZONE", icsTimezone)
+
+	b.line("BEGIN", "VTIMEZONE")
+	b.line("TZID", icsTimezone)
+	b.line("BEGIN", "STANDARD")
+	b.line("DTSTART", "19700101T000000")
+	b.line("TZOFFSETFROM", "+0900")
+	b.line("TZOFFSETTO", "+0900")
+	b.line("TZNAME", "JST")
+	b.line("END", "STANDARD")
+	b.line("END", "VTIMEZONE")
+
+	for _, event := range calendar.Events {
+		err := writeCourseEvent(b, event, stamp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	b.line("END", "VCALENDAR")
+	return b.bytes(), nil
+}
+
+func writeCourseEvent(b *icsBuilder, event *domain.CourseEvent, stamp time.Time) error {
+	course := event.Course
+	term, err := decodeTerm(event.Term)
+	if err != nil {
+		return err
+	}
+	periods := fmt.Sprintf("%s%d", event.Day, event.FirstPeriod)
+	if event.LastPeriod != event.FirstPeriod {
+		periods += fmt.Sprintf("-%d", event.LastPeriod)
+	}
+
+	description := []string{fmt.Sprintf("%s %s %s", course.CourseNumber, term, periods)}
+	if len(course.Instructor) != 0 {
+		description = append(description, "担当教員: "+strings.Join(course.Instructor, ", "))
+	}
+	if course.CourseOverview != "" {
+		description = append(description, "", course.CourseOverview)
+	}
+
+	b.line("BEGIN", "VEVENT")
+	b.line("UID", fmt.Sprintf("%d-%s-%d-%d-%d@sylms", course.Year, course.CourseNumber, event.Term, event.Start.Weekday(), event.FirstPeriod))
+	b.line("DTSTAMP", icsUTCTime(stamp))
+	b.line("DTSTART;TZID="+icsTimezone, icsLocalTime(event.Start))
+	b.line("DTEND;TZID="+icsTimezone, icsLocalTime(event.End))
+	b.line("RRULE", "FREQ=WEEKLY;UNTIL="+icsUTCTime(event.Last))
+	if len(event.Exceptions) != 0 {
+		b.line("EXDATE;TZID="+icsTimezone, icsLocalTimes(event.Exceptions))
+	}
+	if len(event.Additions) != 0 {
+		b.line("RDATE;TZID="+icsTimezone, icsLocalTimes(event.Additions))
+	}
+	b.line("SUMMARY", icsEscape(course.CourseName))
+	if course.Classroom != "" {
+		b.line("LOCATION", icsEscape(course.Classroom))
+	}
+	b.line("DESCRIPTION", icsEscape(strings.Join(description, "\n")))
+	b.line("END", "VEVENT")
+	return nil
+}
@@ -0,0 +1,29 @@
+package handler
+
+import "testing"
+
+func Test_icsEscape(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{
+			name: "区切り文字とバックスラッシュ",
+			s:    `a,b;c\d`,
+			want: `a\,b\;c\\d`,
+		},
+		{
+			name: "改行",
+			s:    "1行目\r\n2行目\n3行目",
+			want: `1行目\n2行目\n3行目`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := icsEscape(tt.s); got != tt.want {
+				t.Errorf("icsEscape() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
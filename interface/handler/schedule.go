@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/sylms/azuki/domain"
+	"github.com/sylms/azuki/usecase"
+	"github.com/sylms/azuki/util"
+)
+
+type ScheduleHandler interface {
+	Generate(http.ResponseWriter, *http.Request)
+}
+
+type scheduleHandler struct {
+	uc usecase.ScheduleUseCase
+}
+
+func NewScheduleHandler(uc usecase.ScheduleUseCase) ScheduleHandler {
+	return &scheduleHandler{
+		uc: uc,
+	}
+}
+
+// 時間割の自動作成で受け付ける候補の数の上限
+const scheduleMaxItems = 30
+
+type ScheduleJSON struct {
+	Courses       []CourseJSON    `json:"courses"`
+	CreditsByTerm map[int]float64 `json:"credits_by_term"`
+	TotalCredits  float64         `json:"total_credits"`
+	Penalty       int             `json:"penalty"`
+}
+
+type ScheduleReasonJSON struct {
+	Code         string `json:"code"`
+	CourseNumber string `json:"course_number,omitempty"`
+	ConflictWith string `json:"conflict_with,omitempty"`
+	Term         int    `json:"term,omitempty"`
+	Period       string `json:"period,omitempty"`
+}
+
+type ScheduleUnplacedJSON struct {
+	Key     string               `json:"key"`
+	Reasons []ScheduleReasonJSON `json:"reasons"`
+}
+
+// /timetable/generate のレスポンス
+type ScheduleResultJSON struct {
+	Schedules []ScheduleJSON         `json:"schedules"`
+	Unplaced  []ScheduleUnplacedJSON `json:"unplaced"`
+	Truncated bool                   `json:"truncated"`
+}
+
+// 候補の科目から重なりの無い時間割を作り、良いものから順に返す
+func (h *scheduleHandler) Generate(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var query domain.ScheduleQuery
+	err := json.NewDecoder(r.Body).Decode(&query)
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err = validateScheduleQuery(query)
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.uc.Generate(query)
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	res := ScheduleResultJSON{
+		Schedules: []ScheduleJSON{},
+		Unplaced:  []ScheduleUnplacedJSON{},
+		Truncated: result.Truncated,
+	}
+	for _, schedule := range result.Schedules {
+		scheduleJson := ScheduleJSON{
+			Courses:       []CourseJSON{},
+			CreditsByTerm: schedule.CreditsByTerm,
+			TotalCredits:  schedule.TotalCredits,
+			Penalty:       schedule.Penalty,
+		}
+		for _, course := range schedule.Courses {
+			scheduleJson.Courses = append(scheduleJson.Courses, CourseJSON(*course))
+		}
+		res.Schedules = append(res.Schedules, scheduleJson)
+	}
+	for _, unplaced := range result.Unplaced {
+		unplacedJson := ScheduleUnplacedJSON{
+			Key:     unplaced.Key,
+			Reasons: []ScheduleReasonJSON{},
+		}
+		for _, reason := range unplaced.Reasons {
+			unplacedJson.Reasons = append(unplacedJson.Reasons, ScheduleReasonJSON(*reason))
+		}
+		res.Unplaced = append(res.Unplaced, unplacedJson)
+	}
+
+	resJson, err := json.Marshal(res)
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resJson)
+	if err != nil {
+		log.Printf("%+v", err)
+	}
+}
+
+func validateScheduleQuery(query domain.ScheduleQuery) error {
+	keys := append(append([]string{}, query.Required...), query.Optional...)
+	if len(keys) == 0 {
+		return errors.New("'required' or 'optional' is required")
+	}
+	if len(keys) > scheduleMaxItems {
+		return fmt.Errorf("'required' and 'optional' accept up to %d courses in total", scheduleMaxItems)
+	}
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if key == "" {
+			return errors.New("'required' and 'optional' must not contain an empty string")
+		}
+		if seen[key] {
+			return fmt.Errorf("course is duplicated: %s", key)
+		}
+		seen[key] = true
+	}
+
+	if query.Year < 0 {
+		return fmt.Errorf("'year' error: %d", query.Year)
+	}
+	if query.MaxCreditsPerTerm < 0 {
+		return fmt.Errorf("'max_credits_per_term' error: %v", query.MaxCreditsPerTerm)
+	}
+	allowedDays := []string{"月", "火", "水", "木", "金", "土", "日"}
+	for _, day := range query.FreeDays {
+		if !util.Contains(allowedDays, day) {
+			return fmt.Errorf("'free_days' error: %s, %+v", day, allowedDays)
+		}
+	}
+	if query.Limit < 0 || domain.ScheduleMaxLimit < query.Limit {
+		return fmt.Errorf("'limit' range error: %d, 0 - %d", query.Limit, domain.ScheduleMaxLimit)
+	}
+	return nil
+}
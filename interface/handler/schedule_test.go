@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/sylms/azuki/domain"
+)
+
+type scheduleUseCaseMock struct {
+	FakeGenerate func(domain.ScheduleQuery) (*domain.ScheduleResult, error)
+}
+
+func (uc *scheduleUseCaseMock) Generate(query domain.ScheduleQuery) (*domain.ScheduleResult, error) {
+	return uc.FakeGenerate(query)
+}
+
+func Test_scheduleHandler_Generate(t *testing.T) {
+	tests := []struct {
+		name              string
+		fakeGenerate      func(domain.ScheduleQuery) (*domain.ScheduleResult, error)
+		reqBody           string
+		wantResStatusCode int
+		wantResBody       string
+	}{
+		{
+			name: "時間割と入れられなかった候補を返す",
+			fakeGenerate: func(query domain.ScheduleQuery) (*domain.ScheduleResult, error) {
+				want := domain.ScheduleQuery{
+					Required:          []string{"GA15301"},
+					Optional:          []string{"GB11931"},
+					MaxCreditsPerTerm: 10,
+					FreeDays:          []string{"金"},
+					AvoidFirstPeriod:  true,
+				}
+				if !reflect.DeepEqual(query, want) {
+					t.Errorf("query mismatch:\ngot: %+v\nwant: %+v", query, want)
+				}
+				return &domain.ScheduleResult{
+					Schedules: []*domain.Schedule{
+						{
+							Courses:       []*domain.Course{{ID: 1}},
+							CreditsByTerm: map[int]float64{1: 2},
+							TotalCredits:  2,
+						},
+					},
+					Unplaced: []*domain.ScheduleUnplaced{
+						{
+							Key: "GB11931",
+							Reasons: []*domain.ScheduleReason{
+								{Code: domain.ScheduleReasonFirstPeriod, CourseNumber: "GB11931", Period: "月1"},
+							},
+						},
+					},
+				}, nil
+			},
+			reqBody: `{
+		    "required": ["GA15301"],
+		    "optional": ["GB11931"],
+		    "max_credits_per_term": 10,
+		    "free_days": ["金"],
+		    "avoid_first_period": true
+		}`,
+			wantResStatusCode: http.StatusOK,
+			wantResBody: `{"schedules":[{"courses":[` + emptyCourseJSON(1) + `],"credits_by_term":{"1":2},"total_credits":2,"penalty":0}],` +
+				`"unplaced":[{"key":"GB11931","reasons":[{"code":"first_period","course_number":"GB11931","period":"月1"}]}],"truncated":false}`,
+		},
+		{
+			name: "候補が無い",
+			fakeGenerate: func(query domain.ScheduleQuery) (*domain.ScheduleResult, error) {
+				t.Fatal("Generate must not be called")
+				return nil, nil
+			},
+			reqBody:           `{"required": [], "optional": []}`,
+			wantResStatusCode: http.StatusBadRequest,
+			wantResBody:       ``,
+		},
+		{
+			name: "候補が重複している",
+			fakeGenerate: func(query domain.ScheduleQuery) (*domain.ScheduleResult, error) {
+				t.Fatal("Generate must not be called")
+				return nil, nil
+			},
+			reqBody:           `{"required": ["GA15301"], "optional": ["GA15301"]}`,
+			wantResStatusCode: http.StatusBadRequest,
+			wantResBody:       ``,
+		},
+		{
+			name: "曜日が不正",
+			fakeGenerate: func(query domain.ScheduleQuery) (*domain.ScheduleResult, error) {
+				t.Fatal("Generate must not be called")
+				return nil, nil
+			},
+			reqBody:           `{"required": ["GA15301"], "free_days": ["Fri"]}`,
+			wantResStatusCode: http.StatusBadRequest,
+			wantResBody:       ``,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/timetable/generate", bytes.NewBufferString(tt.reqBody))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			res := httptest.NewRecorder()
+
+			h := &scheduleHandler{
+				uc: &scheduleUseCaseMock{
+					FakeGenerate: tt.fakeGenerate,
+				},
+			}
+
+			h.Generate(res, req)
+
+			resBodyGot := res.Body.String()
+			if resBodyGot != tt.wantResBody {
+				t.Errorf("response mismatch:\ngot: %s\nwant: %s", resBodyGot, tt.wantResBody)
+			}
+
+			statusCodeGot := res.Code
+			if statusCodeGot != tt.wantResStatusCode {
+				t.Errorf("response status code mismatch:\ngot: %d\nwant: %d", statusCodeGot, tt.wantResStatusCode)
+			}
+		})
+	}
+}
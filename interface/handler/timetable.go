@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/sylms/azuki/domain"
+	"github.com/sylms/azuki/usecase"
+)
+
+type TimetableHandler interface {
+	Check(http.ResponseWriter, *http.Request)
+	Ics(http.ResponseWriter, *http.Request)
+}
+
+type timetableHandler struct {
+	uc usecase.TimetableUseCase
+}
+
+func NewTimetableHandler(uc usecase.TimetableUseCase) TimetableHandler {
+	return &timetableHandler{
+		uc: uc,
+	}
+}
+
+type TimetableCellJSON struct {
+	Term   int    `json:"term"`
+	Period string `json:"period"`
+}
+
+type TimetableConflictJSON struct {
+	// 重なっている 2 つの科目の科目番号
+	CourseNumbers []string            `json:"course_numbers"`
+	Cells         []TimetableCellJSON `json:"cells"`
+}
+
+// /timetable/check のレスポンス
+type TimetableCheckJSON struct {
+	Courses   []CourseJSON            `json:"courses"`
+	Conflicts []TimetableConflictJSON `json:"conflicts"`
+	// 展開した開講時期 (春A = 1, ..., 秋C = 6 など) ごとの単位数の合計
+	CreditsByTerm map[int]float64 `json:"credits_by_term"`
+	TotalCredits  float64         `json:"total_credits"`
+}
+
+// 時間割の科目どうしの重なりと開講時期ごとの単位数を返す
+func (h *timetableHandler) Check(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var query domain.TimetableCheckQuery
+	err := json.NewDecoder(r.Body).Decode(&query)
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err = validateTimetableCheckQuery(query)
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	check, err := h.uc.Check(query)
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	res := TimetableCheckJSON{
+		Courses:       []CourseJSON{},
+		Conflicts:     []TimetableConflictJSON{},
+		CreditsByTerm: check.CreditsByTerm,
+		TotalCredits:  check.TotalCredits,
+	}
+	for _, course := range check.Courses {
+		res.Courses = append(res.Courses, CourseJSON(*course))
+	}
+	for _, conflict := range check.Conflicts {
+		conflictJson := TimetableConflictJSON{
+			CourseNumbers: []string{conflict.Courses[0].CourseNumber, conflict.Courses[1].CourseNumber},
+			Cells:         []TimetableCellJSON{},
+		}
+		for _, cell := range conflict.Cells {
+			conflictJson.Cells = append(conflictJson.Cells, TimetableCellJSON(cell))
+		}
+		res.Conflicts = append(res.Conflicts, conflictJson)
+	}
+
+	resJson, err := json.Marshal(res)
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resJson)
+	if err != nil {
+		log.Printf("%+v", err)
+	}
+}
+
+func validateTimetableCheckQuery(query domain.TimetableCheckQuery) error {
+	if len(query.CourseNumbers) == 0 {
+		return errors.New("'course_numbers' is required")
+	}
+	for _, number := range query.CourseNumbers {
+		if number == "" {
+			return errors.New("'course_numbers' must not contain an empty string")
+		}
+	}
+	if query.Year < 0 {
+		return fmt.Errorf("'year' error: %d", query.Year)
+	}
+	return nil
+}
+
+// 時間割の科目の授業を iCalendar (.ics) で返す
+func (h *timetableHandler) Ics(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var query domain.TimetableCalendarQuery
+	err := json.NewDecoder(r.Body).Decode(&query)
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err = validateTimetableCalendarQuery(query)
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	calendar, err := h.uc.Calendar(query)
+	if errors.Is(err, domain.ErrCalendarNotFound) {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	ics, err := buildTimetableICS(calendar, now())
+	if err != nil {
+		log.Printf("%+v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=UTF-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="timetable_%d.ics"`, query.Year))
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(ics)
+	if err != nil {
+		log.Printf("%+v", err)
+	}
+}
+
+func validateTimetableCalendarQuery(query domain.TimetableCalendarQuery) error {
+	err := validateTimetableCheckQuery(domain.TimetableCheckQuery(query))
+	if err != nil {
+		return err
+	}
+	// 学年暦は年度ごとなので、最新の年度に任せずに指定させる
+	if query.Year == 0 {
+		return errors.New("'year' is required")
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sylms/azuki/domain"
+)
+
+type timetableUseCaseMock struct {
+	FakeCheck    func(domain.TimetableCheckQuery) (*domain.TimetableCheck, error)
+	FakeCalendar func(domain.TimetableCalendarQuery) (*domain.TimetableCalendar, error)
+}
+
+func (uc *timetableUseCaseMock) Check(query domain.TimetableCheckQuery) (*domain.TimetableCheck, error) {
+	return uc.FakeCheck(query)
+}
+
+func (uc *timetableUseCaseMock) Calendar(query domain.TimetableCalendarQuery) (*domain.TimetableCalendar, error) {
+	return uc.FakeCalendar(query)
+}
+
+func Test_timetableHandler_Check(t *testing.T) {
+	tests := []struct {
+		name              string
+		fakeCheck         func(domain.TimetableCheckQuery) (*domain.TimetableCheck, error)
+		reqBody           string
+		wantResStatusCode int
+		wantResBody       string
+	}{
+		{
+			name: "重なりと開講時期ごとの単位数を返す",
+			fakeCheck: func(query domain.TimetableCheckQuery) (*domain.TimetableCheck, error) {
+				want := domain.TimetableCheckQuery{Year: 2021, CourseNumbers: []string{"GB11931", "GB10244"}}
+				if !reflect.DeepEqual(query, want) {
+					t.Errorf("query mismatch:\ngot: %+v\nwant: %+v", query, want)
+				}
+				a := &domain.Course{ID: 1, CourseNumber: "GB11931"}
+				b := &domain.Course{ID: 2, CourseNumber: "GB10244"}
+				return &domain.TimetableCheck{
+					Courses: []*domain.Course{a, b},
+					Conflicts: []*domain.TimetableConflict{
+						{
+							Courses: [2]*domain.Course{a, b},
+							Cells:   []domain.TimetableCell{{Term: 4, Period: "月1"}},
+						},
+					},
+					CreditsByTerm: map[int]float64{4: 3, 5: 1.5},
+					TotalCredits:  3,
+				}, nil
+			},
+			reqBody:           `{"year": 2021, "course_numbers": ["GB11931", "GB10244"]}`,
+			wantResStatusCode: http.StatusOK,
+			wantResBody: `{"courses":[` +
+				strings.Replace(emptyCourseJSON(1), `"course_number":""`, `"course_number":"GB11931"`, 1) + `,` +
+				strings.Replace(emptyCourseJSON(2), `"course_number":""`, `"course_number":"GB10244"`, 1) + `],` +
+				`"conflicts":[{"course_numbers":["GB11931","GB10244"],"cells":[{"term":4,"period":"月1"}]}],` +
+				`"credits_by_term":{"4":3,"5":1.5},"total_credits":3}`,
+		},
+		{
+			name: "科目番号が無い",
+			fakeCheck: func(query domain.TimetableCheckQuery) (*domain.TimetableCheck, error) {
+				t.Fatal("Check must not be called")
+				return nil, nil
+			},
+			reqBody:           `{"year": 2021, "course_numbers": []}`,
+			wantResStatusCode: http.StatusBadRequest,
+			wantResBody:       ``,
+		},
+		{
+			name: "科目が見つからない",
+			fakeCheck: func(query domain.TimetableCheckQuery) (*domain.TimetableCheck, error) {
+				return nil, errors.New("course not found: XX00000")
+			},
+			reqBody:           `{"course_numbers": ["XX00000"]}`,
+			wantResStatusCode: http.StatusBadRequest,
+			wantResBody:       ``,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/timetable/check", bytes.NewBufferString(tt.reqBody))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			res := httptest.NewRecorder()
+
+			h := &timetableHandler{
+				uc: &timetableUseCaseMock{
+					FakeCheck: tt.fakeCheck,
+				},
+			}
+
+			h.Check(res, req)
+
+			resBodyGot := res.Body.String()
+			if resBodyGot != tt.wantResBody {
+				t.Errorf("response mismatch:\ngot: %s\nwant: %s", resBodyGot, tt.wantResBody)
+			}
+
+			statusCodeGot := res.Code
+			if statusCodeGot != tt.wantResStatusCode {
+				t.Errorf("response status code mismatch:\ngot: %d\nwant: %d", statusCodeGot, tt.wantResStatusCode)
+			}
+		})
+	}
+}
+
+func Test_timetableHandler_Ics(t *testing.T) {
+	now = func() time.Time {
+		return time.Date(2021, 4, 1, 9, 30, 0, 0, time.UTC)
+	}
+	defer func() {
+		now = time.Now
+	}()
+
+	at := func(month time.Month, day int, hour int, minute int) time.Time {
+		return time.Date(2021, month, day, hour, minute, 0, 0, domain.CalendarLocation)
+	}
+	course := &domain.Course{
+		CourseNumber:   "GA10101",
+		CourseName:     "情報社会と法制度",
+		Term:           []int{4},
+		Period:         []string{"月5", "月6"},
+		Classroom:      "3A204",
+		Instructor:     []string{"髙良 幸哉"},
+		CourseOverview: "情報化社会における法制度や情報モラル向上に必要な基礎知識を習得することを目指す。",
+		Year:           2021,
+	}
+
+	tests := []struct {
+		name                   string
+		fakeCalendar           func(domain.TimetableCalendarQuery) (*domain.TimetableCalendar, error)
+		reqContentTypeHeader   string
+		reqBody                string
+		wantResStatusCode      int
+		wantContentDisposition string
+		wantResBody            string
+	}{
+		{
+			name: "授業を毎週繰り返す予定にする",
+			fakeCalendar: func(query domain.TimetableCalendarQuery) (*domain.TimetableCalendar, error) {
+				want := domain.TimetableCalendarQuery{Year: 2021, CourseNumbers: []string{"GA10101"}}
+				if !reflect.DeepEqual(query, want) {
+					t.Errorf("query mismatch:\ngot: %+v\nwant: %+v", query, want)
+				}
+				return &domain.TimetableCalendar{
+					Calendar: &domain.AcademicCalendar{Year: 2021},
+					Events: []*domain.CourseEvent{
+						{
+							Course: course, Term: 4, Day: "月", FirstPeriod: 5, LastPeriod: 6,
+							Start: at(time.October, 4, 15, 15), End: at(time.October, 4, 18, 0), Last: at(time.November, 8, 15, 15),
+							Exceptions: []time.Time{at(time.October, 11, 15, 15), at(time.October, 18, 15, 15)},
+							Additions:  []time.Time{at(time.November, 10, 15, 15)},
+						},
+					},
+				}, nil
+			},
+			reqContentTypeHeader:   "application/json",
+			reqBody:                `{"year": 2021, "course_numbers": ["GA10101"]}`,
+			wantResStatusCode:      http.StatusOK,
+			wantContentDisposition: `attachment; filename="timetable_2021.ics"`,
+			wantResBody: strings.Join([]string{
+				"BEGIN:VCALENDAR",
+				"VERSION:2.0",
+				"PRODID:-//sylms//azuki//JA",
+				"CALSCALE:GREGORIAN",
+				"METHOD:PUBLISH",
+				"X-WR-CALNAME:2021年度 時間割",
+				"X-WR-TIMEZONE:Asia/Tokyo",
+				"BEGIN:VTIMEZONE",
+				"TZID:Asia/Tokyo",
+				"BEGIN:STANDARD",
+				"DTSTART:19700101T000000",
+				"TZOFFSETFROM:+0900",
+				"TZOFFSETTO:+0900",
+				"TZNAME:JST",
+				"END:STANDARD",
+				"END:VTIMEZONE",
+				"BEGIN:VEVENT",
+				"UID:2021-GA10101-4-1-5@sylms",
+				"DTSTAMP:20210401T093000Z",
+				"DTSTART;TZID=Asia/Tokyo:20211004T151500",
+				"DTEND;TZID=Asia/Tokyo:20211004T180000",
+				"RRULE:FREQ=WEEKLY;UNTIL=20211108T061500Z",
+				"EXDATE;TZID=Asia/Tokyo:20211011T151500,20211018T151500",
+				"RDATE;TZID=Asia/Tokyo:20211110T151500",
+				"SUMMARY:情報社会と法制度",
+				"LOCATION:3A204",
+				// 75 オクテットを超えないように、文字の途中を避けて折り返す
+				"DESCRIPTION:GA10101 秋A 月5-6\\n担当教員: 髙良 幸哉\\n\\n情報化",
+				" 社会における法制度や情報モラル向上に必要な基礎知",
+				" 識を習得することを目指す。",
+				"END:VEVENT",
+				"END:VCALENDAR",
+				"",
+			}, "\r\n"),
+		},
+		{
+			name: "年度が無い",
+			fakeCalendar: func(query domain.TimetableCalendarQuery) (*domain.TimetableCalendar, error) {
+				t.Fatal("Calendar must not be called")
+				return nil, nil
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody:              `{"course_numbers": ["GA10101"]}`,
+			wantResStatusCode:    http.StatusBadRequest,
+		},
+		{
+			name: "学年暦が無い",
+			fakeCalendar: func(query domain.TimetableCalendarQuery) (*domain.TimetableCalendar, error) {
+				return nil, domain.ErrCalendarNotFound
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody:              `{"year": 2019, "course_numbers": ["GA10101"]}`,
+			wantResStatusCode:    http.StatusNotFound,
+		},
+		{
+			name: "科目が見つからない",
+			fakeCalendar: func(query domain.TimetableCalendarQuery) (*domain.TimetableCalendar, error) {
+				return nil, errors.New("course not found: XX00000")
+			},
+			reqContentTypeHeader: "application/json",
+			reqBody:              `{"year": 2021, "course_numbers": ["XX00000"]}`,
+			wantResStatusCode:    http.StatusBadRequest,
+		},
+		{
+			name: "Content-Type が JSON でない",
+			fakeCalendar: func(query domain.TimetableCalendarQuery) (*domain.TimetableCalendar, error) {
+				t.Fatal("Calendar must not be called")
+				return nil, nil
+			},
+			reqContentTypeHeader: "text/plain",
+			reqBody:              `{"year": 2021, "course_numbers": ["GA10101"]}`,
+			wantResStatusCode:    http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/timetable/ics", bytes.NewBufferString(tt.reqBody))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", tt.reqContentTypeHeader)
+
+			res := httptest.NewRecorder()
+
+			h := &timetableHandler{
+				uc: &timetableUseCaseMock{
+					FakeCalendar: tt.fakeCalendar,
+				},
+			}
+
+			h.Ics(res, req)
+
+			resBodyGot := res.Body.String()
+			if resBodyGot != tt.wantResBody {
+				t.Errorf("response mismatch:\ngot: %q\nwant: %q", resBodyGot, tt.wantResBody)
+			}
+
+			statusCodeGot := res.Code
+			if statusCodeGot != tt.wantResStatusCode {
+				t.Errorf("response status code mismatch:\ngot: %d\nwant: %d", statusCodeGot, tt.wantResStatusCode)
+			}
+
+			contentDispositionGot := res.Header().Get("Content-Disposition")
+			if contentDispositionGot != tt.wantContentDisposition {
+				t.Errorf("Content-Disposition mismatch:\ngot: %s\nwant: %s", contentDispositionGot, tt.wantContentDisposition)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jmoiron/sqlx"
@@ -21,8 +22,15 @@ const (
 	envSylmsPostgresHostKey     = "SYLMS_POSTGRES_HOST"
 	envSylmsPostgresPortKey     = "SYLMS_POSTGRES_PORT"
 	envSylmsPort                = "SYLMS_PORT"
+	// 学年暦のファイルを置くディレクトリ、省略時は defaultCalendarDir
+	envSylmsCalendarDirKey = "SYLMS_CALENDAR_DIR"
 )
 
+const defaultCalendarDir = "calendar"
+
+// 初回の起動では csv2sql が courses を作るまで待つ
+const coursesWaitTimeout = 5 * time.Minute
+
 func main() {
 	envKeys := []string{envSylmsPostgresDBKey, envSylmsPostgresUserKey, envSylmsPostgresPasswordKey, envSylmsPostgresHostKey, envSylmsPostgresPortKey, envSylmsPort}
 	for _, key := range envKeys {
@@ -38,20 +46,48 @@ func main() {
 	postgresHost := os.Getenv(envSylmsPostgresHostKey)
 	postgresPort := os.Getenv(envSylmsPostgresPortKey)
 	portStr := os.Getenv(envSylmsPort)
+	calendarDir := os.Getenv(envSylmsCalendarDirKey)
+	if calendarDir == "" {
+		calendarDir = defaultCalendarDir
+	}
 
 	db, err := sqlx.Open("postgres", fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", postgresHost, postgresPort, postgresUser, postgresPassword, postgresDb))
 	if err != nil {
 		log.Fatalf("%+v", err)
 	}
 
+	err = persistence.WaitForCourses(db, coursesWaitTimeout)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	err = persistence.Migrate(db)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+
+	calendarPersistence := persistence.NewCalendarPersistence(calendarDir)
 	persistence := persistence.NewCoursePersistence(db)
 	useCase := usecase.NewCourseUseCase(persistence)
+	timetableUseCase := usecase.NewTimetableUseCase(persistence, calendarPersistence)
+	timetableHandler := handler.NewTimetableHandler(timetableUseCase)
+	scheduleUseCase := usecase.NewScheduleUseCase(persistence)
+	scheduleHandler := handler.NewScheduleHandler(scheduleUseCase)
+	calendarUseCase := usecase.NewCalendarUseCase(calendarPersistence)
+	calendarHandler := handler.NewCalendarHandler(calendarUseCase)
 	handler := handler.NewCourseHandler(useCase)
 
 	r := mux.NewRouter()
 	r.HandleFunc("/course", handler.Search).Methods("POST")
+	r.HandleFunc("/course/fits", handler.Fits).Methods("POST")
+	r.HandleFunc("/course/{id:[0-9]+}", handler.FindByID).Methods("GET")
+	r.HandleFunc("/courses/{year:[0-9]+}/{course_number}", handler.FindByNumber).Methods("GET")
 	r.HandleFunc("/facet", handler.Facet).Methods("POST")
 	r.HandleFunc("/csv", handler.Csv).Methods("POST")
+	r.HandleFunc("/years", handler.Years).Methods("GET")
+	r.HandleFunc("/timetable/check", timetableHandler.Check).Methods("POST")
+	r.HandleFunc("/timetable/ics", timetableHandler.Ics).Methods("POST")
+	r.HandleFunc("/timetable/generate", scheduleHandler.Generate).Methods("POST")
+	r.HandleFunc("/calendar/{year:[0-9]+}", calendarHandler.FindByYear).Methods("GET")
 	c := cors.Default().Handler(r)
 	log.Printf("Listen Port: %s", portStr)
 	err = http.ListenAndServe(fmt.Sprintf(":%s", portStr), c)
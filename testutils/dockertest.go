@@ -19,7 +19,8 @@ func CreateDB() (*sqlx.DB, error) {
 
 	runOptions := &dockertest.RunOptions{
 		Repository: "postgres",
-		Tag:        "12.7-alpine",
+		// course_search の正規化に使う normalize は PostgreSQL 13 から
+		Tag: "13.5-alpine",
 		Env: []string{
 			"TZ=Asia/Tokyo",
 			"POSTGRES_DB=courses",
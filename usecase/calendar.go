@@ -0,0 +1,23 @@
+package usecase
+
+import (
+	"github.com/sylms/azuki/domain"
+)
+
+type CalendarUseCase interface {
+	FindByYear(year int) (*domain.AcademicCalendar, error)
+}
+
+type calendarUseCase struct {
+	repo domain.CalendarRepository
+}
+
+func NewCalendarUseCase(repo domain.CalendarRepository) CalendarUseCase {
+	return &calendarUseCase{
+		repo: repo,
+	}
+}
+
+func (uc *calendarUseCase) FindByYear(year int) (*domain.AcademicCalendar, error) {
+	return uc.repo.FindByYear(year)
+}
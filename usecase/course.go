@@ -1,10 +1,22 @@
 package usecase
 
-import "github.com/sylms/azuki/domain"
+import (
+	"context"
+	"fmt"
+
+	"github.com/sylms/azuki/domain"
+)
 
 type CourseUseCase interface {
-	Search(domain.CourseQuery) ([]*domain.Course, error)
+	Search(domain.CourseQuery) (*domain.CourseSearchResult, error)
+	Stream(ctx context.Context, query domain.CourseQuery, fn func(*domain.Course) error) error
 	Facet(domain.CourseQuery) ([]*domain.Facet, error)
+	PeriodFacet(domain.CourseQuery) ([]*domain.PeriodFacet, error)
+	FacetValues(domain.CourseQuery) (map[string][]*domain.FacetValue, error)
+	Years() ([]*domain.AcademicYear, error)
+	Fits(domain.CourseFitsQuery) (*domain.CourseSearchResult, error)
+	FindByID(id int) (*domain.Course, error)
+	FindByNumber(year int, number string) (*domain.Course, error)
 }
 
 type courseUseCase struct {
@@ -17,12 +29,16 @@ func NewCourseUseCase(repo domain.CourseRepository) CourseUseCase {
 	}
 }
 
-func (uc *courseUseCase) Search(query domain.CourseQuery) ([]*domain.Course, error) {
-	courses, err := uc.repo.Search(query)
+func (uc *courseUseCase) Search(query domain.CourseQuery) (*domain.CourseSearchResult, error) {
+	result, err := uc.repo.Search(query)
 	if err != nil {
 		return nil, err
 	}
-	return courses, nil
+	return result, nil
+}
+
+func (uc *courseUseCase) Stream(ctx context.Context, query domain.CourseQuery, fn func(*domain.Course) error) error {
+	return uc.repo.Stream(ctx, query, fn)
 }
 
 func (uc *courseUseCase) Facet(query domain.CourseQuery) ([]*domain.Facet, error) {
@@ -32,3 +48,92 @@ func (uc *courseUseCase) Facet(query domain.CourseQuery) ([]*domain.Facet, error
 	}
 	return facets, nil
 }
+
+func (uc *courseUseCase) PeriodFacet(query domain.CourseQuery) ([]*domain.PeriodFacet, error) {
+	facets, err := uc.repo.PeriodFacet(query)
+	if err != nil {
+		return nil, err
+	}
+	return facets, nil
+}
+
+// CourseQuery.Facets で要求された集計、集計の種類ごとにその集計の対象のカラムに対する条件は取り除いて数える
+func (uc *courseUseCase) FacetValues(query domain.CourseQuery) (map[string][]*domain.FacetValue, error) {
+	facets := map[string][]*domain.FacetValue{}
+	for _, kind := range query.Facets {
+		if _, ok := facets[kind]; ok {
+			continue
+		}
+		facetQuery, err := query.WithoutFacetFilter(kind)
+		if err != nil {
+			return nil, err
+		}
+		facet, err := uc.repo.FacetValues(facetQuery, kind)
+		if err != nil {
+			return nil, err
+		}
+		facets[kind] = facet
+	}
+	return facets, nil
+}
+
+func (uc *courseUseCase) Years() ([]*domain.AcademicYear, error) {
+	years, err := uc.repo.Years()
+	if err != nil {
+		return nil, err
+	}
+	return years, nil
+}
+
+func (uc *courseUseCase) FindByID(id int) (*domain.Course, error) {
+	course, err := uc.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return course, nil
+}
+
+func (uc *courseUseCase) FindByNumber(year int, number string) (*domain.Course, error) {
+	course, err := uc.repo.FindByNumber(year, number)
+	if err != nil {
+		return nil, err
+	}
+	return course, nil
+}
+
+// 埋まっているコマと履修している科目のコマを求めて、それらと重ならない科目を検索する
+func (uc *courseUseCase) Fits(query domain.CourseFitsQuery) (*domain.CourseSearchResult, error) {
+	occupied := []domain.TimetableCell{}
+	for _, slot := range query.Occupied {
+		cells, err := slot.Cells()
+		if err != nil {
+			return nil, err
+		}
+		occupied = append(occupied, cells...)
+	}
+
+	if len(query.CourseNumbers) != 0 {
+		courses, err := uc.repo.FindByNumbers(query.CourseNumbers, query.Year)
+		if err != nil {
+			return nil, err
+		}
+		found := map[string]bool{}
+		for _, course := range courses {
+			found[course.CourseNumber] = true
+			occupied = append(occupied, course.TimetableCells()...)
+		}
+		for _, number := range query.CourseNumbers {
+			if !found[number] {
+				return nil, fmt.Errorf("course not found: %s", number)
+			}
+		}
+	}
+
+	searchQuery := query.CourseQuery
+	searchQuery.Occupied = occupied
+	result, err := uc.repo.Search(searchQuery)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
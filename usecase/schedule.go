@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/sylms/azuki/domain"
+)
+
+// 時間割の探索を打ち切るまでの時間
+const scheduleTimeLimit = 2 * time.Second
+
+// 時間割の探索で調べる組み合わせの数の上限
+const scheduleMaxNodes = 200000
+
+type ScheduleUseCase interface {
+	Generate(domain.ScheduleQuery) (*domain.ScheduleResult, error)
+}
+
+type scheduleUseCase struct {
+	repo domain.CourseRepository
+}
+
+func NewScheduleUseCase(repo domain.CourseRepository) ScheduleUseCase {
+	return &scheduleUseCase{
+		repo: repo,
+	}
+}
+
+// 候補の科目を探して、重なりの無い時間割を作る
+func (uc *scheduleUseCase) Generate(query domain.ScheduleQuery) (*domain.ScheduleResult, error) {
+	years := []string{}
+	if query.Year != 0 {
+		years = append(years, strconv.Itoa(query.Year))
+	}
+
+	keys := append(append([]string{}, query.Required...), query.Optional...)
+	sections, err := uc.findSections(keys, years)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []*domain.ScheduleItem{}
+	for _, key := range query.Required {
+		items = append(items, &domain.ScheduleItem{Key: key, Required: true, Sections: sections[key]})
+	}
+	for _, key := range query.Optional {
+		items = append(items, &domain.ScheduleItem{Key: key, Sections: sections[key]})
+	}
+
+	return domain.GenerateSchedules(items, domain.ScheduleOptions{
+		MaxCreditsPerTerm: query.MaxCreditsPerTerm,
+		FreeDays:          query.FreeDays,
+		AvoidFirstPeriod:  query.AvoidFirstPeriod,
+		Limit:             query.Limit,
+		MaxNodes:          scheduleMaxNodes,
+		Deadline:          time.Now().Add(scheduleTimeLimit),
+	}), nil
+}
+
+// 候補ごとの科目
+// 科目番号として見つからなければ科目コードとして探す
+func (uc *scheduleUseCase) findSections(keys []string, years []string) (map[string][]*domain.Course, error) {
+	sections := map[string][]*domain.Course{}
+	byNumber, err := uc.repo.FindByNumbers(keys, years)
+	if err != nil {
+		return nil, err
+	}
+	for _, course := range byNumber {
+		sections[course.CourseNumber] = append(sections[course.CourseNumber], course)
+	}
+
+	codes := []string{}
+	for _, key := range keys {
+		if _, ok := sections[key]; !ok {
+			codes = append(codes, key)
+		}
+	}
+	if len(codes) == 0 {
+		return sections, nil
+	}
+	byCode, err := uc.repo.FindByCodes(codes, years)
+	if err != nil {
+		return nil, err
+	}
+	for _, course := range byCode {
+		sections[course.CourseCode] = append(sections[course.CourseCode], course)
+	}
+	return sections, nil
+}
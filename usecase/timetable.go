@@ -0,0 +1,88 @@
+package usecase
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/sylms/azuki/domain"
+)
+
+type TimetableUseCase interface {
+	Check(domain.TimetableCheckQuery) (*domain.TimetableCheck, error)
+	Calendar(domain.TimetableCalendarQuery) (*domain.TimetableCalendar, error)
+}
+
+type timetableUseCase struct {
+	repo         domain.CourseRepository
+	calendarRepo domain.CalendarRepository
+}
+
+func NewTimetableUseCase(repo domain.CourseRepository, calendarRepo domain.CalendarRepository) TimetableUseCase {
+	return &timetableUseCase{
+		repo:         repo,
+		calendarRepo: calendarRepo,
+	}
+}
+
+// 科目番号から科目を探して時間割を検査する
+// 科目は与えられた順に並べ、同じ科目番号は 1 つにまとめる
+func (uc *timetableUseCase) Check(query domain.TimetableCheckQuery) (*domain.TimetableCheck, error) {
+	courses, err := uc.findCourses(query.CourseNumbers, query.Year)
+	if err != nil {
+		return nil, err
+	}
+	return domain.CheckTimetable(courses), nil
+}
+
+// 年度の学年暦に時間割の科目の授業を並べる
+func (uc *timetableUseCase) Calendar(query domain.TimetableCalendarQuery) (*domain.TimetableCalendar, error) {
+	calendar, err := uc.calendarRepo.FindByYear(query.Year)
+	if err != nil {
+		return nil, err
+	}
+	courses, err := uc.findCourses(query.CourseNumbers, query.Year)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.TimetableCalendar{
+		Calendar: calendar,
+		Events:   []*domain.CourseEvent{},
+	}
+	for _, course := range courses {
+		result.Events = append(result.Events, domain.CourseEvents(course, calendar)...)
+	}
+	return result, nil
+}
+
+// 科目番号の順に科目を探す
+// year が 0 なら最新の年度から探す
+func (uc *timetableUseCase) findCourses(numbers []string, year int) ([]*domain.Course, error) {
+	years := []string{}
+	if year != 0 {
+		years = append(years, strconv.Itoa(year))
+	}
+	found, err := uc.repo.FindByNumbers(numbers, years)
+	if err != nil {
+		return nil, err
+	}
+	byNumber := map[string]*domain.Course{}
+	for _, course := range found {
+		byNumber[course.CourseNumber] = course
+	}
+
+	courses := []*domain.Course{}
+	added := map[string]bool{}
+	for _, number := range numbers {
+		course, ok := byNumber[number]
+		if !ok {
+			return nil, fmt.Errorf("course not found: %s", number)
+		}
+		if added[number] {
+			continue
+		}
+		added[number] = true
+		courses = append(courses, course)
+	}
+	return courses, nil
+}
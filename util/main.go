@@ -1,7 +1,12 @@
 package util
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // element が source にあるか
@@ -25,3 +30,65 @@ func SplitSpace(text string) []string {
 
 	return strings.Fields(text)
 }
+
+// words から minLength 文字より短いものを取り除く
+func DropShortWords(words []string, minLength int) []string {
+	res := []string{}
+	for _, word := range words {
+		if utf8.RuneCountInString(word) >= minLength {
+			res = append(res, word)
+		}
+	}
+	return res
+}
+
+// 単位数の指定をパースして下限と上限を返す
+// "2.0" のような単一の値、"1.0-2.0" のような範囲、"1.0-" や "-2.0" のような片側のみの範囲を受け付ける
+// 範囲の区切りは "-", "〜", "~" のいずれか
+// 指定されていない側は nil となる
+func ParseCreditsRange(text string) (*float64, *float64, error) {
+	text = strings.TrimSpace(text)
+	for _, separator := range []string{"〜", "~"} {
+		text = strings.ReplaceAll(text, separator, "-")
+	}
+	if text == "" {
+		return nil, nil, errors.New("credits is empty")
+	}
+
+	minStr, maxStr := text, text
+	if i := strings.Index(text, "-"); i != -1 {
+		minStr = strings.TrimSpace(text[:i])
+		maxStr = strings.TrimSpace(text[i+1:])
+		if minStr == "" && maxStr == "" {
+			return nil, nil, fmt.Errorf("invalid credits range: %s", text)
+		}
+	}
+
+	parse := func(str string) (*float64, error) {
+		if str == "" {
+			return nil, nil
+		}
+		value, err := strconv.ParseFloat(str, 64)
+		// ParseFloat は "NaN" や "Inf" も受け付けてしまう
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, fmt.Errorf("invalid credits: %s", str)
+		}
+		if value < 0 {
+			return nil, fmt.Errorf("credits is negative: %s", str)
+		}
+		return &value, nil
+	}
+
+	minCredits, err := parse(minStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	maxCredits, err := parse(maxStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if minCredits != nil && maxCredits != nil && *minCredits > *maxCredits {
+		return nil, nil, fmt.Errorf("invalid credits range: %s", text)
+	}
+	return minCredits, maxCredits, nil
+}
@@ -72,3 +72,181 @@ func TestSplitSpace(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCreditsRange(t *testing.T) {
+	float := func(f float64) *float64 {
+		return &f
+	}
+	type args struct {
+		text string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantMin *float64
+		wantMax *float64
+		wantErr bool
+	}{
+		{
+			name: "single value",
+			args: args{
+				text: "2.0",
+			},
+			wantMin: float(2),
+			wantMax: float(2),
+		},
+		{
+			name: "range",
+			args: args{
+				text: "1.0-2.0",
+			},
+			wantMin: float(1),
+			wantMax: float(2),
+		},
+		{
+			name: "zenkaku wave dash",
+			args: args{
+				text: "1〜2",
+			},
+			wantMin: float(1),
+			wantMax: float(2),
+		},
+		{
+			name: "tilde",
+			args: args{
+				text: "1~2",
+			},
+			wantMin: float(1),
+			wantMax: float(2),
+		},
+		{
+			name: "long vowel mark is not a separator",
+			args: args{
+				text: "1ー2",
+			},
+			wantErr: true,
+		},
+		{
+			name: "NaN",
+			args: args{
+				text: "NaN",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Inf",
+			args: args{
+				text: "Inf",
+			},
+			wantErr: true,
+		},
+		{
+			name: "infinite upper bound",
+			args: args{
+				text: "1-+Inf",
+			},
+			wantErr: true,
+		},
+		{
+			name: "min only",
+			args: args{
+				text: "1.5-",
+			},
+			wantMin: float(1.5),
+		},
+		{
+			name: "max only",
+			args: args{
+				text: "-2.0",
+			},
+			wantMax: float(2),
+		},
+		{
+			name: "min is greater than max",
+			args: args{
+				text: "3.0-1.0",
+			},
+			wantErr: true,
+		},
+		{
+			name: "not a number",
+			args: args{
+				text: "abc",
+			},
+			wantErr: true,
+		},
+		{
+			name: "separator only",
+			args: args{
+				text: "-",
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty text",
+			args: args{
+				text: "",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotMin, gotMax, err := ParseCreditsRange(tt.args.text)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseCreditsRange() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotMin, tt.wantMin) {
+				t.Errorf("ParseCreditsRange() min = %v, want %v", gotMin, tt.wantMin)
+			}
+			if !reflect.DeepEqual(gotMax, tt.wantMax) {
+				t.Errorf("ParseCreditsRange() max = %v, want %v", gotMax, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestDropShortWords(t *testing.T) {
+	type args struct {
+		words     []string
+		minLength int
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{
+			name: "1 文字のものを取り除く",
+			args: args{
+				words:     []string{"線形代数", "A", "の", "情報"},
+				minLength: 2,
+			},
+			want: []string{"線形代数", "情報"},
+		},
+		{
+			name: "バイト数ではなく文字数で数える",
+			args: args{
+				words:     []string{"数", "ab"},
+				minLength: 2,
+			},
+			want: []string{"ab"},
+		},
+		{
+			name: "すべて短い",
+			args: args{
+				words:     []string{"a", "b"},
+				minLength: 2,
+			},
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DropShortWords(tt.args.words, tt.args.minLength); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DropShortWords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
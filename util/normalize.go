@@ -0,0 +1,106 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+type NormalizeOptions struct {
+	// カタカナをひらがなにそろえる
+	FoldKana bool
+}
+
+// 検索のために文字列の表記ゆれをそろえる
+// 検索キーワードと検索対象の文字列の両方に同じものを適用して比較する
+//   - NFKC で半角カナ・全角英数字などをそろえる ("ﾌﾟﾛｸﾞﾗﾐﾝｸﾞ" -> "プログラミング", "ＧＡ１" -> "GA1")
+//   - 大文字・小文字をそろえる
+//   - ローマ数字をアラビア数字にする ("解析学II", "解析学Ⅱ" -> "解析学2")
+//   - options.FoldKana ならカタカナをひらがなにする
+func Normalize(text string, options NormalizeOptions) string {
+	text = norm.NFKC.String(text)
+	text = strings.ToLower(text)
+	text = replaceRomanNumerals(text)
+	if options.FoldKana {
+		text = foldKana(text)
+	}
+	return text
+}
+
+// カタカナをひらがなにする
+// 対応するひらがなが無い "ヷ" などや、長音記号 "ー" はそのまま
+func foldKana(text string) string {
+	return strings.Map(func(r rune) rune {
+		if 'ァ' <= r && r <= 'ヶ' {
+			return r - ('ァ' - 'ぁ')
+		}
+		return r
+	}, text)
+}
+
+// 英字・数字と隣り合っていない i, v, x の並びをローマ数字とみなしてアラビア数字にする
+// "analysis ii" や "解析学ii" は置き換えるが、"vision" や教室の "3v207" は置き換えない
+// 小文字にした後に呼び出す
+func replaceRomanNumerals(text string) string {
+	runes := []rune(text)
+	isAlnum := func(i int) bool {
+		if i < 0 || len(runes) <= i {
+			return false
+		}
+		r := runes[i]
+		return r <= unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+	}
+	isRomanNumeral := func(r rune) bool {
+		return r == 'i' || r == 'v' || r == 'x'
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(runes); {
+		if !isRomanNumeral(runes[i]) || isAlnum(i-1) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+
+		end := i
+		for end < len(runes) && isRomanNumeral(runes[end]) {
+			end++
+		}
+		value, ok := parseRomanNumeral(string(runes[i:end]))
+		if ok && !isAlnum(end) {
+			b.WriteString(strconv.Itoa(value))
+		} else {
+			b.WriteString(string(runes[i:end]))
+		}
+		i = end
+	}
+	return b.String()
+}
+
+// i, v, x からなる 1 から 39 までのローマ数字を解釈する
+// "iiii" や "vx" のような正しくない表記は受け付けない
+func parseRomanNumeral(text string) (int, bool) {
+	values := map[byte]int{'i': 1, 'v': 5, 'x': 10}
+
+	value := 0
+	for i := 0; i < len(text); i++ {
+		v := values[text[i]]
+		if i+1 < len(text) && v < values[text[i+1]] {
+			value -= v
+		} else {
+			value += v
+		}
+	}
+
+	// 値から正しい表記を組み立て直して、同じになるものだけを受け付ける
+	if value <= 0 || 40 <= value {
+		return 0, false
+	}
+	canonical := strings.Repeat("x", value/10) + []string{"", "i", "ii", "iii", "iv", "v", "vi", "vii", "viii", "ix"}[value%10]
+	if canonical != text {
+		return 0, false
+	}
+	return value, true
+}
@@ -0,0 +1,143 @@
+package util
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	type args struct {
+		text    string
+		options NormalizeOptions
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "半角カナ",
+			args: args{
+				text:    "ﾌﾟﾛｸﾞﾗﾐﾝｸﾞ入門A",
+				options: NormalizeOptions{FoldKana: true},
+			},
+			want: "ぷろぐらみんぐ入門a",
+		},
+		{
+			name: "全角カナ",
+			args: args{
+				text:    "プログラミング入門A",
+				options: NormalizeOptions{FoldKana: true},
+			},
+			want: "ぷろぐらみんぐ入門a",
+		},
+		{
+			name: "カタカナをそろえない",
+			args: args{
+				text:    "ﾃﾞｰﾀ構造とｱﾙｺﾞﾘｽﾞﾑ実験",
+				options: NormalizeOptions{},
+			},
+			want: "データ構造とアルゴリズム実験",
+		},
+		{
+			name: "長音記号や中黒はそのまま",
+			args: args{
+				text:    "シミュレーション物理 確率・統計",
+				options: NormalizeOptions{FoldKana: true},
+			},
+			want: "しみゅれーしょん物理 確率・統計",
+		},
+		{
+			name: "全角英数字",
+			args: args{
+				text:    "ＧＡ１",
+				options: NormalizeOptions{},
+			},
+			want: "ga1",
+		},
+		{
+			name: "全角括弧",
+			args: args{
+				text:    "総合学域群生（知識情報・図書館学類への移行希望者）",
+				options: NormalizeOptions{},
+			},
+			want: "総合学域群生(知識情報・図書館学類への移行希望者)",
+		},
+		{
+			name: "ローマ数字 (英字)",
+			args: args{
+				text:    "解析学II",
+				options: NormalizeOptions{},
+			},
+			want: "解析学2",
+		},
+		{
+			name: "ローマ数字 (数字の記号)",
+			args: args{
+				text:    "解析学Ⅱ",
+				options: NormalizeOptions{},
+			},
+			want: "解析学2",
+		},
+		{
+			name: "アラビア数字はそのまま",
+			args: args{
+				text:    "解析学2",
+				options: NormalizeOptions{},
+			},
+			want: "解析学2",
+		},
+		{
+			name: "括弧の中のローマ数字",
+			args: args{
+				text:    "「解析学III」(GB10504)の単位を修得した者の履修は認めない。",
+				options: NormalizeOptions{},
+			},
+			want: "「解析学3」(gb10504)の単位を修得した者の履修は認めない。",
+		},
+		{
+			name: "英語の科目名のローマ数字",
+			args: args{
+				text:    "Analysis II",
+				options: NormalizeOptions{},
+			},
+			want: "analysis 2",
+		},
+		{
+			name: "単語の中の i, v, x は置き換えない",
+			args: args{
+				text:    "Computer Simulation Methods in Physics",
+				options: NormalizeOptions{},
+			},
+			want: "computer simulation methods in physics",
+		},
+		{
+			name: "科目番号や教室の中の i, v, x は置き換えない",
+			args: args{
+				text:    "3V207 GB1X",
+				options: NormalizeOptions{},
+			},
+			want: "3v207 gb1x",
+		},
+		{
+			name: "正しくないローマ数字は置き換えない",
+			args: args{
+				text:    "IIII VX IV",
+				options: NormalizeOptions{},
+			},
+			want: "iiii vx 4",
+		},
+		{
+			name: "空文字列",
+			args: args{
+				text:    "",
+				options: NormalizeOptions{FoldKana: true},
+			},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalize(tt.args.text, tt.args.options); got != tt.want {
+				t.Errorf("Normalize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}